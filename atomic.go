@@ -0,0 +1,106 @@
+package bankeodprocessor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// backupFileSuffix is appended to the output file name to name its backup.
+	backupFileSuffix = ".bak"
+	// defaultFileMode is the mode of newly created output file.
+	defaultFileMode os.FileMode = 0644
+)
+
+// writeFileAtomic will write into given file name through a temporary file in the same directory.
+// The temporary file is synced to disk and renamed into place only if given write succeed,
+// so the file is either left untouched or completely replaced even if the process crash mid-write.
+// If backup file name is not empty, the previous file is copied into it before being replaced.
+func writeFileAtomic(fileName, backupFileName string, write func(w io.Writer) error) error {
+	return publishFileAtomic(fileName, backupFileName, write, nil)
+}
+
+// publishFileAtomic will write into given file name like writeFileAtomic, calling given publish function
+// once the temporary file is complete and just before it is renamed into place.
+// The file is left untouched if the publish function fail. Publish function is ignored if nil.
+func publishFileAtomic(fileName, backupFileName string, write func(w io.Writer) error, publish func() error) (err error) {
+	mode := defaultFileMode
+	info, statErr := os.Stat(fileName)
+	if statErr == nil {
+		mode = info.Mode().Perm()
+	} else if !errors.Is(statErr, os.ErrNotExist) {
+		return statErr
+	}
+	dir := filepath.Dir(fileName)
+	tempHandle, err := os.CreateTemp(dir, "."+filepath.Base(fileName)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tempFileName := tempHandle.Name()
+	defer func() {
+		// Leave no temporary file behind on failure.
+		if err != nil {
+			tempHandle.Close()
+			os.Remove(tempFileName)
+		}
+	}()
+	buffer := bufio.NewWriter(tempHandle)
+	if err := write(buffer); err != nil {
+		return err
+	}
+	if err := buffer.Flush(); err != nil {
+		return err
+	}
+	if err := tempHandle.Chmod(mode); err != nil {
+		return err
+	}
+	if err := tempHandle.Sync(); err != nil {
+		return err
+	}
+	if err := tempHandle.Close(); err != nil {
+		return err
+	}
+	if publish != nil {
+		if err := publish(); err != nil {
+			return err
+		}
+	}
+	if len(backupFileName) > 0 && statErr == nil {
+		if err := copyFileAtomic(fileName, backupFileName); err != nil {
+			return fmt.Errorf(`failed to backup provided file %w`, err)
+		}
+	}
+	if err := os.Rename(tempFileName, fileName); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// copyFileAtomic will atomically replace destination file name with the content of source file name.
+func copyFileAtomic(sourceFileName, destinationFileName string) error {
+	sourceHandle, err := os.Open(sourceFileName)
+	if err != nil {
+		return err
+	}
+	defer sourceHandle.Close()
+	return writeFileAtomic(destinationFileName, "", func(w io.Writer) error {
+		_, err := io.Copy(w, sourceHandle)
+		return err
+	})
+}
+
+// syncDir will make the latest rename inside given directory durable.
+// It is best effort since not every platform support syncing a directory.
+func syncDir(dir string) {
+	dirHandle, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	dirHandle.Sync()
+	dirHandle.Close()
+}
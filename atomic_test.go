@@ -0,0 +1,100 @@
+package bankeodprocessor
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	type args struct {
+		previous string
+		backup   bool
+		write    func(w io.Writer) error
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       string
+		wantBackup string
+		wantErr    bool
+	}{
+		{
+			"Given new file then it must succeed",
+			args{"", false, func(w io.Writer) error {
+				_, err := io.WriteString(w, "new")
+				return err
+			}},
+			"new",
+			"",
+			false,
+		},
+		{
+			"Given existing file with backup then it must keep the previous file",
+			args{"old", true, func(w io.Writer) error {
+				_, err := io.WriteString(w, "new")
+				return err
+			}},
+			"new",
+			"old",
+			false,
+		},
+		{
+			"Given failed write then it must keep the previous file",
+			args{"old", true, func(w io.Writer) error {
+				io.WriteString(w, "half")
+				return errors.New("an error")
+			}},
+			"old",
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fileName := filepath.Join(dir, "After Eod.csv")
+			if len(tt.args.previous) > 0 {
+				if err := os.WriteFile(fileName, []byte(tt.args.previous), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			backupFileName := ""
+			if tt.args.backup {
+				backupFileName = fileName + backupFileSuffix
+			}
+			if err := writeFileAtomic(fileName, backupFileName, tt.args.write); (err != nil) != tt.wantErr {
+				t.Errorf("writeFileAtomic() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			got, err := os.ReadFile(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("writeFileAtomic() = %v, want %v", string(got), tt.want)
+			}
+			if len(tt.wantBackup) > 0 {
+				gotBackup, err := os.ReadFile(backupFileName)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(gotBackup) != tt.wantBackup {
+					t.Errorf("writeFileAtomic() backup = %v, want %v", string(gotBackup), tt.wantBackup)
+				}
+			}
+			// Only the file and its backup must be left in the directory.
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if entry.Name() != filepath.Base(fileName) && entry.Name() != filepath.Base(fileName)+backupFileSuffix {
+					t.Errorf("writeFileAtomic() left unexpected file %v", entry.Name())
+				}
+			}
+		})
+	}
+}
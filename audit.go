@@ -0,0 +1,325 @@
+package bankeodprocessor
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+const (
+	// auditGenesisHash is the previous hash of the first entry of an audit log.
+	auditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+	// auditTailChunkSize is the size of the chunk read backward to find the last entry of an audit log.
+	auditTailChunkSize = 4096
+)
+
+var (
+	ErrAuditChainBroken = errors.New("audit chain is broken")
+)
+
+// AuditEntry represent a single change applied to an account by a rule of a pipeline stage.
+type AuditEntry struct {
+	// RunID is the id of the run which applied the change.
+	RunID string `json:"run_id"`
+	// BusinessDate is the business date of the run formatted using BusinessDateLayout.
+	BusinessDate string `json:"business_date"`
+	// AccountID is the id of the changed account.
+	AccountID string `json:"account_id"`
+	// Line is the line number of the account in the input, the header is line 1.
+	Line int `json:"line"`
+	// Stage is the name of the stage which applied the change.
+	Stage string `json:"stage"`
+	// Rule is the name of the rule which applied the change.
+	Rule string `json:"rule"`
+	// Field is the changed field, either pipeline.FieldBalanced or pipeline.FieldFreeTransfer.
+	Field string `json:"field"`
+	// Old is the value of the field before the change.
+	Old money.Amount `json:"old"`
+	// New is the value of the field after the change.
+	New money.Amount `json:"new"`
+	// RecordedAt is the time the change has been recorded.
+	RecordedAt time.Time `json:"recorded_at"`
+	// PrevHash is the hash of the previous entry of the audit log, or zeroes for the first entry.
+	PrevHash string `json:"prev_hash"`
+	// Hash is the hex encoded SHA-256 hash of the entry with an empty hash, which include the previous hash.
+	Hash string `json:"hash"`
+}
+
+// chainHash will return the hash of given entry computed with an empty hash.
+func chainHash(entry AuditEntry) (string, error) {
+	entry.Hash = ""
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditLog represent a local append-only file recording every change applied to the accounts.
+// Each entry is stored as a JSON line so recording a run never rewrite previous entries.
+// Entries are hash-chained, each entry include the hash of the previous one so any edit
+// of a recorded entry break the chain and can be detected by Verify.
+type AuditLog struct {
+	fileName string
+	mutex    sync.Mutex
+}
+
+// NewAuditLog will return a new AuditLog stored on given file name.
+// The file is created on the first recorded run.
+func NewAuditLog(fileName string) *AuditLog {
+	return &AuditLog{
+		fileName: fileName,
+	}
+}
+
+// Record will chain given entries after the last entry of the audit file then append them into the file.
+// The hashes of given entries are updated.
+// Will return the hash of the last entry of the audit file after recording, called the head, which is recorded
+// into the ledger so Verify can detect a truncated or fully recomputed chain.
+// The file is not created if there is no entry.
+func (a *AuditLog) Record(entries []AuditEntry) (string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if len(entries) == 0 {
+		return a.head()
+	}
+	fileHandle, err := os.OpenFile(a.fileName, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return "", fmt.Errorf(`failed to write to provided audit file %w`, err)
+	}
+	prevHash, err := lastAuditHash(fileHandle)
+	if err != nil {
+		fileHandle.Close()
+		return "", err
+	}
+	writer := bufio.NewWriter(fileHandle)
+	encoder := json.NewEncoder(writer)
+	for idx := range entries {
+		entry := &entries[idx]
+		entry.PrevHash = prevHash
+		if entry.Hash, err = chainHash(*entry); err != nil {
+			fileHandle.Close()
+			return "", fmt.Errorf(`failed to write to provided audit file %w`, err)
+		}
+		if err := encoder.Encode(entry); err != nil {
+			fileHandle.Close()
+			return "", fmt.Errorf(`failed to write to provided audit file %w`, err)
+		}
+		prevHash = entry.Hash
+	}
+	if err := writer.Flush(); err != nil {
+		fileHandle.Close()
+		return "", fmt.Errorf(`failed to write to provided audit file %w`, err)
+	}
+	if err := fileHandle.Sync(); err != nil {
+		fileHandle.Close()
+		return "", fmt.Errorf(`failed to write to provided audit file %w`, err)
+	}
+	return prevHash, fileHandle.Close()
+}
+
+// Verify will recompute the hash chain of every entry of the audit file.
+// Will return the amount of verified entries.
+// Will return ErrAuditChainBroken with the line number of the first entry that is invalid, modified,
+// or which previous entry has been modified, removed or inserted.
+// The chain alone can't detect removed trailing entries or a chain recomputed from the first entry,
+// so if given ledger is not nil every head recorded in the ledger for the audit file must also be part of the chain.
+func (a *AuditLog) Verify(ledger *Ledger) (int, error) {
+	heads, err := a.ledgerHeads(ledger)
+	if err != nil {
+		return 0, err
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	fileHandle, err := os.Open(a.fileName)
+	if err != nil {
+		return 0, fmt.Errorf(`failed to read provided audit file %w`, err)
+	}
+	defer fileHandle.Close()
+	prevHash := auditGenesisHash
+	delete(heads, prevHash)
+	verified := 0
+	reader := bufio.NewReader(fileHandle)
+	for line := 1; ; line++ {
+		payload, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(payload)) > 0 {
+			if prevHash, err = verifyAuditEntry(payload, prevHash); err != nil {
+				return verified, fmt.Errorf("%w at line %d, %v", ErrAuditChainBroken, line, err)
+			}
+			delete(heads, prevHash)
+			verified++
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return verified, fmt.Errorf(`failed to read provided audit file %w`, err)
+			}
+			break
+		}
+	}
+	for head, runID := range heads {
+		return verified, fmt.Errorf(`%w, head "%s" of run "%s" recorded in the ledger is not part of the chain`, ErrAuditChainBroken, head, runID)
+	}
+	return verified, nil
+}
+
+// head will return the hash of the last entry of the audit file, or the genesis hash if the file doesn't exist.
+func (a *AuditLog) head() (string, error) {
+	fileHandle, err := os.Open(a.fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return auditGenesisHash, nil
+		}
+		return "", fmt.Errorf(`failed to read provided audit file %w`, err)
+	}
+	defer fileHandle.Close()
+	return lastAuditHash(fileHandle)
+}
+
+// ledgerHeads will return the run id of every audit head recorded in given ledger for the audit file keyed by the head.
+// Will return empty heads if the ledger is nil.
+func (a *AuditLog) ledgerHeads(ledger *Ledger) (map[string]string, error) {
+	heads := make(map[string]string)
+	if ledger == nil {
+		return heads, nil
+	}
+	_, err := ledger.find(func(entry *LedgerEntry) bool {
+		if len(entry.AuditHead) > 0 && samePath(entry.AuditFile, a.fileName) {
+			heads[entry.AuditHead] = entry.RunID
+		}
+		return false
+	})
+	return heads, err
+}
+
+// verifyAuditEntry will verify given JSON entry is chained after given previous hash.
+// Will return the hash of the entry.
+func verifyAuditEntry(payload []byte, prevHash string) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	// Unknown field would be dropped from the recomputed hash.
+	decoder.DisallowUnknownFields()
+	entry := AuditEntry{}
+	if err := decoder.Decode(&entry); err != nil {
+		return "", fmt.Errorf("invalid entry, %v", err)
+	}
+	if entry.PrevHash != prevHash {
+		return "", errors.New("previous hash doesn't match the previous entry")
+	}
+	computed, err := chainHash(entry)
+	if err != nil {
+		return "", err
+	}
+	if computed != entry.Hash {
+		return "", errors.New("hash doesn't match the entry")
+	}
+	return entry.Hash, nil
+}
+
+// lastAuditHash will return the hash of the last entry of given audit file, or the genesis hash if it's empty.
+// The file is read backward so recording doesn't read the whole audit log.
+func lastAuditHash(fileHandle *os.File) (string, error) {
+	info, err := fileHandle.Stat()
+	if err != nil {
+		return "", fmt.Errorf(`failed to read provided audit file %w`, err)
+	}
+	var tail []byte
+	lineStart := -1
+	for offset := info.Size(); offset > 0 && lineStart < 0; {
+		size := int64(auditTailChunkSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+		chunk := make([]byte, size)
+		if _, err := fileHandle.ReadAt(chunk, offset); err != nil {
+			return "", fmt.Errorf(`failed to read provided audit file %w`, err)
+		}
+		tail = bytes.TrimRight(append(chunk, tail...), "\r\n\t ")
+		lineStart = bytes.LastIndexByte(tail, '\n')
+	}
+	lastLine := tail[lineStart+1:]
+	if len(lastLine) == 0 {
+		return auditGenesisHash, nil
+	}
+	entry := AuditEntry{}
+	if err := json.Unmarshal(lastLine, &entry); err != nil {
+		return "", fmt.Errorf(`failed to read the last entry of provided audit file %w`, err)
+	}
+	return entry.Hash, nil
+}
+
+// adjustedRow represent the changes applied to a processed row.
+type adjustedRow struct {
+	id          string
+	line        int
+	adjustments []pipeline.Adjustment
+}
+
+// auditEntries will return the audit entries of every change of given adjusted rows ordered by line
+// then by the order the changes are applied.
+func auditEntries(runID, businessDate string, rows []adjustedRow, recordedAt time.Time) []AuditEntry {
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].line < rows[j].line
+	})
+	entries := []AuditEntry{}
+	for _, row := range rows {
+		for _, adjustment := range row.adjustments {
+			entries = append(entries, AuditEntry{
+				RunID:        runID,
+				BusinessDate: businessDate,
+				AccountID:    row.id,
+				Line:         row.line,
+				Stage:        adjustment.Stage,
+				Rule:         adjustment.Rule,
+				Field:        adjustment.Field,
+				Old:          adjustment.Old,
+				New:          adjustment.New,
+				RecordedAt:   recordedAt,
+			})
+		}
+	}
+	return entries
+}
+
+// recordAudit will record every change of the run of given report into the audit log.
+// The audit file and its head are set into given ledger entry if it is not nil.
+// Does nothing if the audit log is not configured.
+func (e *EODProcessor) recordAudit(entry *LedgerEntry, report *RunReport) error {
+	if e.auditLog == nil {
+		return nil
+	}
+	entries := auditEntries(report.RunID, e.runBusinessDate().Format(BusinessDateLayout), report.adjusted, time.Now())
+	head, err := e.auditLog.Record(entries)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		entry.AuditFile = e.auditLog.fileName
+		entry.AuditHead = head
+	}
+	return nil
+}
+
+// newRunID will return a new random hex encoded id of a run.
+// Fallback to the current time if the random source is not available.
+func newRunID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,211 @@
+package bankeodprocessor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+func TestEODProcessor_Audit(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "Before Eod.csv")
+	outputPath := filepath.Join(dir, "After Eod.csv")
+	auditPath := filepath.Join(dir, "After Eod Audit.jsonl")
+	if err := os.WriteFile(inputPath, []byte(`id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer
+1;Test 1;24;200;100;100;3
+2;Test 2;25;120;150;100;1
+3;Test 3;25;BAD;150;100;1`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runIDs := []string{}
+	for run := 0; run < 2; run++ {
+		bonusDistributor := pipeline.NewBonusDistributor(nil)
+		benefitCalculator := pipeline.NewBenefitCalculator(bonusDistributor.Channel())
+		parser := NewParser(benefitCalculator.Channel())
+		eodCalculator := NewEODProcessor(
+			pipeline.NewChain(parser, benefitCalculator, bonusDistributor),
+			WithAuditLog(NewAuditLog(auditPath)),
+			WithBusinessDate(time.Date(2022, 10, 1, 0, 0, 0, 0, time.Local)),
+		)
+		report, err := eodCalculator.Process(context.Background(), inputPath, outputPath)
+		eodCalculator.Close()
+		if err != nil {
+			t.Fatalf("EODProcessor.Process() error = %v, want nil", err)
+		}
+		runIDs = append(runIDs, report.RunID)
+		// Every run start from the same output.
+		if err := os.Remove(outputPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if runIDs[0] == runIDs[1] {
+		t.Errorf("EODProcessor.Process() run id = %v, want different id for every run", runIDs)
+	}
+
+	fileHandle, err := os.Open(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileHandle.Close()
+	got := []AuditEntry{}
+	scanner := bufio.NewScanner(fileHandle)
+	for scanner.Scan() {
+		entry := AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.RecordedAt.IsZero() {
+			t.Errorf("AuditEntry.RecordedAt = zero, want recorded time")
+		}
+		entry.RecordedAt = time.Time{}
+		// Hash chain is verified separately.
+		entry.PrevHash = ""
+		entry.Hash = ""
+		got = append(got, entry)
+	}
+	want := []AuditEntry{}
+	for _, runID := range runIDs {
+		want = append(want,
+			AuditEntry{runID, "2022-10-01", "1", 2, pipeline.BenefitCalculatorStageName, "balanced-bonus", pipeline.FieldBalanced, money.FromInt(200), money.FromInt(225), time.Time{}, "", ""},
+			AuditEntry{runID, "2022-10-01", "1", 2, pipeline.BonusDistributorStageName, pipeline.BonusRuleName, pipeline.FieldBalanced, money.FromInt(225), money.FromInt(235), time.Time{}, "", ""},
+			AuditEntry{runID, "2022-10-01", "2", 3, pipeline.BenefitCalculatorStageName, "free-transfer", pipeline.FieldFreeTransfer, money.FromInt(1), money.FromInt(5), time.Time{}, "", ""},
+			AuditEntry{runID, "2022-10-01", "2", 3, pipeline.BonusDistributorStageName, pipeline.BonusRuleName, pipeline.FieldBalanced, money.FromInt(120), money.FromInt(130), time.Time{}, "", ""},
+		)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AuditLog entries = %v, want %v", got, want)
+	}
+	if verified, err := NewAuditLog(auditPath).Verify(nil); err != nil || verified != len(want) {
+		t.Errorf("AuditLog.Verify() = %v, %v, want %v, nil", verified, err, len(want))
+	}
+
+	// Changes are not kept in memory without an audit log.
+	bonusDistributor := pipeline.NewBonusDistributor(nil)
+	benefitCalculator := pipeline.NewBenefitCalculator(bonusDistributor.Channel())
+	parser := NewParser(benefitCalculator.Channel())
+	eodCalculator := NewEODProcessor(pipeline.NewChain(parser, benefitCalculator, bonusDistributor), WithDuplicatePolicy(DuplicatePolicyIgnore))
+	defer eodCalculator.Close()
+	report, err := eodCalculator.ProcessStreamFile(context.Background(), inputPath, outputPath)
+	if err != nil {
+		t.Fatalf("EODProcessor.ProcessStreamFile() error = %v, want nil", err)
+	}
+	if len(report.adjusted) != 0 {
+		t.Errorf("RunReport.adjusted = %v, want empty without audit log", report.adjusted)
+	}
+}
+
+func TestAuditLog_Verify(t *testing.T) {
+	entries := func() []AuditEntry {
+		return []AuditEntry{
+			{RunID: "run", AccountID: "1", Field: pipeline.FieldBalanced, Old: money.FromInt(200), New: money.FromInt(225)},
+			{RunID: "run", AccountID: "1", Field: pipeline.FieldBalanced, Old: money.FromInt(225), New: money.FromInt(235)},
+			{RunID: "run", AccountID: "2", Field: pipeline.FieldBalanced, Old: money.FromInt(120), New: money.FromInt(130)},
+		}
+	}
+	tests := []struct {
+		name     string
+		tamper   func(lines []string) []string
+		wantLine string
+	}{
+		{
+			"Given untouched log then it must succeed",
+			func(lines []string) []string { return lines },
+			"",
+		},
+		{
+			"Given edited value then it must report the edited entry",
+			func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"new":235`, `"new":335`, 1)
+				return lines
+			},
+			"line 2",
+		},
+		{
+			"Given removed entry then it must report the next entry",
+			func(lines []string) []string { return append(lines[:1], lines[2:]...) },
+			"line 2",
+		},
+		{
+			"Given removed trailing entry then it must report the missing head",
+			func(lines []string) []string { return lines[:2] },
+			"not part of the chain",
+		},
+		{
+			"Given recomputed chain then it must report the missing head",
+			func(lines []string) []string {
+				prevHash := auditGenesisHash
+				for idx, line := range lines {
+					entry := AuditEntry{}
+					if err := json.Unmarshal([]byte(line), &entry); err != nil {
+						t.Fatal(err)
+					}
+					if idx == 1 {
+						entry.New = money.FromInt(335)
+					}
+					entry.PrevHash = prevHash
+					entry.Hash, _ = chainHash(entry)
+					prevHash = entry.Hash
+					payload, _ := json.Marshal(entry)
+					lines[idx] = string(payload)
+				}
+				return lines
+			},
+			"not part of the chain",
+		},
+		{
+			"Given unknown field then it must report the entry",
+			func(lines []string) []string {
+				lines[2] = strings.Replace(lines[2], "{", `{"note":"x",`, 1)
+				return lines
+			},
+			"line 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fileName := filepath.Join(dir, "audit.jsonl")
+			auditLog := NewAuditLog(fileName)
+			ledger := NewLedger(filepath.Join(dir, "ledger.jsonl"))
+			// Record in two batches so the chain continue from the last entry of the file.
+			batch := entries()
+			for _, entries := range [][]AuditEntry{batch[:2], batch[2:]} {
+				head, err := auditLog.Record(entries)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := ledger.Record(&LedgerEntry{RunID: "run", AuditFile: fileName, AuditHead: head}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			payload, err := os.ReadFile(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := tt.tamper(strings.Split(strings.TrimSpace(string(payload)), "\n"))
+			if err := os.WriteFile(fileName, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err = auditLog.Verify(ledger)
+			if len(tt.wantLine) == 0 {
+				if err != nil {
+					t.Errorf("AuditLog.Verify() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrAuditChainBroken) || !strings.Contains(err.Error(), tt.wantLine) {
+				t.Errorf("AuditLog.Verify() error = %v, want broken chain at %v", err, tt.wantLine)
+			}
+		})
+	}
+}
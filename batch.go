@@ -0,0 +1,301 @@
+package bankeodprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// batchRejectSuffix is appended to the output name of a batch file to name its reject file.
+	batchRejectSuffix = " Reject"
+)
+
+var (
+	ErrEmptyBatch         = errors.New("no input file found for the batch")
+	ErrBatchOutputIsInput = errors.New("batch output would replace its input")
+	ErrBatchFailed        = errors.New("batch has failed files")
+)
+
+// BatchFile represent an input file of a batch with the files written for it.
+type BatchFile struct {
+	// Input is the file name of the input.
+	Input string
+	// Output is the file name of the output.
+	Output string
+	// Reject is the file name of the rejected rows.
+	Reject string
+	// Reconciliation is the file name of the reconciliation, the summary of the file.
+	Reconciliation string
+}
+
+// BatchFiles will find the input files of given pattern and place the files written for each of them under given output directory.
+// Pattern is either a directory or a glob. Every regular file directly inside a directory is an input if it has one of
+// given extensions and isn't a reject file, so outputs and side files such as the ledger or the audit log are skipped.
+// Given nil extensions accept every extension, a glob is taken as is.
+// Each output keep the path of its input relative to the deepest directory holding every input, so inputs of the same name
+// in different directories don't collide. Reject and reconciliation file are placed next to the output.
+// Will return ErrEmptyBatch if no input is found and ErrBatchOutputIsInput if an output is one of the inputs.
+func BatchFiles(pattern, outputDir string, extensions []string) ([]BatchFile, error) {
+	inputs, err := batchInputs(pattern, extensions)
+	if err != nil {
+		return nil, err
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf(`%w "%s"`, ErrEmptyBatch, pattern)
+	}
+	absInputs := make([]string, len(inputs))
+	inputSet := make(map[string]bool, len(inputs))
+	for idx, input := range inputs {
+		if absInputs[idx], err = filepath.Abs(input); err != nil {
+			return nil, fmt.Errorf(`failed to process provided input file %w`, err)
+		}
+		inputSet[absInputs[idx]] = true
+	}
+	root := commonDir(absInputs)
+	files := make([]BatchFile, len(inputs))
+	for idx, input := range inputs {
+		rel, err := filepath.Rel(root, absInputs[idx])
+		if err != nil {
+			return nil, fmt.Errorf(`failed to process provided input file %w`, err)
+		}
+		output := filepath.Join(outputDir, rel)
+		absOutput, err := filepath.Abs(output)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to process provided output file %w`, err)
+		}
+		if inputSet[absOutput] {
+			return nil, fmt.Errorf(`%w "%s"`, ErrBatchOutputIsInput, output)
+		}
+		stem := strings.TrimSuffix(output, filepath.Ext(output))
+		files[idx] = BatchFile{
+			Input:          input,
+			Output:         output,
+			Reject:         stem + batchRejectSuffix + filepath.Ext(output),
+			Reconciliation: stem + " Reconciliation.json",
+		}
+	}
+	return files, nil
+}
+
+// batchInputs will return the regular files of given directory or glob pattern sorted by name.
+// Files of a directory are filtered by given extensions.
+func batchInputs(pattern string, extensions []string) ([]string, error) {
+	if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+		entries, err := os.ReadDir(pattern)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to read provided input directory %w`, err)
+		}
+		inputs := []string{}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && isBatchInputFile(entry.Name(), extensions) {
+				inputs = append(inputs, filepath.Join(pattern, entry.Name()))
+			}
+		}
+		return inputs, nil
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf(`%w, %v`, ErrEmptyBatch, err)
+	}
+	inputs := []string{}
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+			inputs = append(inputs, match)
+		}
+	}
+	sort.Strings(inputs)
+	return inputs, nil
+}
+
+// isBatchInputFile will return true if given file name of a directory has one of given extensions and isn't a reject file.
+// Every extension is accepted if given extensions is nil.
+func isBatchInputFile(fileName string, extensions []string) bool {
+	extension := filepath.Ext(fileName)
+	if strings.HasSuffix(strings.TrimSuffix(fileName, extension), batchRejectSuffix) {
+		return false
+	}
+	if extensions == nil {
+		return true
+	}
+	for _, accepted := range extensions {
+		if strings.EqualFold(extension, accepted) {
+			return true
+		}
+	}
+	return false
+}
+
+// commonDir will return the deepest directory holding every given absolute file name.
+func commonDir(fileNames []string) string {
+	dir := filepath.Dir(fileNames[0])
+	for _, fileName := range fileNames[1:] {
+		for {
+			rel, err := filepath.Rel(dir, fileName)
+			if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	return dir
+}
+
+// BatchProcess represent function processing a single file of a batch, such as (*EODProcessor).Process
+// or (*EODProcessor).ProcessStreamFile.
+type BatchProcess func(e *EODProcessor, ctx context.Context, inputFileName, outputFileName string) (*RunReport, error)
+
+// BatchResult represent the outcome of a single file of a batch.
+type BatchResult struct {
+	// Input is the file name of the input.
+	Input string `json:"input"`
+	// Output is the file name of the output.
+	Output string `json:"output"`
+	// RunID is the random id of the run, empty if the file failed before it was processed.
+	RunID string `json:"run_id,omitempty"`
+	// ProcessedRows is the amount of input rows processed, including the failed rows.
+	ProcessedRows int `json:"processed_rows"`
+	// FailedRows is the amount of rows that failed.
+	FailedRows int `json:"failed_rows"`
+	// Error is the error message of the file, empty if the file succeeded.
+	Error string `json:"error,omitempty"`
+
+	// Report is the report of the run, nil if the file failed before it was processed.
+	Report *RunReport `json:"-"`
+	// Err is the error of the file, nil if the file succeeded.
+	Err error `json:"-"`
+}
+
+// BatchReport represent the combined outcome of every file of a batch.
+type BatchReport struct {
+	// Files list the outcome of every file in the batch order.
+	Files []BatchResult `json:"files"`
+	// Succeeded is the amount of files that succeeded.
+	Succeeded int `json:"succeeded"`
+	// Failed is the amount of files that failed.
+	Failed int `json:"failed"`
+	// Reconciliation is the combined totals of the files that succeeded.
+	// Missing ids are prefixed with the input file name.
+	Reconciliation Reconciliation `json:"reconciliation"`
+}
+
+// ProcessBatch will process every given file using given process function, at most given concurrency files at a time.
+// Every file share the pipeline of the processor, but is written into its own output, reject and reconciliation file.
+// A file failing doesn't stop the other files, its error is recorded on the report instead.
+// The ledger is checked per input file, so files of the same business date don't refuse each other.
+// Concurrency lower than one is treated as one.
+func (e *EODProcessor) ProcessBatch(ctx context.Context, files []BatchFile, concurrency int, process BatchProcess) *BatchReport {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]BatchResult, len(files))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for idx, file := range files {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(idx int, file BatchFile) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			results[idx] = e.processBatchFile(ctx, file, process)
+		}(idx, file)
+	}
+	wg.Wait()
+
+	report := &BatchReport{
+		Files: results,
+	}
+	report.Reconciliation.Adjustments = []RuleAdjustment{}
+	report.Reconciliation.MissingIDs = []string{}
+	for _, result := range results {
+		if result.Err != nil {
+			report.Failed++
+			continue
+		}
+		report.Succeeded++
+		report.Reconciliation.merge(result.Input, &result.Report.Reconciliation)
+	}
+	return report
+}
+
+// processBatchFile will process given file of a batch on a copy of the processor writing the files of given file.
+func (e *EODProcessor) processBatchFile(ctx context.Context, file BatchFile, process BatchProcess) BatchResult {
+	result := BatchResult{
+		Input:  file.Input,
+		Output: file.Output,
+	}
+	processor := *e
+	processor.rejectFileName = file.Reject
+	processor.reconciliationFileName = file.Reconciliation
+	processor.ledgerPerInput = true
+	if err := os.MkdirAll(filepath.Dir(file.Output), 0755); err != nil {
+		result.Err = fmt.Errorf(`failed to write to provided output file %w`, err)
+	} else {
+		result.Report, result.Err = process(&processor, ctx, file.Input, file.Output)
+	}
+	if result.Report != nil {
+		result.RunID = result.Report.RunID
+		result.ProcessedRows = result.Report.ProcessedRows
+		result.FailedRows = len(result.Report.FailedRows)
+	}
+	if result.Err != nil {
+		result.Error = result.Err.Error()
+	}
+	return result
+}
+
+// Err will return ErrBatchFailed with the amount of failed files if any file failed.
+func (b *BatchReport) Err() error {
+	if b.Failed == 0 {
+		return nil
+	}
+	return fmt.Errorf(`%w, %d of %d files failed`, ErrBatchFailed, b.Failed, len(b.Files))
+}
+
+// WriteText will write the outcome of every file followed by the combined reconciliation as human readable text into given writer.
+func (b *BatchReport) WriteText(output io.Writer) error {
+	lines := []string{}
+	for _, result := range b.Files {
+		if result.Err != nil {
+			lines = append(lines, fmt.Sprintf(`File "%s": failed, %s`, result.Input, result.Error))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf(`File "%s": %d processed, %d failed rows into "%s"`, result.Input, result.ProcessedRows, result.FailedRows, result.Output))
+	}
+	lines = append(lines, fmt.Sprintf("Files: %d succeeded, %d failed", b.Succeeded, b.Failed))
+	if _, err := io.WriteString(output, strings.Join(lines, "\n")+"\n"); err != nil {
+		return err
+	}
+	return b.Reconciliation.WriteText(output)
+}
+
+// WriteJSON will write the batch report as indented JSON into given writer.
+func (b *BatchReport) WriteJSON(output io.Writer) error {
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(b)
+}
+
+// WriteFile will write the batch report into given file name.
+// The file is written as JSON if its extension is .json, otherwise as text.
+func (b *BatchReport) WriteFile(fileName string) error {
+	write := b.WriteText
+	if strings.EqualFold(filepath.Ext(fileName), ".json") {
+		write = b.WriteJSON
+	}
+	if err := writeFileAtomic(fileName, "", write); err != nil {
+		return fmt.Errorf(`failed to write to provided summary file %w`, err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package bankeodprocessor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+// writeBatchInputs will write given content of every given file name relative to given directory.
+func writeBatchInputs(t *testing.T, dir string, inputs map[string]string) {
+	for name, content := range inputs {
+		fileName := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeBatchInputs(t, dir, map[string]string{
+		"001/Before Eod.csv": "",
+		"002/Before Eod.csv": "",
+		"002/Other.csv":      "",
+		"flat/a.csv":         "",
+		"flat/b.csv":         "",
+		"flat/a Reject.csv":  "",
+		"flat/ledger.jsonl":  "",
+	})
+	out := filepath.Join(dir, "out")
+	tests := []struct {
+		name      string
+		pattern   string
+		outputDir string
+		want      []BatchFile
+		wantErr   error
+	}{
+		{
+			"Given glob of inputs of the same name then their directory must be kept",
+			filepath.Join(dir, "*", "Before Eod.csv"),
+			out,
+			[]BatchFile{
+				{filepath.Join(dir, "001", "Before Eod.csv"), filepath.Join(out, "001", "Before Eod.csv"), filepath.Join(out, "001", "Before Eod Reject.csv"), filepath.Join(out, "001", "Before Eod Reconciliation.json")},
+				{filepath.Join(dir, "002", "Before Eod.csv"), filepath.Join(out, "002", "Before Eod.csv"), filepath.Join(out, "002", "Before Eod Reject.csv"), filepath.Join(out, "002", "Before Eod Reconciliation.json")},
+			},
+			nil,
+		},
+		{
+			"Given directory then every file of the input extension inside it must be an input",
+			filepath.Join(dir, "flat"),
+			out,
+			[]BatchFile{
+				{filepath.Join(dir, "flat", "a.csv"), filepath.Join(out, "a.csv"), filepath.Join(out, "a Reject.csv"), filepath.Join(out, "a Reconciliation.json")},
+				{filepath.Join(dir, "flat", "b.csv"), filepath.Join(out, "b.csv"), filepath.Join(out, "b Reject.csv"), filepath.Join(out, "b Reconciliation.json")},
+			},
+			nil,
+		},
+		{"Given no matching file then it must fail", filepath.Join(dir, "*.json"), out, nil, ErrEmptyBatch},
+		{"Given output directory of the inputs then it must fail", filepath.Join(dir, "flat"), filepath.Join(dir, "flat"), nil, ErrBatchOutputIsInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BatchFiles(tt.pattern, tt.outputDir, []string{".csv"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("BatchFiles() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BatchFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEODProcessor_ProcessBatch(t *testing.T) {
+	dir := t.TempDir()
+	writeBatchInputs(t, dir, map[string]string{
+		"001/Before Eod.csv": "id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer\n1;Test 1;24;151;100;100;3\n2;Test 2;25;150;150;100;2\n",
+		"002/Before Eod.csv": "id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer\n1;Test 1;24;100;100;100;3\n",
+		"003/Before Eod.csv": "id;Nama\n1;Test 1\n",
+	})
+	files, err := BatchFiles(filepath.Join(dir, "*", "Before Eod.csv"), filepath.Join(dir, "out"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	averageCalculator := pipeline.NewAverageCalculator(nil)
+	parser := NewParser(averageCalculator.Channel())
+	eodCalculator := NewEODProcessor(
+		pipeline.NewChain(parser, averageCalculator),
+		WithLedger(NewLedger(filepath.Join(dir, "ledger.jsonl"))),
+		WithBusinessDate(time.Date(2022, 10, 1, 0, 0, 0, 0, time.Local)),
+		WithIDTracking(true),
+	)
+	defer eodCalculator.Close()
+
+	report := eodCalculator.ProcessBatch(context.Background(), files, 2, (*EODProcessor).ProcessStreamFile)
+	if report.Succeeded != 2 || report.Failed != 1 {
+		t.Fatalf("EODProcessor.ProcessBatch() succeeded = %v, failed = %v, want 2 and 1", report.Succeeded, report.Failed)
+	}
+	if !errors.Is(report.Err(), ErrBatchFailed) {
+		t.Errorf("BatchReport.Err() = %v, want %v", report.Err(), ErrBatchFailed)
+	}
+	if report.Files[2].Err == nil || report.Files[2].Input != files[2].Input {
+		t.Errorf("EODProcessor.ProcessBatch() result = %+v, want failure of %v", report.Files[2], files[2].Input)
+	}
+	for _, file := range files[:2] {
+		for _, fileName := range []string{file.Output, file.Reconciliation} {
+			if _, err := os.Stat(fileName); err != nil {
+				t.Errorf("EODProcessor.ProcessBatch() file %v error = %v, want written", fileName, err)
+			}
+		}
+		// No row is rejected so no reject file is written.
+		if _, err := os.Stat(file.Reject); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("EODProcessor.ProcessBatch() file %v error = %v, want not written", file.Reject, err)
+		}
+	}
+	if report.Reconciliation.InputRows != 3 || report.Reconciliation.ProcessedRows != 3 {
+		t.Errorf("EODProcessor.ProcessBatch() reconciliation = %+v, want 3 input and processed rows", report.Reconciliation)
+	}
+
+	// Processed files are refused by the ledger while the failed file is retried.
+	report = eodCalculator.ProcessBatch(context.Background(), files, 2, (*EODProcessor).ProcessStreamFile)
+	for idx, result := range report.Files[:2] {
+		if !errors.Is(result.Err, ErrAlreadyProcessed) {
+			t.Errorf("EODProcessor.ProcessBatch() error of file %v = %v, want %v", idx, result.Err, ErrAlreadyProcessed)
+		}
+	}
+	if errors.Is(report.Files[2].Err, ErrAlreadyProcessed) {
+		t.Errorf("EODProcessor.ProcessBatch() failed file must not be recorded in the ledger")
+	}
+}
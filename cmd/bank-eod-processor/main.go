@@ -2,37 +2,377 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io"
 	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	bankeodprocessor "github.com/firmanmm/bank-eod-processor"
+	"github.com/firmanmm/bank-eod-processor/format"
+	"github.com/firmanmm/bank-eod-processor/money"
 	"github.com/firmanmm/bank-eod-processor/pipeline"
 )
 
 const (
 	defaultInputFile  = "Before Eod.csv"
 	defaultOutputFile = "After Eod.csv"
+	defaultLedgerFile = "Eod Ledger.jsonl"
+	// defaultBatchOutputDir is the default output directory of a batch.
+	defaultBatchOutputDir = "After Eod"
+	// batchSummaryFile is the default name of the combined summary of a batch inside its output directory.
+	batchSummaryFile = "Summary.json"
+	// batchAuditFile is the default name of the audit log of a batch inside its output directory.
+	batchAuditFile = "Eod Audit.jsonl"
 )
 
 func main() {
-	inputFlag := flag.String("input", defaultInputFile, "File name to be used as input (required)")
-	outputFlag := flag.String("output", defaultOutputFile, "File name to be used as an output (optional)")
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		verify(os.Args[2:])
+		return
+	}
+	inputFlag := flag.String("input", defaultInputFile, "File name to be used as input, a directory or glob process every matching file as a batch (required)")
+	outputFlag := flag.String("output", "", "File name to be used as an output, or the output directory of a batch, default to "+defaultOutputFile+" or "+defaultBatchOutputDir+" for a batch (optional)")
+	rejectFlag := flag.String("reject", "", "File name to be used to write rejected rows, only written if a row is rejected, default to output name suffixed with Reject (optional)")
+	auditFlag := flag.String("audit", "", "File name of the append-only audit log of every change applied to the accounts, default to output name suffixed with Audit.jsonl (optional)")
+	reconciliationFlag := flag.String("reconciliation", "", "File name to be used to write the reconciliation of the run, as JSON if it ends with .json or as text otherwise, default to output name suffixed with Reconciliation.json (optional)")
+	dryRunFlag := flag.Bool("dry-run", false, "Process and print the changes against the output without writing any file, can't be streamed (optional)")
+	streamFlag := flag.Bool("stream", false, "Process the input row by row without loading it into memory, output is not used as template and can't be columnar (optional)")
+	batchConcurrencyFlag := flag.Int("batch-concurrency", 4, "Maximum number of files of a batch processed at the same time (optional)")
+	summaryFlag := flag.String("summary", "", "File name of the combined summary of a batch, as JSON if it ends with .json or as text otherwise, default to "+batchSummaryFile+" inside the output directory (optional)")
+	shardsFlag := flag.Int("shards", 0, "Split the input into the given amount of shards processed concurrently by their own pipeline, output is not used as template, zero to disable (optional)")
+	duplicatePolicyFlag := flag.String("duplicate-policy", "", "Handling of rows sharing the same account id, one of reject-run, keep-first, keep-last, merge or ignore, merge can't be streamed or sharded, ignore process every row and is only for streaming or sharding, the others need -track-ids to be streamed or sharded, default to reject-run, or ignore when streaming or sharding without -track-ids (optional)")
+	outputOrderFlag := flag.String("output-order", string(bankeodprocessor.OutputOrderInput), "Order of the output rows, one of input or id, id can't be streamed (optional)")
+	maxFailedRowsFlag := flag.Int("max-failed-rows", -1, "Maximum number of failed rows before the run is failed, negative to never fail (optional)")
+	benefitRulesFlag := flag.String("benefit-rules", "", "JSON file name of the benefit rules, default rules are used if not provided (optional)")
+	validationRulesFlag := flag.String("validation-rules", "", "JSON file name of the validation rules, default rules are used if not provided (optional)")
+	bonusConfigFlag := flag.String("bonus-config", "", "JSON file name of the bonus config, default config is used if not provided (optional)")
+	pipelineFlag := flag.String("pipeline", "", "JSON file name of the ordered pipeline stages with their params and parallelism, default pipeline is used if not provided (optional)")
+	columnAliasesFlag := flag.String("column-aliases", "", "JSON file name of the accepted alternative names of each column, default aliases are used if not provided (optional)")
+	inputSchemaFlag := flag.Int("input-schema", 0, "Schema version of the input, detected from the input header if zero (optional)")
+	outputSchemaFlag := flag.Int("output-schema", 0, "Schema version of the output, follow the output template or the input if zero (optional)")
+	currencyFlag := flag.String("currency", "", "Currency of the run, input rows of another currency are rejected, any currency is accepted if empty (optional)")
+	roundingFlag := flag.String("rounding", "", "Rounding mode of the average balanced, one of half-even, half-up or down, default to half-even (optional)")
+	businessDateFlag := flag.String("business-date", "", "Business date of the run formatted as YYYY-MM-DD, default to the current date (optional)")
+	ledgerFlag := flag.String("ledger", defaultLedgerFile, "File name of the ledger used to refuse processing the same business date or input twice, empty to disable (optional)")
+	forceFlag := flag.Bool("force", false, "Process even if the business date or input has already been processed (optional)")
+	backupFlag := flag.Bool("backup", false, "Keep the previous output file suffixed with .bak before replacing it (optional)")
+	formatNames := strings.Join(format.Names(), ", ")
+	inputFormatFlag := flag.String("input-format", format.NameSemicolon, "Format of the input, one of "+formatNames+" (optional)")
+	outputFormatFlag := flag.String("output-format", format.NameSemicolon, "Format of the output and its template, one of "+formatNames+" (optional)")
+	inputWidthsFlag := flag.String("input-widths", "", "Comma separated width of each input column when the input format is fixed-width (optional)")
+	outputWidthsFlag := flag.String("output-widths", "", "Comma separated width of each output column when the output format is fixed-width (optional)")
+	timeoutFlag := flag.Duration("timeout", 0, "Maximum duration of the processing, no limit if zero (optional)")
+	trackIDsFlag := flag.Bool("track-ids", false, "Keep every account id in memory when streaming or sharding to detect duplicated account id (optional)")
 	flag.Parse()
 	input := *inputFlag
 	output := *outputFlag
 	if len(input) == 0 {
 		log.Fatalln("Input can't be empty")
 	}
+	batch := isBatchInput(input)
 	// output is optional and will default output name if not provided.
 	if len(output) == 0 {
 		output = defaultOutputFile
+		if batch {
+			output = defaultBatchOutputDir
+		}
+	}
+	if batch && *dryRunFlag {
+		log.Fatalln("Dry run can't be used on a batch")
+	}
+	if batch && (len(*rejectFlag) > 0 || len(*reconciliationFlag) > 0) {
+		log.Fatalln("Reject and reconciliation file are named after each input on a batch")
+	}
+	if *dryRunFlag && *streamFlag {
+		log.Fatalln("Dry run can't be streamed")
+	}
+	if *shardsFlag > 0 && (*dryRunFlag || *streamFlag) {
+		log.Fatalln("Sharding can't be used with dry run or streaming")
+	}
+	if (*streamFlag || *shardsFlag > 0) && *outputFormatFlag == format.NameColumnar {
+		log.Fatalln("Columnar output buffer every row so it can't be streamed or sharded")
+	}
+	reject := *rejectFlag
+	// reject is optional and will be placed next to the output if not provided.
+	if len(reject) == 0 {
+		extension := filepath.Ext(output)
+		reject = strings.TrimSuffix(output, extension) + " Reject" + extension
+	}
+	audit := *auditFlag
+	// audit is optional and will be placed next to the output if not provided.
+	if len(audit) == 0 {
+		audit = auditFileName(output)
+		if batch {
+			audit = filepath.Join(output, batchAuditFile)
+		}
+	}
+	reconciliation := *reconciliationFlag
+	// reconciliation is optional and will be placed next to the output if not provided.
+	if len(reconciliation) == 0 {
+		reconciliation = strings.TrimSuffix(output, filepath.Ext(output)) + " Reconciliation.json"
+	}
+	pipelineConfig := bankeodprocessor.DefaultPipelineConfig()
+	var err error
+	if len(*pipelineFlag) > 0 {
+		pipelineConfig, err = pipeline.LoadPipelineConfig(*pipelineFlag)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	// Stage specific flags are used by the stages that have no params in the pipeline config.
+	if len(*roundingFlag) > 0 {
+		if _, err := money.ParseRoundingMode(*roundingFlag); err != nil {
+			log.Fatalln(err)
+		}
+		params, _ := json.Marshal(map[string]string{"rounding": *roundingFlag})
+		pipelineConfig.SetDefaultParams(pipeline.AverageCalculatorStageName, params)
+	}
+	stageParamsFlags := map[string]string{
+		pipeline.BenefitCalculatorStageName: *benefitRulesFlag,
+		pipeline.ValidatorStageName:         *validationRulesFlag,
+		pipeline.BonusDistributorStageName:  *bonusConfigFlag,
+	}
+	for stageName, fileName := range stageParamsFlags {
+		if len(fileName) == 0 {
+			continue
+		}
+		params, err := os.ReadFile(fileName)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		pipelineConfig.SetDefaultParams(stageName, params)
+	}
+	columnAliases := bankeodprocessor.DefaultColumnAliases()
+	if len(*columnAliasesFlag) > 0 {
+		columnAliases, err = bankeodprocessor.LoadColumnAliases(*columnAliasesFlag)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	duplicatePolicyName := *duplicatePolicyFlag
+	// duplicatePolicyName is optional and will follow the processing mode if not provided.
+	if len(duplicatePolicyName) == 0 {
+		duplicatePolicyName = string(defaultDuplicatePolicy(*streamFlag || *shardsFlag > 0, *trackIDsFlag))
+	}
+	duplicatePolicy, err := bankeodprocessor.ParseDuplicatePolicy(duplicatePolicyName)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	outputOrder, err := bankeodprocessor.ParseOutputOrder(*outputOrderFlag)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	businessDate := time.Now()
+	if len(*businessDateFlag) > 0 {
+		businessDate, err = time.ParseInLocation(bankeodprocessor.BusinessDateLayout, *businessDateFlag, time.Local)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	inputFormat, err := parseFormat(*inputFormatFlag, *inputWidthsFlag)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	outputFormat, err := parseFormat(*outputFormatFlag, *outputWidthsFlag)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	options := []bankeodprocessor.Option{
+		bankeodprocessor.WithInputFormat(inputFormat),
+		bankeodprocessor.WithOutputFormat(outputFormat),
+		bankeodprocessor.WithColumnAliases(columnAliases),
+		bankeodprocessor.WithDuplicatePolicy(duplicatePolicy),
+		bankeodprocessor.WithOutputOrder(outputOrder),
+		bankeodprocessor.WithIDTracking(*trackIDsFlag),
+		bankeodprocessor.WithErrorPolicy(bankeodprocessor.ErrorPolicyThreshold(*maxFailedRowsFlag)),
+		bankeodprocessor.WithRejectFile(reject),
+		bankeodprocessor.WithReconciliationFile(reconciliation),
+		bankeodprocessor.WithAuditLog(bankeodprocessor.NewAuditLog(audit)),
+		bankeodprocessor.WithBusinessDate(businessDate),
+		bankeodprocessor.WithForce(*forceFlag),
+		bankeodprocessor.WithBackup(*backupFlag),
+		bankeodprocessor.WithCurrency(*currencyFlag),
+	}
+	if *inputSchemaFlag != 0 {
+		inputSchema, err := bankeodprocessor.InputSchema(*inputSchemaFlag)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		options = append(options, bankeodprocessor.WithInputSchema(inputSchema))
+	}
+	if *outputSchemaFlag != 0 {
+		outputSchema, err := bankeodprocessor.OutputSchema(*outputSchemaFlag)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		options = append(options, bankeodprocessor.WithOutputSchema(outputSchema))
+	}
+	if len(*ledgerFlag) > 0 {
+		options = append(options, bankeodprocessor.WithLedger(bankeodprocessor.NewLedger(*ledgerFlag)))
+	}
+	if *shardsFlag > 0 {
+		options = append(options, bankeodprocessor.WithShards(*shardsFlag, func() (pipeline.IPipeline, error) {
+			return pipeline.BuildPipeline(pipelineConfig)
+		}))
+	}
+	chain, err := pipeline.BuildPipeline(pipelineConfig)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	eodCalculator := bankeodprocessor.NewEODProcessor(chain, options...)
+	// Stop the processing on termination signal from the scheduler.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *timeoutFlag > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeoutFlag)
+		defer cancel()
+	}
+	if *dryRunFlag {
+		diff, report, err := eodCalculator.DryRun(ctx, input, output)
+		eodCalculator.Close()
+		if report != nil {
+			printReport(os.Stdout, report)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err := diff.WriteText(os.Stdout); err != nil {
+			log.Fatalln(err)
+		}
+		log.Println("Dry run, no file has been written")
+		return
+	}
+	process := (*bankeodprocessor.EODProcessor).Process
+	if *streamFlag {
+		process = (*bankeodprocessor.EODProcessor).ProcessStreamFile
+	}
+	if *shardsFlag > 0 {
+		process = (*bankeodprocessor.EODProcessor).ProcessShardedFile
 	}
-	bonusDistributor := pipeline.NewBonusDistributor(nil)
-	benefitCalculator := pipeline.NewBenefitCalculator(bonusDistributor.Channel())
-	averageCalculator := pipeline.NewAverageCalculator(benefitCalculator.Channel())
-	parser := bankeodprocessor.NewParser(averageCalculator.Channel())
-	eodCalculator := bankeodprocessor.NewEODProcessor(parser)
-	if err := eodCalculator.Process(context.Background(), input, output); err != nil {
+	if batch {
+		runBatch(ctx, eodCalculator, input, output, format.Extensions(inputFormat), *summaryFlag, *batchConcurrencyFlag, process)
+		return
+	}
+	report, err := process(eodCalculator, ctx, input, output)
+	eodCalculator.Close()
+	if report != nil {
+		printReport(log.Writer(), report)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// isBatchInput will return true if given input is a directory or a glob pattern.
+func isBatchInput(input string) bool {
+	if info, err := os.Stat(input); err == nil {
+		return info.IsDir()
+	}
+	return strings.ContainsAny(input, "*?[")
+}
+
+// runBatch will process every file of given input directory or glob into given output directory using given process function,
+// then write and print the combined summary. Files of a directory are filtered by given extensions of the input format.
+// Will exit with failure if any file failed.
+func runBatch(ctx context.Context, eodCalculator *bankeodprocessor.EODProcessor, input, outputDir string, extensions []string, summary string, concurrency int, process bankeodprocessor.BatchProcess) {
+	files, err := bankeodprocessor.BatchFiles(input, outputDir, extensions)
+	if err != nil {
+		eodCalculator.Close()
 		log.Fatalln(err)
 	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		eodCalculator.Close()
+		log.Fatalln(err)
+	}
+	report := eodCalculator.ProcessBatch(ctx, files, concurrency, process)
+	eodCalculator.Close()
+	if len(summary) == 0 {
+		summary = filepath.Join(outputDir, batchSummaryFile)
+	}
+	if err := report.WriteFile(summary); err != nil {
+		log.Println(err)
+	}
+	if err := report.WriteText(log.Writer()); err != nil {
+		log.Println(err)
+	}
+	if err := report.Err(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// verify will verify the hash chain of the audit log given the arguments of the verify subcommand.
+// The heads recorded in the ledger are also verified if the ledger is configured.
+// Will exit with the first broken entry if the chain is broken.
+func verify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	auditFlag := flags.String("audit", auditFileName(defaultOutputFile), "File name of the audit log to be verified (optional)")
+	ledgerFlag := flags.String("ledger", defaultLedgerFile, "File name of the ledger holding the audit head of every run, empty to only verify the chain (optional)")
+	flags.Parse(args)
+	var ledger *bankeodprocessor.Ledger
+	if len(*ledgerFlag) > 0 {
+		ledger = bankeodprocessor.NewLedger(*ledgerFlag)
+	}
+	verified, err := bankeodprocessor.NewAuditLog(*auditFlag).Verify(ledger)
+	if err != nil {
+		log.Fatalf("%v, %d entries verified before it\n", err, verified)
+	}
+	log.Printf("Audit log %s is intact, %d entries verified\n", *auditFlag, verified)
+}
+
+// auditFileName will return the default audit file name placed next to given output file name.
+func auditFileName(output string) string {
+	return strings.TrimSuffix(output, filepath.Ext(output)) + " Audit.jsonl"
+}
+
+// defaultDuplicatePolicy will return the duplicate policy used when none is provided.
+// Detecting duplicates of a streamed or sharded run keep every account id in memory,
+// so every row is processed without looking for duplicates unless ids are tracked.
+func defaultDuplicatePolicy(streamed, trackIDs bool) bankeodprocessor.DuplicatePolicy {
+	if streamed && !trackIDs {
+		return bankeodprocessor.DuplicatePolicyIgnore
+	}
+	return bankeodprocessor.DuplicatePolicyRejectRun
+}
+
+// parseFormat will return the format given its name.
+// Given comma separated widths are used if the format is fixed-width.
+func parseFormat(name, widths string) (format.Format, error) {
+	if name != format.NameFixedWidth || len(widths) == 0 {
+		return format.ByName(name)
+	}
+	columnWidths := []int{}
+	for _, width := range strings.Split(widths, ",") {
+		columnWidth, err := strconv.Atoi(strings.TrimSpace(width))
+		if err != nil || columnWidth <= 0 {
+			return nil, fmt.Errorf(`invalid column width "%s"`, width)
+		}
+		columnWidths = append(columnWidths, columnWidth)
+	}
+	return format.NewFixedWidth(columnWidths), nil
+}
+
+// printReport will print given run report into given output.
+func printReport(output io.Writer, report *bankeodprocessor.RunReport) {
+	fmt.Fprintf(output, "Processed %d rows, %d rows failed, %d warnings, %d duplicate ids\n", report.ProcessedRows, len(report.FailedRows), len(report.Warnings), len(report.Duplicates))
+	if err := report.Reconciliation.WriteText(output); err != nil {
+		log.Println(err)
+	}
+	for _, duplicate := range report.Duplicates {
+		fmt.Fprintln(output, duplicate.String())
+	}
+	for _, warning := range report.Warnings {
+		fmt.Fprintln(output, warning.String())
+	}
+	for _, failedRow := range report.FailedRows {
+		fmt.Fprintln(output, failedRow.Error())
+	}
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// mainEnv is the environment variable making the test binary run main instead of the tests.
+const mainEnv = "BANK_EOD_PROCESSOR_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(mainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain will run main with given arguments inside given directory.
+// Will return the combined output of the run.
+func runMain(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), mainEnv+"=1")
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+func TestMain_DefaultFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"Given no flag then it must succeed", nil},
+		{"Given stream flag only then it must succeed", []string{"-stream"}},
+		{"Given shards flag only then it must succeed", []string{"-shards", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := "id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer\n1;Test 1;24;151;100;100;3\n2;Test 2;25;150;150;100;2\n"
+			if err := os.WriteFile(filepath.Join(dir, defaultInputFile), []byte(input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if output, err := runMain(t, dir, tt.args...); err != nil {
+				t.Fatalf("main() error = %v, want nil, output:\n%s", err, output)
+			}
+			if _, err := os.Stat(filepath.Join(dir, defaultOutputFile)); err != nil {
+				t.Errorf("main() output error = %v, want written", err)
+			}
+		})
+	}
+}
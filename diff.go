@@ -0,0 +1,157 @@
+package bankeodprocessor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	// diffIgnoredColumns is the columns left out of the diff since their value depend on scheduling.
+	diffIgnoredColumns = map[string]bool{
+		afterEodCSVHeader[afterEodHeaderIdxNo1Thread]:  true,
+		afterEodCSVHeader[afterEodHeaderIdxNo2AThread]: true,
+		afterEodCSVHeader[afterEodHeaderIdxNo2BThread]: true,
+		afterEodCSVHeader[afterEodHeaderIdxNo3Thread]:  true,
+	}
+)
+
+// CellChange represent a cell of the output which value differ from the output template.
+type CellChange struct {
+	// ID is the account id of the row.
+	ID string
+	// Column is the name of the column.
+	Column string
+	// Old is the value in the output template, empty if the template doesn't have the column.
+	Old string
+	// New is the value in the output.
+	New string
+}
+
+// String will return the description of the cell change.
+func (c CellChange) String() string {
+	return fmt.Sprintf(`id "%s" column "%s" changed from "%s" to "%s"`, c.ID, c.Column, c.Old, c.New)
+}
+
+// OutputDiff represent the difference between the output template and the output of a run.
+// Rows are matched by account id and cells by column name, the thread number columns are ignored.
+type OutputDiff struct {
+	// AddedRows is the account id of the output rows that aren't in the template in output order.
+	AddedRows []string
+	// RemovedRows is the account id of the template rows that aren't in the output in template order.
+	RemovedRows []string
+	// ChangedRows is the amount of rows with at least one changed cell.
+	ChangedRows int
+	// UnchangedRows is the amount of rows of the template kept without any changed cell.
+	UnchangedRows int
+	// Cells is every changed cell in output order.
+	Cells []CellChange
+}
+
+// WriteText will write the summary of the diff followed by every added row, removed row and changed cell
+// as human readable text into given writer.
+func (d *OutputDiff) WriteText(output io.Writer) error {
+	lines := []string{
+		fmt.Sprintf("Diff: %d rows added, %d rows removed, %d rows changed, %d rows unchanged, %d cells changed",
+			len(d.AddedRows), len(d.RemovedRows), d.ChangedRows, d.UnchangedRows, len(d.Cells)),
+	}
+	for _, id := range d.AddedRows {
+		lines = append(lines, fmt.Sprintf(`id "%s" added`, id))
+	}
+	for _, id := range d.RemovedRows {
+		lines = append(lines, fmt.Sprintf(`id "%s" removed`, id))
+	}
+	for _, cell := range d.Cells {
+		lines = append(lines, cell.String())
+	}
+	_, err := io.WriteString(output, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// DryRun will process from given input and output file name like Process without writing any file.
+// The output file is only read as template and the ledger, the audit log, the reject file and
+// the reconciliation file are left untouched.
+// Will return ErrAlreadyProcessed like Process if the ledger is configured and the run has already been processed,
+// so the dry run show the changes of a run that would be accepted.
+// Will return the difference between the output template and the output that would be written,
+// with the report of the run. The report is also returned when the run fail because of its error policy.
+func (e *EODProcessor) DryRun(ctx context.Context, inputFileName, outputFileName string) (*OutputDiff, *RunReport, error) {
+	if _, err := e.checkLedger(inputFileName, outputFileName); err != nil {
+		return nil, nil, err
+	}
+	templateRows, err := e.readTemplate(outputFileName, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, report, err := e.ProcessFile(ctx, inputFileName, outputFileName)
+	if err != nil {
+		return nil, report, err
+	}
+	_, templateMapping, err := detectSchema(outputSchemas, nil, templateRows[0], e.columnAliases)
+	if err != nil {
+		return nil, report, fmt.Errorf("failed to validate output header, %w", err)
+	}
+	return diffRows(templateMapping.applyAll(templateRows), result), report, nil
+}
+
+// diffRows will return the difference between given mapped template rows and output rows.
+// Only the first template row of a duplicated id is compared.
+func diffRows(templateRows, outputRows [][]string) *OutputDiff {
+	templateColumns := make(map[string]int, len(templateRows[0]))
+	for idx, column := range templateRows[0] {
+		templateColumns[column] = idx
+	}
+	templateIDRows := make(map[string][]string, len(templateRows))
+	for _, row := range templateRows[1:] {
+		if _, exist := templateIDRows[row[afterEodHeaderIdxID]]; !exist {
+			templateIDRows[row[afterEodHeaderIdxID]] = row
+		}
+	}
+	diff := &OutputDiff{
+		AddedRows:   []string{},
+		RemovedRows: []string{},
+		Cells:       []CellChange{},
+	}
+	outputIDs := make(map[string]bool, len(outputRows))
+	header := outputRows[0]
+	for _, row := range outputRows[1:] {
+		id := row[afterEodHeaderIdxID]
+		outputIDs[id] = true
+		templateRow, exist := templateIDRows[id]
+		if !exist {
+			diff.AddedRows = append(diff.AddedRows, id)
+			continue
+		}
+		changed := false
+		for idx, column := range header {
+			if diffIgnoredColumns[column] {
+				continue
+			}
+			old := ""
+			if templateIdx, ok := templateColumns[column]; ok {
+				old = templateRow[templateIdx]
+			}
+			if old != row[idx] {
+				changed = true
+				diff.Cells = append(diff.Cells, CellChange{
+					ID:     id,
+					Column: column,
+					Old:    old,
+					New:    row[idx],
+				})
+			}
+		}
+		if changed {
+			diff.ChangedRows++
+		} else {
+			diff.UnchangedRows++
+		}
+	}
+	for _, row := range templateRows[1:] {
+		if id := row[afterEodHeaderIdxID]; !outputIDs[id] {
+			diff.RemovedRows = append(diff.RemovedRows, id)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,135 @@
+package bankeodprocessor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+func TestDiffRows(t *testing.T) {
+	type args struct {
+		templateRows [][]string
+		outputRows   [][]string
+	}
+	tests := []struct {
+		name string
+		args args
+		want *OutputDiff
+	}{
+		{
+			"Given changed cell then it must be listed",
+			args{
+				[][]string{afterEodCSVHeader, {"1", "Test 1", "24", "100", "1", "2", "100", "100", "3", "3", "4"}},
+				[][]string{afterEodCSVHeader, {"1", "Test 1", "24", "125", "5", "6", "100", "112.50", "7", "3", "8"}},
+			},
+			&OutputDiff{
+				AddedRows:   []string{},
+				RemovedRows: []string{},
+				ChangedRows: 1,
+				Cells: []CellChange{
+					{ID: "1", Column: "Balanced", Old: "100", New: "125"},
+					{ID: "1", Column: "Average Balanced", Old: "100", New: "112.50"},
+				},
+			},
+		},
+		{
+			"Given added and removed rows then they must be listed",
+			args{
+				[][]string{afterEodCSVHeader, {"1", "Test 1", "24", "100", "", "", "100", "100", "", "3", ""}},
+				[][]string{afterEodCSVHeader, {"2", "Test 2", "24", "100", "", "", "100", "100", "", "3", ""}},
+			},
+			&OutputDiff{
+				AddedRows:   []string{"2"},
+				RemovedRows: []string{"1"},
+				Cells:       []CellChange{},
+			},
+		},
+		{
+			"Given column missing in the template then it must be compared with empty value",
+			args{
+				[][]string{afterEodCSVHeader, {"1", "Test 1", "24", "100", "", "", "100", "100", "", "3", ""}},
+				[][]string{OutputSchemaV2.Columns, {"1", "Test 1", "24", "100", "", "", "100", "100", "", "3", "", "IDR"}},
+			},
+			&OutputDiff{
+				AddedRows:   []string{},
+				RemovedRows: []string{},
+				ChangedRows: 1,
+				Cells:       []CellChange{{ID: "1", Column: "Currency", Old: "", New: "IDR"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffRows(tt.args.templateRows, tt.args.outputRows); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffRows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEODProcessor_DryRun(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "Before Eod.csv")
+	outputPath := filepath.Join(dir, "After Eod.csv")
+	ledgerPath := filepath.Join(dir, "ledger.jsonl")
+	auditPath := filepath.Join(dir, "audit.jsonl")
+	rejectPath := filepath.Join(dir, "reject.csv")
+	if err := os.WriteFile(inputPath, []byte(`id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer
+1;Test 1;24;151;100;100;3
+2;Test 2;25;BAD;150;100;2`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	template := `id;Nama;Age;Balanced;No 2b Thread-No;No 3 Thread-No;Previous Balanced;Average Balanced;No 1 Thread-No;Free Transfer;No 2a Thread-No
+1;Test 1;24;100;;;100;100;;3;
+`
+	if err := os.WriteFile(outputPath, []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+	averageCalculator := pipeline.NewAverageCalculator(nil)
+	parser := NewParser(averageCalculator.Channel())
+	eodCalculator := NewEODProcessor(
+		pipeline.NewChain(parser, averageCalculator),
+		WithLedger(NewLedger(ledgerPath)),
+		WithAuditLog(NewAuditLog(auditPath)),
+		WithRejectFile(rejectPath),
+	)
+	defer eodCalculator.Close()
+	diff, report, err := eodCalculator.DryRun(context.Background(), inputPath, outputPath)
+	if err != nil {
+		t.Fatalf("EODProcessor.DryRun() error = %v, want nil", err)
+	}
+	if len(report.FailedRows) != 1 {
+		t.Errorf("EODProcessor.DryRun() failed rows = %v, want 1", len(report.FailedRows))
+	}
+	wantCells := []CellChange{
+		{ID: "1", Column: "Balanced", Old: "100", New: "151"},
+		{ID: "1", Column: "Average Balanced", Old: "100", New: "125.50"},
+	}
+	if !reflect.DeepEqual(diff.Cells, wantCells) {
+		t.Errorf("EODProcessor.DryRun() cells = %v, want %v", diff.Cells, wantCells)
+	}
+	if got, _ := os.ReadFile(outputPath); string(got) != template {
+		t.Errorf("EODProcessor.DryRun() output = %v, want untouched template", string(got))
+	}
+	for _, fileName := range []string{ledgerPath, auditPath, rejectPath} {
+		if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+			t.Errorf("EODProcessor.DryRun() must not write %v", fileName)
+		}
+	}
+
+	checksum, err := fileChecksum(inputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewLedger(ledgerPath).Record(&LedgerEntry{BusinessDate: "2022-10-01", Checksum: checksum}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := eodCalculator.DryRun(context.Background(), inputPath, outputPath); !errors.Is(err, ErrAlreadyProcessed) {
+		t.Errorf("EODProcessor.DryRun() error = %v, want %v", err, ErrAlreadyProcessed)
+	}
+}
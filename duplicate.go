@@ -0,0 +1,297 @@
+package bankeodprocessor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
+
+// DuplicatePolicy represent how rows sharing the same account id are handled.
+type DuplicatePolicy string
+
+const (
+	// DuplicatePolicyRejectRun fail the run if any account id is duplicated.
+	DuplicatePolicyRejectRun DuplicatePolicy = "reject-run"
+	// DuplicatePolicyKeepFirst keep the first row of a duplicated account id and skip the others.
+	DuplicatePolicyKeepFirst DuplicatePolicy = "keep-first"
+	// DuplicatePolicyKeepLast keep the last row of a duplicated account id and skip the others.
+	DuplicatePolicyKeepLast DuplicatePolicy = "keep-last"
+	// DuplicatePolicyMerge merge the input rows of a duplicated account id into its first row.
+	// Balanced, previous balanced and free transfer are summed while the other columns take the first non empty value.
+	// Average balanced is not a sum of the rows, so it also take the first non empty value.
+	// Output template rows are not merged and keep their first row instead.
+	DuplicatePolicyMerge DuplicatePolicy = "merge"
+	// DuplicatePolicyIgnore process every row without looking for duplicated account id, so no id is kept in memory.
+	// It only apply to streamed or sharded run, where every row is written into its own output row.
+	DuplicatePolicyIgnore DuplicatePolicy = "ignore"
+
+	// DuplicateSourceInput is the source of a duplicate id found in the input.
+	DuplicateSourceInput = "input"
+	// DuplicateSourceTemplate is the source of a duplicate id found in the output template.
+	DuplicateSourceTemplate = "template"
+
+	// DuplicateStageName is the name of the stage used on failed row report of rows that can't be merged.
+	DuplicateStageName = "duplicate"
+)
+
+var (
+	duplicatePolicies = []DuplicatePolicy{
+		DuplicatePolicyRejectRun, DuplicatePolicyKeepFirst, DuplicatePolicyKeepLast, DuplicatePolicyMerge, DuplicatePolicyIgnore,
+	}
+
+	ErrDuplicateID                  = errors.New("duplicate account id")
+	ErrInvalidDuplicatePolicy       = errors.New("invalid duplicate policy provided")
+	ErrDuplicatePolicyNotStreamable = errors.New("duplicate policy can't be used in streaming mode")
+)
+
+// ParseDuplicatePolicy will return the duplicate policy given its name.
+// Valid names are "reject-run", "keep-first", "keep-last", "merge" and "ignore".
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, error) {
+	for _, policy := range duplicatePolicies {
+		if string(policy) == name {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf(`%w "%s"`, ErrInvalidDuplicatePolicy, name)
+}
+
+// DuplicateID represent an account id that appear more than once in the input or the output template.
+type DuplicateID struct {
+	// ID is the duplicated account id.
+	ID string
+	// Source is where the duplicate is found, either DuplicateSourceInput or DuplicateSourceTemplate.
+	Source string
+	// Lines is the line number of every row of the id in ascending order, the header is line 1.
+	Lines []int
+	// KeptLine is the line number of the row that is kept, or the row the others are merged into.
+	// Zero if no row is kept.
+	KeptLine int
+}
+
+// String will return the description of the duplicate id.
+func (d DuplicateID) String() string {
+	lines := make([]string, len(d.Lines))
+	for idx, line := range d.Lines {
+		lines[idx] = strconv.Itoa(line)
+	}
+	description := fmt.Sprintf(`id "%s" appear in %s at lines %s`, d.ID, d.Source, strings.Join(lines, ", "))
+	if d.KeptLine > 0 {
+		description += fmt.Sprintf(", line %d is kept", d.KeptLine)
+	}
+	return description
+}
+
+// duplicateFilter will decide which row of a duplicated id is kept following a duplicate policy
+// and record the line numbers of every duplicated id.
+// Rows must be given in order.
+type duplicateFilter struct {
+	policy      DuplicatePolicy
+	source      string
+	lastIndexes map[string]int
+	firstLines  map[string]int
+	duplicates  map[string]*DuplicateID
+	order       []string
+}
+
+// newDuplicateFilter will return a new duplicateFilter of given policy and source.
+// Given last indexes map every id to the index of its last row and is only used by DuplicatePolicyKeepLast.
+func newDuplicateFilter(policy DuplicatePolicy, source string, lastIndexes map[string]int) *duplicateFilter {
+	return &duplicateFilter{
+		policy:      policy,
+		source:      source,
+		lastIndexes: lastIndexes,
+		firstLines:  make(map[string]int),
+		duplicates:  make(map[string]*DuplicateID),
+	}
+}
+
+// skip will record the row of given index and id and return true if the row must be skipped.
+// Only the first row is kept unless the policy is DuplicatePolicyKeepLast.
+// Every row is kept without being recorded if the policy is DuplicatePolicyIgnore.
+func (f *duplicateFilter) skip(index int, id string) bool {
+	if f.policy == DuplicatePolicyIgnore {
+		return false
+	}
+	line := index + 2
+	firstLine, seen := f.firstLines[id]
+	if !seen {
+		f.firstLines[id] = line
+	} else if duplicate, exist := f.duplicates[id]; exist {
+		duplicate.Lines = append(duplicate.Lines, line)
+	} else {
+		f.duplicates[id] = &DuplicateID{
+			ID:     id,
+			Source: f.source,
+			Lines:  []int{firstLine, line},
+		}
+		f.order = append(f.order, id)
+	}
+	if f.policy == DuplicatePolicyKeepLast {
+		return f.lastIndexes[id] != index
+	}
+	return seen
+}
+
+// result will return every duplicated id recorded in order of their first row.
+func (f *duplicateFilter) result() []DuplicateID {
+	result := make([]DuplicateID, 0, len(f.order))
+	for _, id := range f.order {
+		duplicate := *f.duplicates[id]
+		switch f.policy {
+		case DuplicatePolicyKeepFirst, DuplicatePolicyMerge:
+			duplicate.KeptLine = duplicate.Lines[0]
+		case DuplicatePolicyKeepLast:
+			duplicate.KeptLine = f.lastIndexes[id] + 2
+		}
+		result = append(result, duplicate)
+	}
+	return result
+}
+
+// lastIndexes will return the index of the last row of every id given rows without header and the id column.
+func lastIndexes(rows [][]string, idColumn int) map[string]int {
+	indexes := make(map[string]int, len(rows))
+	for idx, row := range rows {
+		indexes[row[idColumn]] = idx
+	}
+	return indexes
+}
+
+// duplicateError will return the error listing given duplicated ids.
+func duplicateError(duplicates []DuplicateID) error {
+	descriptions := make([]string, len(duplicates))
+	for idx, duplicate := range duplicates {
+		descriptions[idx] = duplicate.String()
+	}
+	return fmt.Errorf("%w, %s", ErrDuplicateID, strings.Join(descriptions, ", "))
+}
+
+// resolveDuplicates will apply the duplicate policy on given mapped input and output rows.
+// Merged rows replace the first row of their id inside the input rows.
+// Will return the index of the input rows to skip, the output rows without the skipped rows,
+// every duplicated id and the input rows rejected because they can't be merged.
+// Will return ErrDuplicateID if the policy is DuplicatePolicyRejectRun and any id is duplicated,
+// and ErrInvalidDuplicatePolicy if the policy is DuplicatePolicyIgnore since duplicated rows would share the same output row.
+func (e *EODProcessor) resolveDuplicates(inputRows, outputRows [][]string) (map[int]bool, [][]string, []DuplicateID, []RowError, error) {
+	if e.duplicatePolicy == DuplicatePolicyIgnore {
+		return nil, nil, nil, nil, fmt.Errorf(`%w, "%s" only apply to streamed or sharded run`, ErrInvalidDuplicatePolicy, e.duplicatePolicy)
+	}
+	var inputLastIndexes, templateLastIndexes map[string]int
+	if e.duplicatePolicy == DuplicatePolicyKeepLast {
+		inputLastIndexes = lastIndexes(inputRows[1:], int(beforeEodHeaderIdxID))
+		templateLastIndexes = lastIndexes(outputRows[1:], int(afterEodHeaderIdxID))
+	}
+	inputFilter := newDuplicateFilter(e.duplicatePolicy, DuplicateSourceInput, inputLastIndexes)
+	skipped := make(map[int]bool)
+	for idx, row := range inputRows[1:] {
+		if inputFilter.skip(idx, row[beforeEodHeaderIdxID]) {
+			skipped[idx] = true
+		}
+	}
+	templateFilter := newDuplicateFilter(e.duplicatePolicy, DuplicateSourceTemplate, templateLastIndexes)
+	keptOutputRows := outputRows[:1]
+	for idx, row := range outputRows[1:] {
+		if !templateFilter.skip(idx, row[afterEodHeaderIdxID]) {
+			keptOutputRows = append(keptOutputRows, row)
+		}
+	}
+	duplicates := append(inputFilter.result(), templateFilter.result()...)
+	if len(duplicates) == 0 {
+		return skipped, outputRows, nil, nil, nil
+	}
+	if e.duplicatePolicy == DuplicatePolicyRejectRun {
+		return nil, nil, nil, nil, duplicateError(duplicates)
+	}
+	var rejected []RowError
+	if e.duplicatePolicy == DuplicatePolicyMerge {
+		for _, duplicate := range inputFilter.result() {
+			if rowErrors := mergeDuplicate(inputRows, duplicate); len(rowErrors) > 0 {
+				skipped[duplicate.Lines[0]-2] = true
+				rejected = append(rejected, rowErrors...)
+			}
+		}
+	}
+	return skipped, keptOutputRows, duplicates, rejected, nil
+}
+
+// mergeDuplicate will merge every input row of given duplicated id into its first row.
+// Every row of the id is rejected if any of them can't be merged, so no partial balance is processed.
+func mergeDuplicate(inputRows [][]string, duplicate DuplicateID) []RowError {
+	rows := make([][]string, len(duplicate.Lines))
+	for idx, line := range duplicate.Lines {
+		// Line is 1 based and include the header.
+		rows[idx] = inputRows[line-1]
+	}
+	merged, failedIdx, err := mergeRows(rows)
+	if err == nil {
+		inputRows[duplicate.Lines[0]-1] = merged
+		return nil
+	}
+	rowErrors := make([]RowError, len(rows))
+	for idx, row := range rows {
+		rowErrors[idx] = RowError{
+			ID:       duplicate.ID,
+			Line:     duplicate.Lines[idx],
+			Stage:    DuplicateStageName,
+			Err:      fmt.Errorf("%w, line %d can't be merged, %v", ErrDuplicateID, duplicate.Lines[failedIdx], err),
+			InputRow: row,
+		}
+	}
+	return rowErrors
+}
+
+// mergeRows will merge given mapped input rows into a new row.
+// Balanced, previous balanced and free transfer are summed while the other columns, average balanced included,
+// take the first non empty value.
+// Will return the index of the row that can't be merged with the error on fail.
+func mergeRows(rows [][]string) ([]string, int, error) {
+	merged := append([]string{}, rows[0]...)
+	amountColumns := []CSVHeaderInputIndex{
+		beforeEodHeaderIdxBalanced, beforeEodHeaderIdxPreviousBalanced,
+	}
+	for _, column := range amountColumns {
+		sum := money.FromInt(0)
+		for idx, row := range rows {
+			amount, err := money.Parse(row[column])
+			if err != nil {
+				return nil, idx, err
+			}
+			sum = sum.Add(amount)
+		}
+		merged[column] = sum.String()
+	}
+	freeTransfer := 0
+	for idx, row := range rows {
+		value, err := strconv.Atoi(row[beforeEodHeaderIdxFreeTransfer])
+		if err != nil {
+			return nil, idx, err
+		}
+		freeTransfer += value
+	}
+	merged[beforeEodHeaderIdxFreeTransfer] = strconv.Itoa(freeTransfer)
+	for column := range merged {
+		for _, row := range rows[1:] {
+			if len(strings.TrimSpace(merged[column])) > 0 {
+				break
+			}
+			merged[column] = row[column]
+		}
+	}
+	return merged, 0, nil
+}
+
+// checkStreamDuplicatePolicy will return ErrDuplicatePolicyNotStreamable if the duplicate policy can't be used
+// when the input is streamed. Detecting duplicates keep every account id of the input in memory,
+// so it is refused unless id tracking is enabled.
+func (e *EODProcessor) checkStreamDuplicatePolicy() error {
+	switch {
+	case e.duplicatePolicy == DuplicatePolicyMerge:
+		return fmt.Errorf(`%w, "%s" need every row of the input`, ErrDuplicatePolicyNotStreamable, e.duplicatePolicy)
+	case e.duplicatePolicy != DuplicatePolicyIgnore && !e.trackIDs:
+		return fmt.Errorf(`%w, "%s" need every account id of the input in memory, enable id tracking or use "%s"`, ErrDuplicatePolicyNotStreamable, e.duplicatePolicy, DuplicatePolicyIgnore)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package bankeodprocessor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		want    DuplicatePolicy
+		wantErr bool
+	}{
+		{"Given keep-last then it must succeed", "keep-last", DuplicatePolicyKeepLast, false},
+		{"Given merge then it must succeed", "merge", DuplicatePolicyMerge, false},
+		{"Given unknown policy then it must fail", "keep-all", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDuplicatePolicy(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseDuplicatePolicy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseDuplicatePolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeRows(t *testing.T) {
+	tests := []struct {
+		name       string
+		rows       [][]string
+		want       []string
+		wantFailed int
+		wantErr    bool
+	}{
+		{
+			"Given rows of the same id then balances must be summed and average balanced kept from the first row",
+			[][]string{
+				{"1", "Test 1", "24", "100", "50", "75", "3"},
+				{"1", "", "25", "20", "10", "15", "2"},
+			},
+			[]string{"1", "Test 1", "24", "120", "60", "75", "5"},
+			0,
+			false,
+		},
+		{
+			"Given empty column on the first row then it must take the next non empty value",
+			[][]string{
+				{"1", "", "24", "100", "50", "", "3"},
+				{"1", "Test 1b", "25", "20", "10", "15", "2"},
+			},
+			[]string{"1", "Test 1b", "24", "120", "60", "15", "5"},
+			0,
+			false,
+		},
+		{
+			"Given bad balance then it must fail on its row",
+			[][]string{
+				{"1", "Test 1", "24", "100", "50", "75", "3"},
+				{"1", "Test 1b", "25", "BAD", "10", "15", "2"},
+			},
+			nil,
+			1,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, failed, err := mergeRows(tt.rows)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("mergeRows() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if failed != tt.wantFailed {
+				t.Errorf("mergeRows() failed = %v, want %v", failed, tt.wantFailed)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeRows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEODProcessor_ProcessSlice_Duplicate(t *testing.T) {
+	inputHeader := beforeEodCSVHeader
+	outputHeader := afterEodCSVHeader
+	inputRows := [][]string{
+		inputHeader,
+		{"1", "Test 1", "24", "100", "50", "0", "3"},
+		{"2", "Test 2", "30", "10", "10", "0", "1"},
+		{"1", "Test 1b", "25", "20", "10", "0", "2"},
+	}
+	templateRows := [][]string{
+		outputHeader,
+		{"9", "Test 9", "40", "10", "", "", "10", "10", "", "1", ""},
+		{"9", "Test 9b", "41", "11", "", "", "11", "11", "", "1", ""},
+	}
+	type args struct {
+		policy     DuplicatePolicy
+		inputRows  [][]string
+		outputRows [][]string
+	}
+	tests := []struct {
+		name           string
+		args           args
+		want           [][]string
+		wantDuplicates []DuplicateID
+		wantFailed     []int
+		wantErr        error
+	}{
+		{
+			"Given reject-run then it must fail",
+			args{DuplicatePolicyRejectRun, inputRows, [][]string{outputHeader}},
+			nil,
+			nil,
+			nil,
+			ErrDuplicateID,
+		},
+		{
+			"Given keep-first then it must process the first row",
+			args{DuplicatePolicyKeepFirst, inputRows, [][]string{outputHeader}},
+			[][]string{
+				outputHeader,
+				{"1", "Test 1", "24", "100", "0", "0", "50", "75", "", "3", "0"},
+				{"2", "Test 2", "30", "10", "0", "0", "10", "10", "", "1", "0"},
+			},
+			[]DuplicateID{{ID: "1", Source: DuplicateSourceInput, Lines: []int{2, 4}, KeptLine: 2}},
+			nil,
+			nil,
+		},
+		{
+			"Given keep-last then it must process the last row",
+			args{DuplicatePolicyKeepLast, inputRows, templateRows},
+			[][]string{
+				outputHeader,
+				{"9", "Test 9b", "41", "11", "", "", "11", "11", "", "1", ""},
+				{"2", "Test 2", "30", "10", "0", "0", "10", "10", "", "1", "0"},
+				{"1", "Test 1b", "25", "20", "0", "0", "10", "15", "", "2", "0"},
+			},
+			[]DuplicateID{
+				{ID: "1", Source: DuplicateSourceInput, Lines: []int{2, 4}, KeptLine: 4},
+				{ID: "9", Source: DuplicateSourceTemplate, Lines: []int{2, 3}, KeptLine: 3},
+			},
+			nil,
+			nil,
+		},
+		{
+			"Given merge then it must sum the balances",
+			args{DuplicatePolicyMerge, inputRows, templateRows},
+			[][]string{
+				outputHeader,
+				{"9", "Test 9", "40", "10", "", "", "10", "10", "", "1", ""},
+				{"1", "Test 1", "24", "120", "0", "0", "60", "90", "", "5", "0"},
+				{"2", "Test 2", "30", "10", "0", "0", "10", "10", "", "1", "0"},
+			},
+			[]DuplicateID{
+				{ID: "1", Source: DuplicateSourceInput, Lines: []int{2, 4}, KeptLine: 2},
+				{ID: "9", Source: DuplicateSourceTemplate, Lines: []int{2, 3}, KeptLine: 2},
+			},
+			nil,
+			nil,
+		},
+		{
+			"Given merge of bad balance then it must reject every row of the id",
+			args{DuplicatePolicyMerge, [][]string{
+				inputHeader,
+				{"1", "Test 1", "24", "100", "50", "0", "3"},
+				{"2", "Test 2", "30", "10", "10", "0", "1"},
+				{"1", "Test 1b", "25", "BAD", "10", "0", "2"},
+			}, [][]string{outputHeader}},
+			[][]string{
+				outputHeader,
+				{"2", "Test 2", "30", "10", "0", "0", "10", "10", "", "1", "0"},
+			},
+			[]DuplicateID{{ID: "1", Source: DuplicateSourceInput, Lines: []int{2, 4}, KeptLine: 2}},
+			[]int{2, 4},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			averageCalculator := pipeline.NewAverageCalculator(nil)
+			parser := NewParser(averageCalculator.Channel())
+			eodCalculator := NewEODProcessor(pipeline.NewChain(parser, averageCalculator), WithDuplicatePolicy(tt.args.policy))
+			defer eodCalculator.Close()
+			got, report, err := eodCalculator.ProcessSlice(context.Background(), tt.args.inputRows, tt.args.outputRows)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("EODProcessor.ProcessSlice() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			// Thread number depends on scheduling.
+			for _, row := range got[1:] {
+				row[afterEodHeaderIdxNo1Thread] = ""
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EODProcessor.ProcessSlice() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(report.Duplicates, tt.wantDuplicates) {
+				t.Errorf("EODProcessor.ProcessSlice() duplicates = %v, want %v", report.Duplicates, tt.wantDuplicates)
+			}
+			var failed []int
+			for _, failedRow := range report.FailedRows {
+				failed = append(failed, failedRow.Line)
+			}
+			if !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Errorf("EODProcessor.ProcessSlice() failed lines = %v, want %v", failed, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestEODProcessor_ProcessStream_Duplicate(t *testing.T) {
+	input := "id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer\n" +
+		"1;Test 1;24;100;50;0;3\n" +
+		"2;Test 2;30;10;10;0;1\n" +
+		"1;Test 1b;25;20;10;0;2\n"
+	tests := []struct {
+		name           string
+		policy         DuplicatePolicy
+		trackIDs       bool
+		wantIDs        []string
+		wantDuplicates []DuplicateID
+		wantErr        error
+	}{
+		{
+			"Given reject-run then it must fail",
+			DuplicatePolicyRejectRun,
+			true,
+			nil,
+			nil,
+			ErrDuplicateID,
+		},
+		{
+			"Given keep-last then it must process the last row",
+			DuplicatePolicyKeepLast,
+			true,
+			[]string{"1", "2"},
+			[]DuplicateID{{ID: "1", Source: DuplicateSourceInput, Lines: []int{2, 4}, KeptLine: 4}},
+			nil,
+		},
+		{
+			"Given merge then it must fail",
+			DuplicatePolicyMerge,
+			true,
+			nil,
+			nil,
+			ErrDuplicatePolicyNotStreamable,
+		},
+		{
+			"Given keep-first without id tracking then it must fail",
+			DuplicatePolicyKeepFirst,
+			false,
+			nil,
+			nil,
+			ErrDuplicatePolicyNotStreamable,
+		},
+		{
+			"Given ignore then it must process every row",
+			DuplicatePolicyIgnore,
+			false,
+			[]string{"1", "2", "1"},
+			[]DuplicateID{},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bonusDistributor := pipeline.NewBonusDistributor(nil)
+			parser := NewParser(bonusDistributor.Channel())
+			eodCalculator := NewEODProcessor(pipeline.NewChain(parser, bonusDistributor), WithDuplicatePolicy(tt.policy), WithIDTracking(tt.trackIDs))
+			defer eodCalculator.Close()
+			output := &bytes.Buffer{}
+			report, err := eodCalculator.ProcessStream(context.Background(), strings.NewReader(input), output)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("EODProcessor.ProcessStream() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")[1:]
+			ids := make([]string, 0, len(lines))
+			for _, line := range lines {
+				if tt.policy != DuplicatePolicyIgnore && strings.HasPrefix(line, "1;") && !strings.HasPrefix(line, "1;Test 1b;") {
+					t.Errorf("EODProcessor.ProcessStream() processed skipped row %v", line)
+				}
+				ids = append(ids, strings.SplitN(line, ";", 2)[0])
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Errorf("EODProcessor.ProcessStream() ids = %v, want %v", ids, tt.wantIDs)
+			}
+			if report.Reconciliation.OutputRows != len(tt.wantIDs) {
+				t.Errorf("EODProcessor.ProcessStream() output rows = %v, want %v", report.Reconciliation.OutputRows, len(tt.wantIDs))
+			}
+			if !reflect.DeepEqual(report.Duplicates, tt.wantDuplicates) {
+				t.Errorf("EODProcessor.ProcessStream() duplicates = %v, want %v", report.Duplicates, tt.wantDuplicates)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package format
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// columnar represent JSON document storing the values of every column contiguously
+// similar to the layout of Parquet, so a single column can be scanned without reading every row.
+// Every row is buffered in memory until the writer is closed.
+type columnar struct{}
+
+// columnarDocument is the JSON representation of columnar format.
+type columnarDocument struct {
+	RowCount int              `json:"row_count"`
+	Columns  []columnarColumn `json:"columns"`
+}
+
+// columnarColumn is a single column of columnar format.
+type columnarColumn struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// Name return the name of the format.
+func (c *columnar) Name() string {
+	return NameColumnar
+}
+
+// Buffered mark columnar as buffered since the document is only written once every row is known.
+func (c *columnar) Buffered() {}
+
+// NewReader return a new Reader reading columnar document from given reader.
+// The whole document is read on the first read.
+func (c *columnar) NewReader(r io.Reader) Reader {
+	return &columnarReader{
+		reader: r,
+	}
+}
+
+// NewWriter return a new Writer writing columnar document into given writer.
+// The document is only written on Close.
+func (c *columnar) NewWriter(w io.Writer) Writer {
+	return &columnarWriter{
+		writer: w,
+	}
+}
+
+type columnarReader struct {
+	reader   io.Reader
+	document *columnarDocument
+	next     int
+}
+
+func (c *columnarReader) Read() ([]string, error) {
+	if c.document == nil {
+		document := &columnarDocument{}
+		if err := json.NewDecoder(bufio.NewReader(c.reader)).Decode(document); err != nil {
+			return nil, err
+		}
+		for _, column := range document.Columns {
+			if len(column.Values) != document.RowCount {
+				return nil, fmt.Errorf(`%w, column "%s" has %d values while there are %d rows`, ErrInvalidRow, column.Name, len(column.Values), document.RowCount)
+			}
+		}
+		c.document = document
+		c.next = -1
+	}
+	if c.next >= c.document.RowCount {
+		return nil, io.EOF
+	}
+	row := make([]string, len(c.document.Columns))
+	for idx, column := range c.document.Columns {
+		if c.next < 0 {
+			row[idx] = column.Name
+		} else {
+			row[idx] = column.Values[c.next]
+		}
+	}
+	c.next++
+	return row, nil
+}
+
+type columnarWriter struct {
+	writer   io.Writer
+	document *columnarDocument
+}
+
+func (c *columnarWriter) Write(row []string) error {
+	if c.document == nil {
+		c.document = &columnarDocument{
+			Columns: make([]columnarColumn, len(row)),
+		}
+		for idx, column := range row {
+			c.document.Columns[idx] = columnarColumn{
+				Name:   column,
+				Values: []string{},
+			}
+		}
+		return nil
+	}
+	if len(row) != len(c.document.Columns) {
+		return fmt.Errorf("%w, it has %d columns while header has %d columns", ErrInvalidRow, len(row), len(c.document.Columns))
+	}
+	for idx, value := range row {
+		c.document.Columns[idx].Values = append(c.document.Columns[idx].Values, value)
+	}
+	c.document.RowCount++
+	return nil
+}
+
+func (c *columnarWriter) Close() error {
+	if c.document == nil {
+		return nil
+	}
+	return json.NewEncoder(c.writer).Encode(c.document)
+}
@@ -0,0 +1,58 @@
+package format
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// Delimited represent CSV like format delimited by a single character.
+type Delimited struct {
+	name  string
+	comma rune
+}
+
+// NewDelimited will return a new Delimited format with given name delimited by given comma.
+func NewDelimited(name string, comma rune) *Delimited {
+	return &Delimited{
+		name:  name,
+		comma: comma,
+	}
+}
+
+// Name return the name of the format.
+func (d *Delimited) Name() string {
+	return d.name
+}
+
+// LineBased mark delimited format as LineFormat.
+func (d *Delimited) LineBased() {}
+
+// NewReader return a new Reader reading delimited rows from given reader.
+// Every row must have the same amount of columns as the header.
+func (d *Delimited) NewReader(r io.Reader) Reader {
+	reader := csv.NewReader(r)
+	reader.Comma = d.comma
+	return reader
+}
+
+// NewWriter return a new Writer writing delimited rows into given writer.
+func (d *Delimited) NewWriter(w io.Writer) Writer {
+	writer := csv.NewWriter(w)
+	writer.Comma = d.comma
+	return &delimitedWriter{
+		writer: writer,
+	}
+}
+
+type delimitedWriter struct {
+	writer *csv.Writer
+}
+
+func (d *delimitedWriter) Write(row []string) error {
+	return d.writer.Write(row)
+}
+
+func (d *delimitedWriter) Close() error {
+	d.writer.Flush()
+	return d.writer.Error()
+}
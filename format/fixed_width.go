@@ -0,0 +1,145 @@
+package format
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultFixedWidth is the width of a column without configured width.
+	DefaultFixedWidth = 20
+)
+
+var (
+	ErrValueTooWide = errors.New("value is wider than its column")
+)
+
+// FixedWidth represent format where every column is left aligned and padded with spaces to its width.
+// Width is counted in characters.
+type FixedWidth struct {
+	widths []int
+}
+
+// NewFixedWidth will return a new FixedWidth format given the width of each column in order.
+// Columns without given width use DefaultFixedWidth.
+func NewFixedWidth(widths []int) *FixedWidth {
+	return &FixedWidth{
+		widths: widths,
+	}
+}
+
+// Name return the name of the format.
+func (f *FixedWidth) Name() string {
+	return NameFixedWidth
+}
+
+// width will return the width of given column index.
+func (f *FixedWidth) width(idx int) int {
+	if idx < len(f.widths) && f.widths[idx] > 0 {
+		return f.widths[idx]
+	}
+	return DefaultFixedWidth
+}
+
+// LineBased mark fixed width format as LineFormat.
+func (f *FixedWidth) LineBased() {}
+
+// NewReader return a new Reader reading fixed width rows from given reader.
+// The amount of columns is taken from the header, every row must not be wider than the header columns.
+func (f *FixedWidth) NewReader(r io.Reader) Reader {
+	return &fixedWidthReader{
+		format: f,
+		reader: bufio.NewReader(r),
+	}
+}
+
+// NewWriter return a new Writer writing fixed width rows into given writer.
+// Will return ErrValueTooWide if a value doesn't fit its column instead of truncating it.
+func (f *FixedWidth) NewWriter(w io.Writer) Writer {
+	return &fixedWidthWriter{
+		format: f,
+		writer: bufio.NewWriter(w),
+	}
+}
+
+type fixedWidthReader struct {
+	format  *FixedWidth
+	reader  *bufio.Reader
+	line    int
+	columns int
+}
+
+func (f *fixedWidthReader) Read() ([]string, error) {
+	line, err := f.readLine()
+	if err != nil {
+		return nil, err
+	}
+	row := []string{}
+	for idx := 0; len(line) > 0 || idx < f.columns; idx++ {
+		if f.columns > 0 && idx >= f.columns {
+			return nil, fmt.Errorf("%w at line %d, it has more than %d columns", ErrInvalidRow, f.line, f.columns)
+		}
+		value := line
+		width := f.format.width(idx)
+		if utf8.RuneCountInString(line) > width {
+			// Find the byte offset of the character right after the column.
+			offset := 0
+			for count := 0; count < width; count++ {
+				_, size := utf8.DecodeRuneInString(line[offset:])
+				offset += size
+			}
+			value = line[:offset]
+		}
+		line = line[len(value):]
+		row = append(row, strings.TrimRight(value, " "))
+	}
+	if f.columns == 0 {
+		f.columns = len(row)
+	}
+	return row, nil
+}
+
+// readLine will return the next non empty line without its line ending.
+func (f *fixedWidthReader) readLine() (string, error) {
+	for {
+		line, err := f.reader.ReadString('\n')
+		if err != nil && (!errors.Is(err, io.EOF) || len(line) == 0) {
+			return "", err
+		}
+		f.line++
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) > 0 {
+			return line, nil
+		}
+	}
+}
+
+type fixedWidthWriter struct {
+	format *FixedWidth
+	writer *bufio.Writer
+}
+
+func (f *fixedWidthWriter) Write(row []string) error {
+	for idx, value := range row {
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf(`%w, value "%s" contain new line`, ErrInvalidRow, value)
+		}
+		width := f.format.width(idx)
+		length := utf8.RuneCountInString(value)
+		if length > width {
+			return fmt.Errorf(`%w, value "%s" is %d characters while column %d is %d characters`, ErrValueTooWide, value, length, idx, width)
+		}
+		f.writer.WriteString(value)
+		f.writer.WriteString(strings.Repeat(" ", width-length))
+	}
+	_, err := f.writer.WriteString("\n")
+	return err
+}
+
+func (f *fixedWidthWriter) Close() error {
+	return f.writer.Flush()
+}
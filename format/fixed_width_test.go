@@ -0,0 +1,57 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFixedWidth_Read(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			"Given padded rows then it must succeed",
+			"id  Nama  \r\n1   Test 1\n\n22  Test22\n",
+			[][]string{{"id", "Nama"}, {"1", "Test 1"}, {"22", "Test22"}},
+			false,
+		},
+		{
+			"Given row with trailing space trimmed then it must fill empty column",
+			"id  Nama  \n1\n",
+			[][]string{{"id", "Nama"}, {"1", ""}},
+			false,
+		},
+		{
+			"Given row wider than header then it must fail",
+			"id  Nama\n1   Test 1              extra\n",
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadAll(NewFixedWidth([]int{4, 6}).NewReader(strings.NewReader(tt.input)))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FixedWidth.Read() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FixedWidth.Read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFixedWidth_Write(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	err := WriteAll(NewFixedWidth([]int{2}).NewWriter(buffer), [][]string{{"id"}, {"100"}})
+	if !errors.Is(err, ErrValueTooWide) {
+		t.Errorf("FixedWidth.Write() error = %v, want %v", err, ErrValueTooWide)
+	}
+}
@@ -0,0 +1,150 @@
+// Package format provide readers and writers of tabular rows in the file formats
+// supported by the EOD processor.
+// Every format treat the first row as the header.
+package format
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	NameCSV        = "csv"
+	NameSemicolon  = "semicolon"
+	NameTab        = "tab"
+	NameFixedWidth = "fixed-width"
+	NameJSONLines  = "jsonl"
+	NameColumnar   = "columnar"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown format")
+	ErrInvalidRow    = errors.New("invalid row")
+
+	// CSV is comma delimited format.
+	CSV = NewDelimited(NameCSV, ',')
+	// Semicolon is semicolon delimited format, it is the default format of the processor.
+	Semicolon = NewDelimited(NameSemicolon, ';')
+	// Tab is tab delimited format.
+	Tab = NewDelimited(NameTab, '\t')
+	// JSONLines is JSON Lines format where every row is an object keyed by the header.
+	JSONLines = &jsonLines{}
+	// Columnar is JSON document storing every column contiguously.
+	Columnar = &columnar{}
+)
+
+// Reader represent reader of rows.
+type Reader interface {
+	// Read will return the next row, the first row is the header.
+	// Will return io.EOF once there is no more row.
+	Read() ([]string, error)
+}
+
+// Writer represent writer of rows.
+type Writer interface {
+	// Write will write given row, the first row is the header.
+	Write(row []string) error
+	// Close will flush every buffered row into the underlying writer.
+	// Writer must not be used after Close.
+	Close() error
+}
+
+// Format represent a file format of rows.
+type Format interface {
+	// Name return the name of the format.
+	Name() string
+	// NewReader return a new Reader reading rows from given reader.
+	NewReader(r io.Reader) Reader
+	// NewWriter return a new Writer writing rows into given writer.
+	NewWriter(w io.Writer) Writer
+}
+
+// LineFormat represent a Format storing the header on the first line and every row on its own line.
+// The rows after any line break can be read by prefixing them with the header line, which allow an input
+// to be split on line boundaries. Rows must not contain line break, such as quoted line break in delimited row.
+type LineFormat interface {
+	Format
+	// LineBased only mark the format as line based.
+	LineBased()
+}
+
+// BufferedFormat represent a Format which Writer buffer every row in memory until it is closed,
+// so writing it doesn't keep the memory usage bounded when the rows are streamed.
+type BufferedFormat interface {
+	Format
+	// Buffered only mark the format as buffered.
+	Buffered()
+}
+
+// ByName will return the format given its name using default settings.
+// Will return ErrUnknownFormat if the name is not known.
+func ByName(name string) (Format, error) {
+	switch name {
+	case NameCSV:
+		return CSV, nil
+	case NameSemicolon:
+		return Semicolon, nil
+	case NameTab:
+		return Tab, nil
+	case NameFixedWidth:
+		return NewFixedWidth(nil), nil
+	case NameJSONLines:
+		return JSONLines, nil
+	case NameColumnar:
+		return Columnar, nil
+	}
+	return nil, fmt.Errorf(`%w "%s", must be one of %v`, ErrUnknownFormat, name, Names())
+}
+
+// Extensions will return the file extensions of given format by its name, including the leading dot.
+// Will return nil if the format is not known.
+func Extensions(f Format) []string {
+	switch f.Name() {
+	case NameCSV, NameSemicolon:
+		return []string{".csv"}
+	case NameTab:
+		return []string{".tsv", ".tab", ".txt"}
+	case NameFixedWidth:
+		return []string{".txt", ".dat"}
+	case NameJSONLines:
+		return []string{".jsonl"}
+	case NameColumnar:
+		return []string{".json"}
+	}
+	return nil
+}
+
+// Names will return the name of every known format.
+func Names() []string {
+	names := []string{NameCSV, NameSemicolon, NameTab, NameFixedWidth, NameJSONLines, NameColumnar}
+	sort.Strings(names)
+	return names
+}
+
+// ReadAll will read every remaining row from given reader.
+func ReadAll(reader Reader) ([][]string, error) {
+	rows := [][]string{}
+	for {
+		row, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return rows, nil
+			}
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// WriteAll will write every given row into given writer then close it.
+func WriteAll(writer Writer, rows [][]string) error {
+	for _, row := range rows {
+		if err := writer.Write(row); err != nil {
+			writer.Close()
+			return err
+		}
+	}
+	return writer.Close()
+}
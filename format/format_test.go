@@ -0,0 +1,73 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFormat_RoundTrip(t *testing.T) {
+	rows := [][]string{
+		{"id", "Nama", "Balanced", "Free Transfer"},
+		{"1", "Test 1", "151", "3"},
+		{"2", "Test; \"2\"", "150.50", ""},
+		{"3", "Tést 3", "", "5"},
+	}
+	tests := []struct {
+		name   string
+		format Format
+	}{
+		{"Given comma delimited then it must read back what is written", CSV},
+		{"Given semicolon delimited then it must read back what is written", Semicolon},
+		{"Given tab delimited then it must read back what is written", Tab},
+		{"Given fixed width then it must read back what is written", NewFixedWidth([]int{4, 12})},
+		{"Given JSON Lines then it must read back what is written", JSONLines},
+		{"Given columnar then it must read back what is written", Columnar},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			if err := WriteAll(tt.format.NewWriter(buffer), rows); err != nil {
+				t.Errorf("WriteAll() error = %v, want nil", err)
+				return
+			}
+			got, err := ReadAll(tt.format.NewReader(buffer))
+			if err != nil {
+				t.Errorf("ReadAll() error = %v, want nil", err)
+				return
+			}
+			if !reflect.DeepEqual(got, rows) {
+				t.Errorf("ReadAll() = %v, want %v", got, rows)
+			}
+		})
+	}
+}
+
+func TestByName(t *testing.T) {
+	for _, name := range Names() {
+		got, err := ByName(name)
+		if err != nil {
+			t.Errorf("ByName() error = %v, want nil", err)
+			continue
+		}
+		if got.Name() != name {
+			t.Errorf("ByName() = %v, want %v", got.Name(), name)
+		}
+	}
+	if _, err := ByName("parquet"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("ByName() error = %v, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func TestExtensions(t *testing.T) {
+	for _, name := range Names() {
+		f, err := ByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Extensions(f); len(got) == 0 {
+			t.Errorf("Extensions() of %v = %v, want at least one extension", name, got)
+		}
+	}
+}
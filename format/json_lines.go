@@ -0,0 +1,169 @@
+package format
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// jsonLines represent JSON Lines format where every line is an object keyed by the header.
+// The header is taken from the keys of the first object in order, values are written as string.
+type jsonLines struct{}
+
+// Name return the name of the format.
+func (j *jsonLines) Name() string {
+	return NameJSONLines
+}
+
+// NewReader return a new Reader reading JSON Lines from given reader.
+// String, number and boolean values are read as their text, null is read as empty value.
+// Keys missing from an object are read as empty value.
+func (j *jsonLines) NewReader(r io.Reader) Reader {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	return &jsonLinesReader{
+		decoder: decoder,
+	}
+}
+
+// NewWriter return a new Writer writing JSON Lines into given writer.
+func (j *jsonLines) NewWriter(w io.Writer) Writer {
+	return &jsonLinesWriter{
+		writer: bufio.NewWriter(w),
+	}
+}
+
+type jsonLinesReader struct {
+	decoder   *json.Decoder
+	header    []string
+	headerMap map[string]int
+	pending   []string
+	line      int
+}
+
+func (j *jsonLinesReader) Read() ([]string, error) {
+	if j.pending != nil {
+		row := j.pending
+		j.pending = nil
+		return row, nil
+	}
+	keys, values, err := j.readObject()
+	if err != nil {
+		return nil, err
+	}
+	if j.header == nil {
+		// The first object define the header and is returned on the next read.
+		j.header = keys
+		j.headerMap = make(map[string]int, len(keys))
+		for idx, key := range keys {
+			j.headerMap[key] = idx
+		}
+		j.pending = values
+		return j.header, nil
+	}
+	row := make([]string, len(j.header))
+	for idx, key := range keys {
+		column, ok := j.headerMap[key]
+		if !ok {
+			return nil, fmt.Errorf(`%w at object %d, unknown key "%s"`, ErrInvalidRow, j.line, key)
+		}
+		row[column] = values[idx]
+	}
+	return row, nil
+}
+
+// readObject will read the next object and return its keys and values in order.
+func (j *jsonLinesReader) readObject() ([]string, []string, error) {
+	token, err := j.decoder.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	j.line++
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, nil, fmt.Errorf("%w at object %d, it must be an object", ErrInvalidRow, j.line)
+	}
+	keys := []string{}
+	values := []string{}
+	for j.decoder.More() {
+		token, err := j.decoder.Token()
+		if err != nil {
+			return nil, nil, j.unexpected(err)
+		}
+		key := token.(string)
+		token, err = j.decoder.Token()
+		if err != nil {
+			return nil, nil, j.unexpected(err)
+		}
+		var value string
+		switch typed := token.(type) {
+		case string:
+			value = typed
+		case json.Number:
+			value = typed.String()
+		case bool:
+			value = fmt.Sprint(typed)
+		case nil:
+			value = ""
+		default:
+			return nil, nil, fmt.Errorf(`%w at object %d, value of key "%s" must not be nested`, ErrInvalidRow, j.line, key)
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	// Consume the closing delimiter.
+	if _, err := j.decoder.Token(); err != nil {
+		return nil, nil, j.unexpected(err)
+	}
+	return keys, values, nil
+}
+
+// unexpected will convert end of input in the middle of an object into an error.
+func (j *jsonLinesReader) unexpected(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+type jsonLinesWriter struct {
+	writer *bufio.Writer
+	header [][]byte
+}
+
+func (j *jsonLinesWriter) Write(row []string) error {
+	if j.header == nil {
+		j.header = make([][]byte, len(row))
+		for idx, column := range row {
+			key, err := json.Marshal(column)
+			if err != nil {
+				return err
+			}
+			j.header[idx] = key
+		}
+		return nil
+	}
+	if len(row) != len(j.header) {
+		return fmt.Errorf("%w, it has %d columns while header has %d columns", ErrInvalidRow, len(row), len(j.header))
+	}
+	j.writer.WriteByte('{')
+	for idx, column := range row {
+		if idx > 0 {
+			j.writer.WriteByte(',')
+		}
+		value, err := json.Marshal(column)
+		if err != nil {
+			return err
+		}
+		j.writer.Write(j.header[idx])
+		j.writer.WriteByte(':')
+		j.writer.Write(value)
+	}
+	_, err := j.writer.WriteString("}\n")
+	return err
+}
+
+func (j *jsonLinesWriter) Close() error {
+	return j.writer.Flush()
+}
@@ -0,0 +1,41 @@
+package format
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONLines_Read(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			"Given objects in any key order then it must follow the first object",
+			`{"id":"1","Balanced":151.5,"Active":true}
+{"Active":false,"id":2}
+{"id":"3","Balanced":null}`,
+			[][]string{{"id", "Balanced", "Active"}, {"1", "151.5", "true"}, {"2", "", "false"}, {"3", "", ""}},
+			false,
+		},
+		{"Given unknown key then it must fail", `{"id":"1"}` + "\n" + `{"Nama":"Test"}`, nil, true},
+		{"Given nested value then it must fail", `{"id":{"value":"1"}}`, nil, true},
+		{"Given truncated object then it must fail", `{"id":"1"`, nil, true},
+		{"Given non object then it must fail", `["1"]`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadAll(JSONLines.NewReader(strings.NewReader(tt.input)))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("JSONLines.Read() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JSONLines.Read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
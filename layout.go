@@ -0,0 +1,275 @@
+package bankeodprocessor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	ErrMissingColumn        = errors.New("missing required column")
+	ErrDuplicateColumn      = errors.New("duplicate column")
+	ErrInvalidColumnAliases = errors.New("invalid column aliases")
+)
+
+// ColumnAliases represent the accepted alternative names of the known columns keyed by the column name.
+// Column names and aliases are matched case insensitively ignoring surrounding spaces.
+type ColumnAliases map[string][]string
+
+// DefaultColumnAliases will return the aliases accepted when no aliases are configured.
+func DefaultColumnAliases() ColumnAliases {
+	return ColumnAliases{
+		"id":                {"Account ID"},
+		"Nama":              {"Name"},
+		"Balanced":          {"Balance"},
+		"Previous Balanced": {"Previous Balance"},
+		"Average Balanced":  {"Average Balance"},
+	}
+}
+
+// LoadColumnAliases will read and validate column aliases from given JSON file name.
+func LoadColumnAliases(fileName string) (ColumnAliases, error) {
+	fileHandle, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read provided column aliases file %w`, err)
+	}
+	defer fileHandle.Close()
+	return ParseColumnAliases(fileHandle)
+}
+
+// ParseColumnAliases will read and validate column aliases from given JSON reader.
+func ParseColumnAliases(reader io.Reader) (ColumnAliases, error) {
+	aliases := ColumnAliases{}
+	if err := json.NewDecoder(reader).Decode(&aliases); err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrInvalidColumnAliases, err)
+	}
+	if err := aliases.Validate(); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// Validate will return error if an alias refer to unknown column or is shared by more than one column.
+func (c ColumnAliases) Validate() error {
+	columns := knownColumns()
+	known := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		known[columnKey(column)] = true
+	}
+	owners := make(map[string]string)
+	for column, aliases := range c {
+		if !known[columnKey(column)] {
+			return fmt.Errorf(`%w, unknown column "%s"`, ErrInvalidColumnAliases, column)
+		}
+		for _, alias := range aliases {
+			key := columnKey(alias)
+			if len(key) == 0 {
+				return fmt.Errorf(`%w, column "%s" has empty alias`, ErrInvalidColumnAliases, column)
+			}
+			if known[key] && key != columnKey(column) {
+				return fmt.Errorf(`%w, alias "%s" of column "%s" is a known column`, ErrInvalidColumnAliases, alias, column)
+			}
+			if owner, exist := owners[key]; exist && owner != column {
+				return fmt.Errorf(`%w, alias "%s" is used by column "%s" and "%s"`, ErrInvalidColumnAliases, alias, owner, column)
+			}
+			owners[key] = column
+		}
+	}
+	return nil
+}
+
+// names will return every accepted name of given column.
+func (c ColumnAliases) names(column string) []string {
+	return append([]string{column}, c[column]...)
+}
+
+// columnKey will return the key used to match given column name.
+func columnKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// columnMapping represent mapping of rows with named columns into the known columns order.
+// Columns that are not known are kept after the known columns in their original order.
+type columnMapping struct {
+	// header is the known columns followed by the extra columns.
+	header []string
+	// sources is the index of each header column on the mapped row.
+	sources []int
+}
+
+// newColumnMapping will resolve every given known column by name from given header.
+// Will return error listing every known column that can't be found.
+func newColumnMapping(known []string, header []string, aliases ColumnAliases) (*columnMapping, error) {
+	lookup := make(map[string]int)
+	for idx, column := range known {
+		for _, name := range aliases.names(column) {
+			lookup[columnKey(name)] = idx
+		}
+	}
+	sources := make([]int, len(known))
+	for idx := range sources {
+		sources[idx] = -1
+	}
+	extras := []string{}
+	extraSources := []int{}
+	for idx, name := range header {
+		knownIdx, ok := lookup[columnKey(name)]
+		if !ok {
+			extras = append(extras, name)
+			extraSources = append(extraSources, idx)
+			continue
+		}
+		if previous := sources[knownIdx]; previous >= 0 {
+			return nil, fmt.Errorf(`%w, "%s" at index "%d" and "%s" at index "%d" are both column "%s"`,
+				ErrDuplicateColumn, header[previous], previous, name, idx, known[knownIdx])
+		}
+		sources[knownIdx] = idx
+	}
+	missing := []string{}
+	for idx, source := range sources {
+		if source < 0 {
+			missing = append(missing, fmt.Sprintf(`"%s" (accepted names: %s)`, known[idx], strings.Join(aliases.names(known[idx]), ", ")))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w %s", ErrMissingColumn, strings.Join(missing, ", "))
+	}
+	return &columnMapping{
+		header:  append(append([]string{}, known...), extras...),
+		sources: append(sources, extraSources...),
+	}, nil
+}
+
+// apply will return given row with its columns in the mapping order.
+// Missing trailing column is treated as empty value.
+func (m *columnMapping) apply(row []string) []string {
+	mapped := make([]string, len(m.sources))
+	for idx, source := range m.sources {
+		if source < len(row) {
+			mapped[idx] = row[source]
+		}
+	}
+	return mapped
+}
+
+// applyAll will map every given row, the first row is the header and is replaced by the mapping header.
+func (m *columnMapping) applyAll(rows [][]string) [][]string {
+	mapped := make([][]string, len(rows))
+	mapped[0] = m.header
+	for idx, row := range rows[1:] {
+		mapped[idx+1] = m.apply(row)
+	}
+	return mapped
+}
+
+// outputLayout represent the columns of the output given the columns of the input and the output template.
+// Output always start with the known columns of the output schema, followed by the extra columns of the template,
+// followed by the extra columns of the input that aren't part of the template.
+type outputLayout struct {
+	header []string
+	// fromInput migrate a mapped input row into a new output row.
+	fromInput *rowMigration
+	// carried is the index pair of input and output column copied into every processed output row.
+	carried [][2]int
+}
+
+// newOutputLayout will return the output layout of given output schema.
+// Given input header must already be mapped on its schema of given amount of known columns.
+func newOutputLayout(inputHeader []string, inputKnown int, outputSchema *Schema, templateExtras []string) *outputLayout {
+	header := append(append([]string{}, outputSchema.Columns...), templateExtras...)
+	positions := make(map[string]int, len(header))
+	for idx, column := range header {
+		positions[columnKey(column)] = idx
+	}
+	carriedColumns := append([]string{}, outputSchema.Carried...)
+	for _, column := range inputHeader[inputKnown:] {
+		carriedColumns = append(carriedColumns, column)
+		if _, exist := positions[columnKey(column)]; !exist {
+			positions[columnKey(column)] = len(header)
+			header = append(header, column)
+		}
+	}
+	inputPositions := make(map[string]int, len(inputHeader))
+	for idx, column := range inputHeader {
+		inputPositions[columnKey(column)] = idx
+	}
+	carried := [][2]int{}
+	for _, column := range carriedColumns {
+		if inputIdx, exist := inputPositions[columnKey(column)]; exist {
+			carried = append(carried, [2]int{inputIdx, positions[columnKey(column)]})
+		}
+	}
+	return &outputLayout{
+		header:    header,
+		fromInput: newRowMigration(inputHeader, header, outputSchema.Defaults),
+		carried:   carried,
+	}
+}
+
+// newRow will return a new output row filled with the data from given mapped input row.
+func (l *outputLayout) newRow(inputRow []string) []string {
+	return l.fromInput.apply(inputRow)
+}
+
+// copyCarried will copy the carried and extra columns of given mapped input row into given output row untouched.
+func (l *outputLayout) copyCarried(inputRow, outputRow []string) {
+	for _, pair := range l.carried {
+		outputRow[pair[1]] = inputRow[pair[0]]
+	}
+}
+
+// rowMigration represent migration of rows from a header into another header by column name.
+type rowMigration struct {
+	// sources is the index on the source row of each target column, -1 if the source doesn't have it.
+	sources []int
+	// defaults is the value of each target column that the source doesn't have.
+	defaults []string
+}
+
+// newRowMigration will return migration of rows from given header into given target header.
+// Target columns that are not in the source header are filled with given defaults.
+func newRowMigration(fromHeader, toHeader []string, defaults map[string]string) *rowMigration {
+	positions := make(map[string]int, len(fromHeader))
+	for idx, column := range fromHeader {
+		positions[columnKey(column)] = idx
+	}
+	migration := &rowMigration{
+		sources:  make([]int, len(toHeader)),
+		defaults: make([]string, len(toHeader)),
+	}
+	for idx, column := range toHeader {
+		source, exist := positions[columnKey(column)]
+		if !exist {
+			source = -1
+			migration.defaults[idx] = defaults[column]
+		}
+		migration.sources[idx] = source
+	}
+	return migration
+}
+
+// apply will return given row migrated into the target header.
+func (m *rowMigration) apply(row []string) []string {
+	migrated := make([]string, len(m.sources))
+	for idx, source := range m.sources {
+		if source < 0 {
+			migrated[idx] = m.defaults[idx]
+		} else {
+			migrated[idx] = row[source]
+		}
+	}
+	return migrated
+}
+
+// applyAll will migrate every given row, the first row is the header and is replaced by given target header.
+func (m *rowMigration) applyAll(rows [][]string, toHeader []string) [][]string {
+	migrated := make([][]string, len(rows))
+	migrated[0] = toHeader
+	for idx, row := range rows[1:] {
+		migrated[idx+1] = m.apply(row)
+	}
+	return migrated
+}
@@ -0,0 +1,133 @@
+package bankeodprocessor
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+func TestNewColumnMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  []string
+		row     []string
+		want    []string
+		wantErr error
+	}{
+		{
+			"Given exact header then it must keep the row",
+			[]string{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer"},
+			[]string{"1", "Test 1", "24", "151", "100", "100", "3"},
+			[]string{"1", "Test 1", "24", "151", "100", "100", "3"},
+			nil,
+		},
+		{
+			"Given reordered aliased header with extra column then it must map the row",
+			[]string{"Branch", "Free Transfer", " NAME ", "Age", "Balance", "ID", "Average Balanced", "Previous Balanced"},
+			[]string{"JKT", "3", "Test 1", "24", "151", "1", "100", "100"},
+			[]string{"1", "Test 1", "24", "151", "100", "100", "3", "JKT"},
+			nil,
+		},
+		{
+			"Given missing column then it must fail",
+			[]string{"id", "Nama", "Age", "Previous Balanced", "Average Balanced", "Free Transfer"},
+			nil,
+			nil,
+			ErrMissingColumn,
+		},
+		{
+			"Given column and its alias then it must fail",
+			[]string{"id", "Nama", "Age", "Balanced", "Balance", "Previous Balanced", "Average Balanced", "Free Transfer"},
+			nil,
+			nil,
+			ErrDuplicateColumn,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapping, err := newColumnMapping(beforeEodCSVHeader, tt.header, DefaultColumnAliases())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("newColumnMapping() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if got := mapping.apply(tt.row); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("columnMapping.apply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewColumnMapping_MissingMessage(t *testing.T) {
+	_, err := newColumnMapping(beforeEodCSVHeader, []string{"id", "Nama", "Age"}, DefaultColumnAliases())
+	want := `missing required column "Balanced" (accepted names: Balanced, Balance)`
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("newColumnMapping() error = %v, want containing %v", err, want)
+	}
+}
+
+func TestParseColumnAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"Given valid aliases then it must succeed", `{"Balanced": ["Balance", "Saldo"]}`, false},
+		{"Given unknown column then it must fail", `{"Saldo": ["Balance"]}`, true},
+		{"Given alias shared by columns then it must fail", `{"Balanced": ["Saldo"], "Previous Balanced": ["saldo"]}`, true},
+		{"Given alias of known column then it must fail", `{"Balanced": ["Age"]}`, true},
+		{"Given empty alias then it must fail", `{"Balanced": [" "]}`, true},
+		{"Given invalid JSON then it must fail", `{"Balanced": "Balance"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseColumnAliases(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseColumnAliases() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && !errors.Is(err, ErrInvalidColumnAliases) {
+				t.Errorf("ParseColumnAliases() error = %v, want %v", err, ErrInvalidColumnAliases)
+			}
+		})
+	}
+}
+
+func TestEODProcessor_ProcessSlice_Columns(t *testing.T) {
+	averageCalculator := pipeline.NewAverageCalculator(nil)
+	parser := NewParser(averageCalculator.Channel())
+	eodCalculator := NewEODProcessor(pipeline.NewChain(parser, averageCalculator))
+	defer eodCalculator.Close()
+	inputRows := [][]string{
+		{"Branch", "Free Transfer", "Name", "Age", "Balance", "id", "Average Balanced", "Previous Balanced"},
+		{"JKT", "3", "Test 1", "24", "151", "1", "100", "100"},
+		{"BDG", "2", "Test 2", "25", "150", "2", "100", "150"},
+	}
+	outputRows := [][]string{
+		{"id", "Nama", "Age", "Balanced", "No 2b Thread-No", "No 3 Thread-No", "Previous Balanced", "Average Balanced", "No 1 Thread-No", "Free Transfer", "No 2a Thread-No", "Note", "Branch"},
+		{"2", "Test 2", "25", "90", "", "", "80", "85", "", "2", "", "VIP", "SBY"},
+	}
+	got, _, err := eodCalculator.ProcessSlice(context.Background(), inputRows, outputRows)
+	if err != nil {
+		t.Errorf("EODProcessor.ProcessSlice() error = %v, want nil", err)
+		return
+	}
+	// Thread number depends on scheduling.
+	for _, row := range got[1:] {
+		row[afterEodHeaderIdxNo1Thread] = ""
+	}
+	want := [][]string{
+		{"id", "Nama", "Age", "Balanced", "No 2b Thread-No", "No 3 Thread-No", "Previous Balanced", "Average Balanced", "No 1 Thread-No", "Free Transfer", "No 2a Thread-No", "Note", "Branch"},
+		{"2", "Test 2", "25", "150", "0", "0", "80", "150", "", "2", "0", "VIP", "BDG"},
+		{"1", "Test 1", "24", "151", "0", "0", "100", "125.50", "", "3", "0", "", "JKT"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EODProcessor.ProcessSlice() = %v, want %v", got, want)
+	}
+}
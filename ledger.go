@@ -0,0 +1,272 @@
+package bankeodprocessor
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// BusinessDateLayout is the layout used to format a business date.
+	BusinessDateLayout = "2006-01-02"
+	// ledgerLockSuffix is appended to the ledger file name to name its lock file.
+	ledgerLockSuffix = ".lock"
+	// ledgerLockTimeout is the maximum duration waited for the lock of the ledger.
+	ledgerLockTimeout = 30 * time.Second
+	// ledgerLockRetry is the duration waited before retrying to take the lock of the ledger.
+	ledgerLockRetry = 10 * time.Millisecond
+)
+
+// LedgerStatus represent the state of a run recorded in the ledger.
+type LedgerStatus string
+
+const (
+	// LedgerStatusPending is recorded before the output of the run is published.
+	// A run left pending has crashed while publishing its output, it is still considered processed.
+	LedgerStatusPending LedgerStatus = "pending"
+	// LedgerStatusDone is recorded once the output of the run is published.
+	// Entry without status is treated as done.
+	LedgerStatusDone LedgerStatus = "done"
+	// LedgerStatusAborted is recorded when the output of a pending run failed to be published,
+	// the run is then no longer considered processed.
+	LedgerStatusAborted LedgerStatus = "aborted"
+)
+
+var (
+	ErrAlreadyProcessed = errors.New("run has already been processed")
+	ErrLedgerLocked     = errors.New("ledger is locked by another run")
+)
+
+// LedgerEntry represent a successful run recorded in the ledger.
+type LedgerEntry struct {
+	// RunID is the id of the run.
+	RunID string `json:"run_id,omitempty"`
+	// BusinessDate is the business date of the run formatted using BusinessDateLayout.
+	BusinessDate string `json:"business_date"`
+	// Checksum is the hex encoded SHA-256 checksum of the input file.
+	Checksum string `json:"checksum"`
+	// InputFile is the input file name of the run.
+	InputFile string `json:"input_file"`
+	// OutputFile is the output file name of the run.
+	OutputFile string `json:"output_file"`
+	// ProcessedRows is the amount of rows processed by the run.
+	ProcessedRows int `json:"processed_rows"`
+	// FailedRows is the amount of rows failed by the run.
+	FailedRows int `json:"failed_rows"`
+	// AuditFile is the file name of the audit log the run was recorded into, empty if the audit log is disabled.
+	AuditFile string `json:"audit_file,omitempty"`
+	// AuditHead is the hash of the last entry of the audit log once the run was recorded,
+	// used to detect a truncated or recomputed audit log.
+	AuditHead string `json:"audit_head,omitempty"`
+	// Forced is true if the run was forced over a previous run.
+	Forced bool `json:"forced,omitempty"`
+	// Status is the state of the run, a later entry of the same run id replace the status of the previous one.
+	Status LedgerStatus `json:"status,omitempty"`
+	// ProcessedAt is the time the run has been recorded.
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// Ledger represent a local file recording which business dates and inputs have been processed.
+// Each entry is stored as a JSON line so recording a run never rewrite previous entries.
+// A run is recorded as pending before its output is published then recorded again as done,
+// so a crash while publishing still leave the run in the ledger.
+type Ledger struct {
+	fileName string
+	mutex    sync.Mutex
+}
+
+// NewLedger will return a new Ledger stored on given file name.
+// The file is created on the first recorded run.
+func NewLedger(fileName string) *Ledger {
+	return &Ledger{
+		fileName: fileName,
+	}
+}
+
+// Find will return the first entry processed on given business date or with given checksum.
+// Will return nil entry if no such run has been recorded.
+func (l *Ledger) Find(businessDate, checksum string) (*LedgerEntry, error) {
+	return l.find(func(entry *LedgerEntry) bool {
+		return entry.BusinessDate == businessDate || entry.Checksum == checksum
+	})
+}
+
+// FindInput will return the first entry processed on given business date from given input file name, or with given checksum.
+// Unlike Find, other input files of the same business date don't match so every file of a batch can be processed.
+// Input file names are compared as absolute paths, so the same file given relative or absolute still match.
+// Will return nil entry if no such run has been recorded.
+func (l *Ledger) FindInput(businessDate, inputFileName, checksum string) (*LedgerEntry, error) {
+	return l.find(func(entry *LedgerEntry) bool {
+		return (entry.BusinessDate == businessDate && samePath(entry.InputFile, inputFileName)) || entry.Checksum == checksum
+	})
+}
+
+// find will return the first run matching given function, aborted runs are skipped.
+// Every run is represented by its latest entry.
+// Will return nil entry if no run match.
+func (l *Ledger) find(match func(entry *LedgerEntry) bool) (*LedgerEntry, error) {
+	entries, err := l.runs()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Status != LedgerStatusAborted && match(entry) {
+			return entry, nil
+		}
+	}
+	return nil, nil
+}
+
+// runs will return the latest entry of every run in the order the runs are first recorded.
+// Entries without run id are always a run of their own.
+func (l *Ledger) runs() ([]*LedgerEntry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	fileHandle, err := os.Open(l.fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf(`failed to read provided ledger file %w`, err)
+	}
+	defer fileHandle.Close()
+	entries := []*LedgerEntry{}
+	runIndexes := make(map[string]int)
+	scanner := bufio.NewScanner(fileHandle)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := &LedgerEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf(`failed to read provided ledger file at line %d %w`, line, err)
+		}
+		if idx, exist := runIndexes[entry.RunID]; exist && len(entry.RunID) > 0 {
+			entries[idx] = entry
+			continue
+		}
+		runIndexes[entry.RunID] = len(entries)
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf(`failed to read provided ledger file %w`, err)
+	}
+	return entries, nil
+}
+
+// Begin will record given entry as pending once given check succeed.
+// Both are done under an exclusive lock file shared with other processes, so two runs can't both pass the check.
+// Will return the error of the check without recording the entry if it fail.
+func (l *Ledger) Begin(entry *LedgerEntry, check func() error) error {
+	unlock, err := l.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := check(); err != nil {
+		return err
+	}
+	entry.Status = LedgerStatusPending
+	entry.ProcessedAt = time.Now()
+	return l.Record(entry)
+}
+
+// Finish will record given pending entry as done.
+func (l *Ledger) Finish(entry *LedgerEntry) error {
+	entry.Status = LedgerStatusDone
+	entry.ProcessedAt = time.Now()
+	return l.Record(entry)
+}
+
+// Abort will record given pending entry as aborted.
+func (l *Ledger) Abort(entry *LedgerEntry) error {
+	entry.Status = LedgerStatusAborted
+	entry.ProcessedAt = time.Now()
+	return l.Record(entry)
+}
+
+// lock will take the exclusive lock of the ledger by creating its lock file, waiting for the lock
+// to be released by another run up to ledgerLockTimeout.
+// Will return the function releasing the lock.
+// Will return ErrLedgerLocked if the lock can't be taken in time, the lock file of a crashed run must be removed by hand.
+func (l *Ledger) lock() (func(), error) {
+	lockFileName := l.fileName + ledgerLockSuffix
+	deadline := time.Now().Add(ledgerLockTimeout)
+	for {
+		fileHandle, err := os.OpenFile(lockFileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(fileHandle, "%d\n", os.Getpid())
+			fileHandle.Close()
+			return func() {
+				os.Remove(lockFileName)
+			}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf(`failed to lock provided ledger file %w`, err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf(`%w, remove "%s" if no run is in progress`, ErrLedgerLocked, lockFileName)
+		}
+		time.Sleep(ledgerLockRetry)
+	}
+}
+
+// Record will append given entry into the ledger file.
+func (l *Ledger) Record(entry *LedgerEntry) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	fileHandle, err := os.OpenFile(l.fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf(`failed to write to provided ledger file %w`, err)
+	}
+	if _, err := fileHandle.Write(append(payload, '\n')); err != nil {
+		fileHandle.Close()
+		return fmt.Errorf(`failed to write to provided ledger file %w`, err)
+	}
+	if err := fileHandle.Sync(); err != nil {
+		fileHandle.Close()
+		return fmt.Errorf(`failed to write to provided ledger file %w`, err)
+	}
+	return fileHandle.Close()
+}
+
+// samePath will return true if given file names are the same path once made absolute and cleaned.
+// File name that can't be made absolute is only cleaned.
+func samePath(fileName, otherFileName string) bool {
+	return absPath(fileName) == absPath(otherFileName)
+}
+
+// absPath will return given file name as a clean absolute path, or only cleaned if it can't be made absolute.
+func absPath(fileName string) string {
+	abs, err := filepath.Abs(fileName)
+	if err != nil {
+		return filepath.Clean(fileName)
+	}
+	return abs
+}
+
+// fileChecksum will return the hex encoded SHA-256 checksum of given file name.
+func fileChecksum(fileName string) (string, error) {
+	fileHandle, err := os.Open(fileName)
+	if err != nil {
+		return "", fmt.Errorf(`failed to process provided input file %w`, err)
+	}
+	defer fileHandle.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, fileHandle); err != nil {
+		return "", fmt.Errorf(`failed to process provided input file %w`, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,236 @@
+package bankeodprocessor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+func TestLedger_Find(t *testing.T) {
+	ledger := NewLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	entry := &LedgerEntry{
+		BusinessDate: "2022-10-01",
+		Checksum:     "abc",
+		InputFile:    "Before Eod.csv",
+		OutputFile:   "After Eod.csv",
+		ProcessedAt:  time.Date(2022, 10, 1, 23, 0, 0, 0, time.UTC),
+	}
+	if got, err := ledger.Find("2022-10-01", "abc"); got != nil || err != nil {
+		t.Fatalf("Ledger.Find() = %v, %v, want nil on missing ledger", got, err)
+	}
+	if err := ledger.Record(entry); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		businessDate string
+		checksum     string
+		wantFound    bool
+	}{
+		{"Given same business date then it must be found", "2022-10-01", "def", true},
+		{"Given same checksum then it must be found", "2022-10-02", "abc", true},
+		{"Given new business date and checksum then it must not be found", "2022-10-02", "def", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ledger.Find(tt.businessDate, tt.checksum)
+			if err != nil {
+				t.Errorf("Ledger.Find() error = %v, want nil", err)
+				return
+			}
+			if (got != nil) != tt.wantFound {
+				t.Errorf("Ledger.Find() = %v, wantFound %v", got, tt.wantFound)
+				return
+			}
+			if got != nil && !got.ProcessedAt.Equal(entry.ProcessedAt) {
+				t.Errorf("Ledger.Find() processed at = %v, want %v", got.ProcessedAt, entry.ProcessedAt)
+			}
+		})
+	}
+}
+
+func TestLedger_FindInput(t *testing.T) {
+	ledger := NewLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	if err := ledger.Record(&LedgerEntry{
+		BusinessDate: "2022-10-01",
+		Checksum:     "abc",
+		InputFile:    "001/Before Eod.csv",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		businessDate string
+		inputFile    string
+		checksum     string
+		wantFound    bool
+	}{
+		{"Given same business date and input then it must be found", "2022-10-01", "001/Before Eod.csv", "def", true},
+		{"Given same business date and input as another path then it must be found", "2022-10-01", "./001/../001/Before Eod.csv", "def", true},
+		{"Given same checksum then it must be found", "2022-10-02", "002/Before Eod.csv", "abc", true},
+		{"Given same business date of another input then it must not be found", "2022-10-01", "002/Before Eod.csv", "def", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ledger.FindInput(tt.businessDate, tt.inputFile, tt.checksum)
+			if err != nil {
+				t.Errorf("Ledger.FindInput() error = %v, want nil", err)
+				return
+			}
+			if (got != nil) != tt.wantFound {
+				t.Errorf("Ledger.FindInput() = %v, wantFound %v", got, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestLedger_Begin(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "ledger.jsonl")
+	ledger := NewLedger(fileName)
+	entry := &LedgerEntry{RunID: "run", BusinessDate: "2022-10-01", Checksum: "abc"}
+	refused := errors.New("refused")
+	if err := ledger.Begin(entry, func() error { return refused }); !errors.Is(err, refused) {
+		t.Fatalf("Ledger.Begin() error = %v, want %v", err, refused)
+	}
+	if got, err := ledger.Find("2022-10-01", "abc"); got != nil || err != nil {
+		t.Fatalf("Ledger.Find() = %v, %v, want nil on refused run", got, err)
+	}
+	if err := ledger.Begin(entry, func() error { return nil }); err != nil {
+		t.Fatalf("Ledger.Begin() error = %v, want nil", err)
+	}
+	if _, err := os.Stat(fileName + ledgerLockSuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Ledger.Begin() lock file error = %v, want released lock", err)
+	}
+	if got, err := ledger.Find("2022-10-01", "abc"); err != nil || got == nil || got.Status != LedgerStatusPending {
+		t.Fatalf("Ledger.Find() = %v, %v, want pending run", got, err)
+	}
+	if err := ledger.Abort(entry); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ledger.Find("2022-10-01", "abc"); got != nil || err != nil {
+		t.Fatalf("Ledger.Find() = %v, %v, want nil on aborted run", got, err)
+	}
+	if err := ledger.Begin(entry, func() error { return nil }); err != nil {
+		t.Fatalf("Ledger.Begin() error = %v, want nil", err)
+	}
+	if err := ledger.Finish(entry); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ledger.Find("2022-10-01", "abc"); err != nil || got == nil || got.Status != LedgerStatusDone {
+		t.Fatalf("Ledger.Find() = %v, %v, want done run", got, err)
+	}
+}
+
+func TestLedger_FindCorrupted(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "ledger.jsonl")
+	if err := os.WriteFile(fileName, []byte("{\"business_date\":\"2022-10-01\"\nnot json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewLedger(fileName).Find("2022-10-02", "abc"); err == nil {
+		t.Errorf("Ledger.Find() error = nil, want error")
+	}
+}
+
+func TestEODProcessor_Ledger(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "Before Eod.csv")
+	outputPath := filepath.Join(dir, "After Eod.csv")
+	ledgerPath := filepath.Join(dir, "ledger.jsonl")
+	if err := os.WriteFile(inputPath, []byte(`id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer
+1;Test 1;24;151;100;100;3
+2;Test 2;25;150;150;100;2`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	businessDate := time.Date(2022, 10, 1, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name         string
+		businessDate time.Time
+		force        bool
+		wantErr      error
+		wantMatch    string
+	}{
+		{"Given first run then it must succeed", businessDate, false, nil, ""},
+		{"Given same input and business date then it must be refused", businessDate, false, ErrAlreadyProcessed, "business date \"2022-10-01\" and input checksum"},
+		{"Given same input on another business date then it must be refused", businessDate.AddDate(0, 0, 1), false, ErrAlreadyProcessed, "input checksum"},
+		{"Given forced run then it must succeed", businessDate, true, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			averageCalculator := pipeline.NewAverageCalculator(nil)
+			parser := NewParser(averageCalculator.Channel())
+			eodCalculator := NewEODProcessor(
+				pipeline.NewChain(parser, averageCalculator),
+				WithLedger(NewLedger(ledgerPath)),
+				WithBusinessDate(tt.businessDate),
+				WithForce(tt.force),
+			)
+			defer eodCalculator.Close()
+			before, _ := os.ReadFile(outputPath)
+			_, err := eodCalculator.Process(context.Background(), inputPath, outputPath)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("EODProcessor.Process() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantMatch) {
+				t.Errorf("EODProcessor.Process() error = %v, want matched %v", err, tt.wantMatch)
+			}
+			after, _ := os.ReadFile(outputPath)
+			if tt.wantErr != nil && string(before) != string(after) {
+				t.Errorf("EODProcessor.Process() refused run must not change the output")
+			}
+		})
+	}
+}
+
+func TestEODProcessor_LedgerPending(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "Before Eod.csv")
+	outputPath := filepath.Join(dir, "After Eod.csv")
+	ledger := NewLedger(filepath.Join(dir, "ledger.jsonl"))
+	if err := os.WriteFile(inputPath, []byte(`id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer
+1;Test 1;24;151;100;100;3`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	businessDate := time.Date(2022, 10, 1, 0, 0, 0, 0, time.Local)
+	process := func() (*RunReport, error) {
+		averageCalculator := pipeline.NewAverageCalculator(nil)
+		parser := NewParser(averageCalculator.Channel())
+		eodCalculator := NewEODProcessor(
+			pipeline.NewChain(parser, averageCalculator),
+			WithLedger(ledger),
+			WithBusinessDate(businessDate),
+		)
+		defer eodCalculator.Close()
+		return eodCalculator.Process(context.Background(), inputPath, outputPath)
+	}
+	report, err := process()
+	if err != nil {
+		t.Fatalf("EODProcessor.Process() error = %v, want nil", err)
+	}
+	got, err := ledger.Find(businessDate.Format(BusinessDateLayout), "")
+	if err != nil || got == nil || got.RunID != report.RunID || got.Status != LedgerStatusDone {
+		t.Fatalf("Ledger.Find() = %v, %v, want done run %v", got, err, report.RunID)
+	}
+
+	// A crash after publishing leave the run pending.
+	got.RunID = "crashed"
+	got.BusinessDate = businessDate.AddDate(0, 0, 1).Format(BusinessDateLayout)
+	got.Status = LedgerStatusPending
+	if err := ledger.Record(got); err != nil {
+		t.Fatal(err)
+	}
+	businessDate = businessDate.AddDate(0, 0, 1)
+	if err := os.WriteFile(inputPath, []byte(`id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer
+1;Test 1;24;152;100;100;3`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := process(); !errors.Is(err, ErrAlreadyProcessed) || !strings.Contains(err.Error(), `business date "2022-10-02" matched`) || !strings.Contains(err.Error(), "pending") {
+		t.Errorf("EODProcessor.Process() error = %v, want pending %v", err, ErrAlreadyProcessed)
+	}
+}
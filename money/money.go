@@ -0,0 +1,241 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// Scale is the number of decimal places kept exactly by Amount.
+	Scale = 4
+	// unit is the amount of units representing 1.
+	unit = 10000
+	// minimumDisplayedDecimals is the minimum decimal places displayed for non whole amount.
+	minimumDisplayedDecimals = 2
+)
+
+// RoundingMode represent how an amount that can't be represented exactly is rounded.
+type RoundingMode int
+
+const (
+	// RoundHalfEven round to the nearest neighbour, or to the even neighbour when equidistant.
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp round to the nearest neighbour, or away from zero when equidistant.
+	RoundHalfUp
+	// RoundDown round toward zero, which truncate the discarded digits.
+	RoundDown
+)
+
+var (
+	roundingModeNames = map[string]RoundingMode{
+		"half-even": RoundHalfEven,
+		"half-up":   RoundHalfUp,
+		"down":      RoundDown,
+	}
+
+	ErrInvalidAmount       = errors.New("invalid amount provided")
+	ErrTooManyDecimals     = fmt.Errorf("amount has more than %d decimal places", Scale)
+	ErrAmountOutOfRange    = errors.New("amount is out of range")
+	ErrInvalidRoundingMode = errors.New("invalid rounding mode provided")
+)
+
+// ParseRoundingMode will return rounding mode given its name.
+// Valid names are "half-even", "half-up" and "down".
+func ParseRoundingMode(name string) (RoundingMode, error) {
+	mode, ok := roundingModeNames[name]
+	if !ok {
+		return 0, fmt.Errorf(`%w "%s"`, ErrInvalidRoundingMode, name)
+	}
+	return mode, nil
+}
+
+// Amount represent an exact fixed-point decimal money amount with Scale decimal places.
+// The zero value is an amount of 0.
+type Amount struct {
+	units int64
+}
+
+// FromInt will return an Amount representing given whole value.
+func FromInt(value int64) Amount {
+	return Amount{
+		units: value * unit,
+	}
+}
+
+// Parse will parse given decimal string such as "197", "-12.5" or "197.5025" into an Amount.
+// Will return error if the value has more than Scale decimal places instead of silently rounding it.
+func Parse(value string) (Amount, error) {
+	digits := value
+	negative := false
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		negative = digits[0] == '-'
+		digits = digits[1:]
+	}
+	whole, fraction, hasFraction := strings.Cut(digits, ".")
+	if len(whole) == 0 || (hasFraction && len(fraction) == 0) {
+		return Amount{}, fmt.Errorf(`%w "%s"`, ErrInvalidAmount, value)
+	}
+	if len(fraction) > Scale {
+		return Amount{}, fmt.Errorf(`%w "%s"`, ErrTooManyDecimals, value)
+	}
+	var units int64
+	for _, char := range whole + fraction + strings.Repeat("0", Scale-len(fraction)) {
+		if char < '0' || char > '9' {
+			return Amount{}, fmt.Errorf(`%w "%s"`, ErrInvalidAmount, value)
+		}
+		if units > (math.MaxInt64-int64(char-'0'))/10 {
+			return Amount{}, fmt.Errorf(`%w "%s"`, ErrAmountOutOfRange, value)
+		}
+		units = units*10 + int64(char-'0')
+	}
+	if negative {
+		units = -units
+	}
+	return Amount{
+		units: units,
+	}, nil
+}
+
+// MustParse is like Parse but panic on invalid value.
+// Should only be used for constant value.
+func MustParse(value string) Amount {
+	amount, err := Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return amount
+}
+
+// Add will return the sum of the amount and given amount.
+func (a Amount) Add(b Amount) Amount {
+	return Amount{
+		units: a.units + b.units,
+	}
+}
+
+// Sub will return the difference of the amount and given amount.
+func (a Amount) Sub(b Amount) Amount {
+	return Amount{
+		units: a.units - b.units,
+	}
+}
+
+// Div will return the amount divided by given divisor.
+// The result is rounded to Scale decimal places using given rounding mode.
+func (a Amount) Div(divisor int64, mode RoundingMode) Amount {
+	return Amount{
+		units: divRound(a.units, divisor, mode),
+	}
+}
+
+// Round will return the amount rounded to given decimal places using given rounding mode.
+func (a Amount) Round(places int, mode RoundingMode) Amount {
+	if places >= Scale {
+		return a
+	}
+	factor := int64(math.Pow10(Scale - places))
+	return Amount{
+		units: divRound(a.units, factor, mode) * factor,
+	}
+}
+
+// Cmp will compare the amount with given amount.
+// Will return -1 if the amount is less than, 0 if equal to and +1 if greater than given amount.
+func (a Amount) Cmp(b Amount) int {
+	switch {
+	case a.units < b.units:
+		return -1
+	case a.units > b.units:
+		return 1
+	}
+	return 0
+}
+
+// IsNegative will return true if the amount is less than zero.
+func (a Amount) IsNegative() bool {
+	return a.units < 0
+}
+
+// String will format the amount as decimal string.
+// Whole amount is formatted without decimal places, otherwise at least 2 decimal places are displayed.
+func (a Amount) String() string {
+	if a.units%unit == 0 {
+		return a.StringFixed(0)
+	}
+	formatted := strings.TrimRight(a.StringFixed(Scale), "0")
+	if decimals := len(formatted) - strings.IndexByte(formatted, '.') - 1; decimals < minimumDisplayedDecimals {
+		formatted += strings.Repeat("0", minimumDisplayedDecimals-decimals)
+	}
+	return formatted
+}
+
+// StringFixed will format the amount as decimal string with exactly given decimal places.
+// The amount is rounded half even if it has more decimal places than requested.
+func (a Amount) StringFixed(places int) string {
+	if places > Scale {
+		places = Scale
+	}
+	units := a.Round(places, RoundHalfEven).units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+	}
+	whole := units / unit
+	fraction := units % unit
+	if whole < 0 {
+		whole = -whole
+	}
+	if fraction < 0 {
+		fraction = -fraction
+	}
+	if places == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	fraction /= int64(math.Pow10(Scale - places))
+	return fmt.Sprintf("%s%d.%0*d", sign, whole, places, fraction)
+}
+
+// MarshalJSON will encode the amount as JSON number.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON will decode the amount from JSON number or string.
+func (a *Amount) UnmarshalJSON(payload []byte) error {
+	value := strings.Trim(string(payload), `"`)
+	amount, err := Parse(value)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// divRound will divide given dividend by given divisor and round the result using given rounding mode.
+func divRound(dividend, divisor int64, mode RoundingMode) int64 {
+	negative := (dividend < 0) != (divisor < 0)
+	if dividend < 0 {
+		dividend = -dividend
+	}
+	if divisor < 0 {
+		divisor = -divisor
+	}
+	quotient := dividend / divisor
+	remainder := dividend % divisor
+	switch mode {
+	case RoundHalfUp:
+		if remainder*2 >= divisor {
+			quotient++
+		}
+	case RoundHalfEven:
+		if remainder*2 > divisor || (remainder*2 == divisor && quotient%2 == 1) {
+			quotient++
+		}
+	}
+	if negative {
+		return -quotient
+	}
+	return quotient
+}
@@ -0,0 +1,170 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr error
+	}{
+		{"Given whole value then it must succeed", "197", "197", nil},
+		{"Given two decimals value then it must succeed", "197.50", "197.50", nil},
+		{"Given four decimals value then it must succeed", "197.5025", "197.5025", nil},
+		{"Given negative value then it must succeed", "-12.5", "-12.50", nil},
+		{"Given negative fraction value then it must succeed", "-0.05", "-0.05", nil},
+		{"Given positive sign then it must succeed", "+3", "3", nil},
+		{"Given too many decimals then it must fail", "1.23456", "", ErrTooManyDecimals},
+		{"Given empty value then it must fail", "", "", ErrInvalidAmount},
+		{"Given bad value then it must fail", "BAD", "", ErrInvalidAmount},
+		{"Given missing fraction then it must fail", "1.", "", ErrInvalidAmount},
+		{"Given missing whole then it must fail", ".5", "", ErrInvalidAmount},
+		{"Given too big value then it must fail", "922337203685478", "", ErrAmountOutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.String() != tt.want {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmount_Div(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  Amount
+		divisor int64
+		mode    RoundingMode
+		want    Amount
+	}{
+		{"Given exact result then it must not round", MustParse("299"), 2, RoundDown, MustParse("149.5")},
+		{"Given half even on odd then it must round up", MustParse("0.0003"), 2, RoundHalfEven, MustParse("0.0002")},
+		{"Given half even on even then it must round down", MustParse("0.0005"), 2, RoundHalfEven, MustParse("0.0002")},
+		{"Given half up then it must round up", MustParse("0.0005"), 2, RoundHalfUp, MustParse("0.0003")},
+		{"Given down then it must truncate", MustParse("0.0005"), 2, RoundDown, MustParse("0.0002")},
+		{"Given negative half up then it must round away from zero", MustParse("-0.0005"), 2, RoundHalfUp, MustParse("-0.0003")},
+		{"Given negative down then it must round toward zero", MustParse("-0.0005"), 2, RoundDown, MustParse("-0.0002")},
+		{"Given third then it must round half even", MustParse("100"), 3, RoundHalfEven, MustParse("33.3333")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.amount.Div(tt.divisor, tt.mode); got != tt.want {
+				t.Errorf("Amount.Div() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmount_Round(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount Amount
+		places int
+		mode   RoundingMode
+		want   Amount
+	}{
+		{"Given half even then it must round to even", MustParse("2.125"), 2, RoundHalfEven, MustParse("2.12")},
+		{"Given half up then it must round up", MustParse("2.125"), 2, RoundHalfUp, MustParse("2.13")},
+		{"Given down then it must truncate", MustParse("2.129"), 2, RoundDown, MustParse("2.12")},
+		{"Given zero places then it must round to whole", MustParse("2.5"), 0, RoundHalfEven, MustParse("2")},
+		{"Given more places than scale then it must be unchanged", MustParse("2.1234"), 6, RoundDown, MustParse("2.1234")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.amount.Round(tt.places, tt.mode); got != tt.want {
+				t.Errorf("Amount.Round() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmount_StringFixed(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount Amount
+		places int
+		want   string
+	}{
+		{"Given whole value then it must pad decimals", MustParse("197"), 2, "197.00"},
+		{"Given more decimals then it must round", MustParse("197.505"), 2, "197.50"},
+		{"Given negative value then it must keep sign", MustParse("-0.5"), 2, "-0.50"},
+		{"Given zero places then it must not have point", MustParse("197.5"), 0, "198"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.amount.StringFixed(tt.places); got != tt.want {
+				t.Errorf("Amount.StringFixed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmount_Cmp(t *testing.T) {
+	tests := []struct {
+		name string
+		a    Amount
+		b    Amount
+		want int
+	}{
+		{"Given less amount then it must be -1", MustParse("99.99"), FromInt(100), -1},
+		{"Given equal amount then it must be 0", MustParse("100.00"), FromInt(100), 0},
+		{"Given greater amount then it must be 1", MustParse("100.0001"), FromInt(100), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Cmp(tt.b); got != tt.want {
+				t.Errorf("Amount.Cmp() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmount_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    Amount
+		wantErr bool
+	}{
+		{"Given number then it must succeed", `197.5`, MustParse("197.5"), false},
+		{"Given string then it must succeed", `"197.50"`, MustParse("197.5"), false},
+		{"Given bad value then it must fail", `"BAD"`, Amount{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Amount
+			err := json.Unmarshal([]byte(tt.payload), &got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Amount.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Amount.UnmarshalJSON() = %v, want %v", got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+			payload, err := json.Marshal(got)
+			if err != nil {
+				t.Errorf("Amount.MarshalJSON() error = %v", err)
+			}
+			if string(payload) != got.String() {
+				t.Errorf("Amount.MarshalJSON() = %s, want %v", payload, got.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package bankeodprocessor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+// OutputOrder represent the order of the rows written into the output.
+type OutputOrder string
+
+const (
+	// OutputOrderInput write the processed rows in input order regardless of the order they finish.
+	// Rows of the output template keep their position and new rows follow them.
+	OutputOrderInput OutputOrder = "input"
+	// OutputOrderID write every row of the output, including the rows of the output template, sorted by account id.
+	// Account ids are compared numerically if both are integer, otherwise as text.
+	OutputOrderID OutputOrder = "id"
+)
+
+var (
+	outputOrders = []OutputOrder{
+		OutputOrderInput, OutputOrderID,
+	}
+
+	ErrInvalidOutputOrder       = errors.New("invalid output order provided")
+	ErrOutputOrderNotStreamable = errors.New("output order can't be used in streaming mode")
+)
+
+// ParseOutputOrder will return the output order given its name.
+// Valid names are "input" and "id".
+func ParseOutputOrder(name string) (OutputOrder, error) {
+	for _, order := range outputOrders {
+		if string(order) == name {
+			return order, nil
+		}
+	}
+	return "", fmt.Errorf(`%w "%s"`, ErrInvalidOutputOrder, name)
+}
+
+// sortRows will sort given output rows following given order, the header is kept first.
+// Rows are already in input order for OutputOrderInput and are left untouched.
+func sortRows(outputRows [][]string, order OutputOrder) {
+	if order != OutputOrderID {
+		return
+	}
+	rows := outputRows[1:]
+	sort.SliceStable(rows, func(i, j int) bool {
+		return pipeline.CompareAccountID(rows[i][afterEodHeaderIdxID], rows[j][afterEodHeaderIdxID]) < 0
+	})
+}
+
+// reorderBuffer represent a buffer releasing finished rows in index order regardless of the order they finish.
+// Rows are held until every row of a lower index has been released or skipped.
+// Indexes must be pushed into the pipeline in ascending order, and an index which is never pushed
+// must be skipped before any higher index is pushed.
+type reorderBuffer struct {
+	mutex   sync.Mutex
+	next    int
+	pending map[int]*pipeline.EODRowData
+	skipped map[int]bool
+}
+
+// newReorderBuffer will return a new reorderBuffer which first released row has given index.
+func newReorderBuffer(first int) *reorderBuffer {
+	return &reorderBuffer{
+		next:    first,
+		pending: make(map[int]*pipeline.EODRowData),
+		skipped: make(map[int]bool),
+	}
+}
+
+// skip will mark given index as never pushed so the rows after it aren't held for it.
+func (b *reorderBuffer) skip(index int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.skipped[index] = true
+}
+
+// add will hold given finished data and return every held data that can be released in index order.
+// Will return an empty slice if a row of a lower index hasn't finished yet.
+func (b *reorderBuffer) add(data *pipeline.EODRowData) []*pipeline.EODRowData {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.pending[data.Index] = data
+	released := []*pipeline.EODRowData{}
+	for {
+		if b.skipped[b.next] {
+			delete(b.skipped, b.next)
+			b.next++
+			continue
+		}
+		ready, exist := b.pending[b.next]
+		if !exist {
+			return released
+		}
+		delete(b.pending, b.next)
+		released = append(released, ready)
+		b.next++
+	}
+}
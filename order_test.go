@@ -0,0 +1,183 @@
+package bankeodprocessor
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/format"
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+func TestParseOutputOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   string
+		want    OutputOrder
+		wantErr bool
+	}{
+		{"Given input order then it must succeed", "input", OutputOrderInput, false},
+		{"Given id order then it must succeed", "id", OutputOrderID, false},
+		{"Given unknown order then it must fail", "balanced", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOutputOrder(tt.order)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOutputOrder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && !errors.Is(err, ErrInvalidOutputOrder) {
+				t.Errorf("ParseOutputOrder() error = %v, want %v", err, ErrInvalidOutputOrder)
+			}
+			if got != tt.want {
+				t.Errorf("ParseOutputOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReorderBuffer(t *testing.T) {
+	tests := []struct {
+		name     string
+		skipped  []int
+		finished []int
+		want     [][]int
+	}{
+		{
+			"Given rows finishing in order then each row must be released right away",
+			nil,
+			[]int{0, 1, 2},
+			[][]int{{0}, {1}, {2}},
+		},
+		{
+			"Given rows finishing out of order then they must be held until the previous rows finish",
+			nil,
+			[]int{2, 1, 0, 3},
+			[][]int{{}, {}, {0, 1, 2}, {3}},
+		},
+		{
+			"Given skipped index then it must not hold the next rows",
+			[]int{1, 3},
+			[]int{4, 2, 0},
+			[][]int{{}, {}, {0, 2, 4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := newReorderBuffer(0)
+			for _, index := range tt.skipped {
+				buffer.skip(index)
+			}
+			got := [][]int{}
+			for _, index := range tt.finished {
+				released := []int{}
+				for _, data := range buffer.add(&pipeline.EODRowData{Index: index}) {
+					released = append(released, data.Index)
+				}
+				got = append(got, released)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reorderBuffer.add() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEODProcessor_ProcessStreamOrder(t *testing.T) {
+	rows := []string{"id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer"}
+	wantIDs := []string{}
+	for id := 1; id <= 2000; id++ {
+		// Duplicates are skipped without holding the next rows.
+		if id%100 == 0 {
+			rows = append(rows, fmt.Sprintf("%d;Test;25;100;100;100;2", id-1))
+		}
+		rows = append(rows, fmt.Sprintf("%d;Test;25;%d;100;100;2", id, id%300))
+		wantIDs = append(wantIDs, fmt.Sprint(id))
+	}
+	input := strings.Join(rows, "\n")
+	bonusDistributor := pipeline.NewBonusDistributor(nil)
+	benefitCalculator := pipeline.NewBenefitCalculator(bonusDistributor.Channel())
+	averageCalculator := pipeline.NewAverageCalculator(benefitCalculator.Channel())
+	parser := NewParser(averageCalculator.Channel())
+	eodCalculator := NewEODProcessor(pipeline.NewChain(parser, averageCalculator, benefitCalculator, bonusDistributor), WithDuplicatePolicy(DuplicatePolicyKeepFirst), WithIDTracking(true))
+	defer eodCalculator.Close()
+	output := &bytes.Buffer{}
+	if _, err := eodCalculator.ProcessStream(context.Background(), strings.NewReader(input), output); err != nil {
+		t.Fatalf("EODProcessor.ProcessStream() error = %v, want nil", err)
+	}
+	reader := csv.NewReader(output)
+	reader.Comma = ';'
+	got, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotIDs := []string{}
+	for _, row := range got[1:] {
+		gotIDs = append(gotIDs, row[afterEodHeaderIdxID])
+	}
+	if !reflect.DeepEqual(gotIDs, wantIDs) {
+		t.Errorf("EODProcessor.ProcessStream() ids are not in input order")
+	}
+
+	eodCalculator.outputOrder = OutputOrderID
+	if _, err := eodCalculator.ProcessStream(context.Background(), strings.NewReader(input), &bytes.Buffer{}); !errors.Is(err, ErrOutputOrderNotStreamable) {
+		t.Errorf("EODProcessor.ProcessStream() error = %v, want %v", err, ErrOutputOrderNotStreamable)
+	}
+	eodCalculator.outputOrder = OutputOrderInput
+	eodCalculator.outputFormat = format.Columnar
+	if _, err := eodCalculator.ProcessStream(context.Background(), strings.NewReader(input), &bytes.Buffer{}); !errors.Is(err, ErrOutputFormatNotStreamable) {
+		t.Errorf("EODProcessor.ProcessStream() error = %v, want %v", err, ErrOutputFormatNotStreamable)
+	}
+}
+
+func TestEODProcessor_ProcessSliceOrder(t *testing.T) {
+	inputRows := [][]string{
+		beforeEodCSVHeader,
+		{"10", "Test 10", "24", "100", "100", "100", "3"},
+		{"2", "Test 2", "25", "100", "100", "100", "2"},
+		{"B", "Test B", "25", "100", "100", "100", "2"},
+		{"1", "Test 1", "25", "100", "100", "100", "2"},
+	}
+	outputRows := [][]string{
+		afterEodCSVHeader,
+		{"A", "Test A", "24", "100", "", "", "100", "100", "", "3", ""},
+		{"2", "Test 2", "25", "100", "", "", "100", "100", "", "2", ""},
+	}
+	tests := []struct {
+		name  string
+		order OutputOrder
+		want  []string
+	}{
+		{"Given input order then the template must be followed by new rows in input order", OutputOrderInput, []string{"A", "2", "10", "B", "1"}},
+		{"Given id order then every row must be sorted by account id", OutputOrderID, []string{"1", "2", "10", "A", "B"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			averageCalculator := pipeline.NewAverageCalculator(nil)
+			parser := NewParser(averageCalculator.Channel())
+			eodCalculator := NewEODProcessor(pipeline.NewChain(parser, averageCalculator), WithOutputOrder(tt.order))
+			defer eodCalculator.Close()
+			template := make([][]string, len(outputRows))
+			for idx, row := range outputRows {
+				template[idx] = append([]string{}, row...)
+			}
+			result, _, err := eodCalculator.ProcessSlice(context.Background(), inputRows, template)
+			if err != nil {
+				t.Fatalf("EODProcessor.ProcessSlice() error = %v, want nil", err)
+			}
+			got := []string{}
+			for _, row := range result[1:] {
+				got = append(got, row[afterEodHeaderIdxID])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EODProcessor.ProcessSlice() ids = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,62 +1,136 @@
 package bankeodprocessor
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"runtime"
 	"strconv"
+	"strings"
 
+	"github.com/firmanmm/bank-eod-processor/money"
 	"github.com/firmanmm/bank-eod-processor/pipeline"
 )
 
+// ParserStageName is the name of the Parser stage used on failed row report and the pipeline config.
+const ParserStageName = "parser"
+
 // Parser represent CSV Parser pipeline for EOD operation.
 // Will read from input row in the pipeline and write it as parsed value.
 // Will return error and terminate pipeline for current flow if encounter error.
 type Parser struct {
-	*pipeline.WorkerPool
-	next chan<- *pipeline.EODRowData
+	*pipeline.StageRunner
 }
 
 // NewParser will return a new Parser.
 func NewParser(next chan<- *pipeline.EODRowData) *Parser {
-	parser := &Parser{
-		next: next,
-	}
-	pool := pipeline.NewWorkerPool(runtime.NumCPU(), parser.Execute)
-	parser.WorkerPool = pool
+	return newParser(next, runtime.NumCPU())
+}
+
+// newParser will return a new Parser with given amount of worker.
+func newParser(next chan<- *pipeline.EODRowData, parallelism int) *Parser {
+	parser := &Parser{}
+	parser.StageRunner = pipeline.NewStageRunner(parser, next, parallelism)
 	return parser
 }
 
+// Name will return ParserStageName.
+func (p *Parser) Name() string {
+	return ParserStageName
+}
+
+// newParserStage will return a new Parser for the pipeline config, the Parser doesn't accept any params.
+func newParserStage(next chan<- *pipeline.EODRowData, params json.RawMessage, parallelism int) (pipeline.IPipeline, error) {
+	if len(params) > 0 {
+		return nil, fmt.Errorf("%w, stage doesn't accept params", pipeline.ErrInvalidPipelineConfig)
+	}
+	return newParser(next, pipeline.ParallelismOr(parallelism, runtime.NumCPU())), nil
+}
+
+// DefaultPipelineConfig will return the pipeline config used when no config is provided.
+// Every stage use its default params and parallelism.
+func DefaultPipelineConfig() *pipeline.PipelineConfig {
+	return &pipeline.PipelineConfig{
+		Stages: []pipeline.StageConfig{
+			{Name: ParserStageName},
+			{Name: pipeline.ValidatorStageName},
+			{Name: pipeline.AverageCalculatorStageName},
+			{Name: pipeline.BenefitCalculatorStageName},
+			{Name: pipeline.BonusDistributorStageName},
+		},
+	}
+}
+
+func init() {
+	pipeline.RegisterParserStage(ParserStageName, newParserStage)
+}
+
 // Execute will process current data in the pipeline stage.
 // In this case will parse and set the parsed data into the pipeline for further
 // operation
-func (p *Parser) Execute(workerID int, data *pipeline.EODRowData) {
+func (p *Parser) Execute(ctx context.Context, data *pipeline.EODRowData) error {
+	return parseInputRow(data)
+}
+
+// parseInputRow will parse the input row of given data and set the parsed value into the data.
+// Will return error and leave the data untouched if any of the column is invalid or the currency isn't
+// the run currency, except the age which error is set as the AgeError of the data instead.
+func parseInputRow(data *pipeline.EODRowData) error {
+	if err := checkCurrency(data); err != nil {
+		return err
+	}
 	inputRow := data.InputRow
-	balanced, err := strconv.Atoi(inputRow[beforeEodHeaderIdxBalanced])
+	balanced, err := money.Parse(inputRow[beforeEodHeaderIdxBalanced])
 	if err != nil {
-		data.Error = err
-		data.FinishChannel <- data
-		return
+		return err
 	}
-	previousBalanced, err := strconv.Atoi(inputRow[beforeEodHeaderIdxPreviousBalanced])
+	previousBalanced, err := money.Parse(inputRow[beforeEodHeaderIdxPreviousBalanced])
 	if err != nil {
-		data.Error = err
-		data.FinishChannel <- data
-		return
+		return err
 	}
 	freeTransfer, err := strconv.Atoi(inputRow[beforeEodHeaderIdxFreeTransfer])
 	if err != nil {
-		data.Error = err
-		data.FinishChannel <- data
-		return
+		return err
 	}
-	averageBalance, err := strconv.Atoi(inputRow[beforeEodHeaderIdxAverageBalanced])
+	averageBalance, err := money.Parse(inputRow[beforeEodHeaderIdxAverageBalanced])
 	if err != nil {
-		data.Error = err
-		data.FinishChannel <- data
-		return
+		return err
 	}
+	data.ID = inputRow[beforeEodHeaderIdxID]
+	data.Name = inputRow[beforeEodHeaderIdxNama]
+	data.Age, data.AgeError = parseAge(inputRow[beforeEodHeaderIdxAge])
 	data.Balanced = balanced
 	data.PreviousBalanced = previousBalanced
 	data.FreeTransfer = freeTransfer
 	data.AverageBalanced = averageBalance
-	p.next <- data
+	return nil
+}
+
+// checkCurrency will return ErrCurrencyMismatch if the currency column of given data isn't the currency of its run.
+// Input without currency column and run without currency are not checked.
+func checkCurrency(data *pipeline.EODRowData) error {
+	if data.Run == nil || len(data.Run.Currency) == 0 {
+		return nil
+	}
+	for idx, column := range data.Run.InputHeader {
+		if columnKey(column) != columnKey(currencyColumn) || idx >= len(data.InputRow) {
+			continue
+		}
+		if currency := strings.TrimSpace(data.InputRow[idx]); !strings.EqualFold(currency, data.Run.Currency) {
+			return fmt.Errorf(`%w "%s", run currency is "%s"`, ErrCurrencyMismatch, currency, data.Run.Currency)
+		}
+		return nil
+	}
+	return nil
+}
+
+// parseAge will parse given age column.
+// Will return zero and pipeline.ErrInvalidAge if the age is not an integer, the row is not rejected
+// here since only the validator and the bonus eligibility use the age.
+func parseAge(value string) (int, error) {
+	age, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf(`%w "%s"`, pipeline.ErrInvalidAge, value)
+	}
+	return age, nil
 }
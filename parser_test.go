@@ -1,13 +1,18 @@
 package bankeodprocessor
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/firmanmm/bank-eod-processor/money"
 	"github.com/firmanmm/bank-eod-processor/pipeline"
 )
 
-func TestParser_Execute(t *testing.T) {
+func TestParser_Handle(t *testing.T) {
 	type args struct {
 		workerID int
 		data     *pipeline.EODRowData
@@ -40,10 +45,97 @@ func TestParser_Execute(t *testing.T) {
 				OutputRow: []string{
 					"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 				},
+				ID:               "1",
+				Name:             "Test 1",
+				Age:              24,
 				FreeTransfer:     5,
-				AverageBalanced:  4,
-				PreviousBalanced: 3,
-				Balanced:         2,
+				AverageBalanced:  money.FromInt(4),
+				PreviousBalanced: money.FromInt(3),
+				Balanced:         money.FromInt(2),
+				Workers:          map[string]int{ParserStageName: 1},
+			},
+			false,
+		},
+		{
+			"Given decimal balance then it must succeed",
+			args{
+				workerID: 1,
+				data: &pipeline.EODRowData{
+					Index: 1,
+					InputRow: []string{
+						"1", "Test 1", "24", "197.50", "3.1234", "4.5", "5",
+					},
+					OutputRow: []string{
+						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
+					},
+				},
+			},
+			&pipeline.EODRowData{
+				Index: 1,
+				InputRow: []string{
+					"1", "Test 1", "24", "197.50", "3.1234", "4.5", "5",
+				},
+				OutputRow: []string{
+					"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
+				},
+				ID:               "1",
+				Name:             "Test 1",
+				Age:              24,
+				FreeTransfer:     5,
+				AverageBalanced:  money.MustParse("4.5"),
+				PreviousBalanced: money.MustParse("3.1234"),
+				Balanced:         money.MustParse("197.50"),
+				Workers:          map[string]int{ParserStageName: 1},
+			},
+			false,
+		},
+		{
+			"Given too many decimals balance then it must fail",
+			args{
+				workerID: 1,
+				data: &pipeline.EODRowData{
+					Index: 1,
+					InputRow: []string{
+						"1", "Test 1", "24", "197.12345", "3", "4", "5",
+					},
+					OutputRow: []string{
+						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
+					},
+				},
+			},
+			nil,
+			true,
+		},
+		{
+			"Given bad age then it must succeed with age error",
+			args{
+				workerID: 1,
+				data: &pipeline.EODRowData{
+					Index: 1,
+					InputRow: []string{
+						"1", "Test 1", "BAD", "2", "3", "4", "5",
+					},
+					OutputRow: []string{
+						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
+					},
+				},
+			},
+			&pipeline.EODRowData{
+				Index: 1,
+				InputRow: []string{
+					"1", "Test 1", "BAD", "2", "3", "4", "5",
+				},
+				OutputRow: []string{
+					"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
+				},
+				ID:               "1",
+				Name:             "Test 1",
+				AgeError:         fmt.Errorf(`%w "%s"`, pipeline.ErrInvalidAge, "BAD"),
+				FreeTransfer:     5,
+				AverageBalanced:  money.FromInt(4),
+				PreviousBalanced: money.FromInt(3),
+				Balanced:         money.FromInt(2),
+				Workers:          map[string]int{ParserStageName: 1},
 			},
 			false,
 		},
@@ -115,6 +207,24 @@ func TestParser_Execute(t *testing.T) {
 			nil,
 			true,
 		},
+		{
+			"Given cancelled run then it must fail",
+			args{
+				workerID: 1,
+				data: &pipeline.EODRowData{
+					Index: 1,
+					InputRow: []string{
+						"1", "Test 1", "24", "2", "3", "4", "5",
+					},
+					OutputRow: []string{
+						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
+					},
+					Run: newCanceledRun(),
+				},
+			},
+			nil,
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -123,16 +233,109 @@ func TestParser_Execute(t *testing.T) {
 			if tt.wantErr {
 				tt.args.data.FinishChannel = res
 			}
-			parser.Execute(tt.args.workerID, tt.args.data)
+			parser.Handle(tt.args.workerID, tt.args.data)
 			got := <-res
 			if tt.wantErr {
 				if !reflect.DeepEqual(tt.wantErr, got.Error != nil) {
-					t.Errorf("Parser.Execute() err check = %v, want %v", got.Error != nil, tt.wantErr)
+					t.Errorf("Parser.Handle() err check = %v, want %v", got.Error != nil, tt.wantErr)
 				}
 				return
 			}
 			if !reflect.DeepEqual(tt.want, got) {
-				t.Errorf("Parser.Execute() = %v, want %v", got, tt.want)
+				t.Errorf("Parser.Handle() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newCanceledRun return a run which context is already cancelled.
+func newCanceledRun() *pipeline.Run {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return pipeline.NewRun(ctx, nil)
+}
+
+func TestDefaultPipelineConfig(t *testing.T) {
+	inputRows := [][]string{
+		{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer"},
+		{"1", "Test 1", "24", "151", "100", "100", "3"},
+		{"2", "Test 2", "25", "150", "150", "100", "2"},
+		{"3", "Test 3", "200", "100", "150", "100", "2"},
+	}
+	tests := []struct {
+		name       string
+		config     *pipeline.PipelineConfig
+		want       [][]string
+		wantFailed int
+	}{
+		{
+			"Given default config then it must process every stage",
+			DefaultPipelineConfig(),
+			[][]string{
+				{"1", "186", "125.50", "3"},
+				{"2", "160", "150", "5"},
+			},
+			1,
+		},
+		{
+			"Given only the parser then it must keep the input values",
+			&pipeline.PipelineConfig{Stages: []pipeline.StageConfig{{Name: ParserStageName, Parallelism: 1}}},
+			[][]string{
+				{"1", "151", "100", "3"},
+				{"2", "150", "100", "2"},
+				{"3", "100", "100", "2"},
+			},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := pipeline.BuildPipeline(tt.config)
+			if err != nil {
+				t.Fatalf("pipeline.BuildPipeline() error = %v, want nil", err)
+			}
+			eodCalculator := NewEODProcessor(chain)
+			defer eodCalculator.Close()
+			result, report, err := eodCalculator.ProcessSlice(context.Background(), inputRows, [][]string{afterEodCSVHeader})
+			if err != nil {
+				t.Fatalf("EODProcessor.ProcessSlice() error = %v, want nil", err)
+			}
+			got := [][]string{}
+			for _, row := range result[1:] {
+				got = append(got, []string{
+					row[afterEodHeaderIdxID],
+					row[afterEodHeaderIdxBalanced],
+					row[afterEodHeaderIdxAverageBalanced],
+					row[afterEodHeaderIdxFreeTransfer],
+				})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EODProcessor.ProcessSlice() = %v, want %v", got, tt.want)
+			}
+			if len(report.FailedRows) != tt.wantFailed {
+				t.Errorf("EODProcessor.ProcessSlice() failed rows = %v, want %v", len(report.FailedRows), tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestPipelineConfig_ValidateParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr error
+	}{
+		{"Given parser first then it must succeed", `{"stages": [{"name": "parser"}, {"name": "validator"}]}`, nil},
+		{"Given disabled stage before the parser then it must succeed", `{"stages": [{"name": "validator", "disabled": true}, {"name": "parser"}]}`, nil},
+		{"Given no parser then it must fail", `{"stages": [{"name": "validator"}, {"name": "average-calculator"}]}`, pipeline.ErrInvalidPipelineConfig},
+		{"Given parser not first then it must fail", `{"stages": [{"name": "validator"}, {"name": "parser"}]}`, pipeline.ErrInvalidPipelineConfig},
+		{"Given disabled parser then it must fail", `{"stages": [{"name": "parser", "disabled": true}, {"name": "validator"}]}`, pipeline.ErrInvalidPipelineConfig},
+		{"Given parser twice then it must fail", `{"stages": [{"name": "parser"}, {"name": "parser"}]}`, pipeline.ErrInvalidPipelineConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := pipeline.ParsePipelineConfig(strings.NewReader(tt.config)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("pipeline.ParsePipelineConfig() error = %v, want %v", err, tt.wantErr)
 			}
 		})
 	}
@@ -1,30 +1,50 @@
 package pipeline
 
+import (
+	"context"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
+
+// AverageCalculatorStageName is the name of the AverageCalculator stage used on the pipeline config.
+const AverageCalculatorStageName = "average-calculator"
+
 // AverageCalculator represent pipeline stage that perform
 // calculation of average of previous balanced and current balanced.
 type AverageCalculator struct {
-	*WorkerPool
-	next chan<- *EODRowData
+	*StageRunner
+	rounding money.RoundingMode
 }
 
-// NewAverageCalculator return a new AverageCalculator.
+// NewAverageCalculator return a new AverageCalculator
+// which round the average using money.RoundHalfEven.
 func NewAverageCalculator(next chan<- *EODRowData) *AverageCalculator {
+	return NewAverageCalculatorWithRounding(next, money.RoundHalfEven)
+}
+
+// NewAverageCalculatorWithRounding return a new AverageCalculator
+// which round the average using given rounding mode.
+func NewAverageCalculatorWithRounding(next chan<- *EODRowData, rounding money.RoundingMode) *AverageCalculator {
+	return newAverageCalculator(next, rounding, getOptimumParallelism())
+}
+
+// newAverageCalculator will return a new AverageCalculator with given amount of worker.
+func newAverageCalculator(next chan<- *EODRowData, rounding money.RoundingMode, parallelism int) *AverageCalculator {
 	calculator := &AverageCalculator{
-		next: next,
+		rounding: rounding,
 	}
-	pool := NewWorkerPool(getOptimumParallelism(), calculator.Execute)
-	calculator.WorkerPool = pool
+	calculator.StageRunner = NewStageRunner(calculator, next, parallelism)
 	return calculator
 }
 
+// Name will return AverageCalculatorStageName.
+func (a *AverageCalculator) Name() string {
+	return AverageCalculatorStageName
+}
+
 // Execute will process current data in the pipeline stage.
 // In this case will average previous balanced and current balanced.
-func (a *AverageCalculator) Execute(workerID int, data *EODRowData) {
-	data.ThreadNo1 = workerID
-	data.AverageBalanced = (data.PreviousBalanced + data.Balanced) / 2
-	if a.next != nil {
-		a.next <- data
-	} else {
-		data.FinishChannel <- data
-	}
+func (a *AverageCalculator) Execute(ctx context.Context, data *EODRowData) error {
+	data.AverageBalanced = data.PreviousBalanced.Add(data.Balanced).Div(2, a.rounding)
+	return nil
 }
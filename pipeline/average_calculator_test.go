@@ -3,9 +3,11 @@ package pipeline
 import (
 	"reflect"
 	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/money"
 )
 
-func TestAverageCalculator_Execute(t *testing.T) {
+func TestAverageCalculator_Handle(t *testing.T) {
 	type args struct {
 		workerID       int
 		isFinalChannel bool
@@ -29,14 +31,15 @@ func TestAverageCalculator_Execute(t *testing.T) {
 					OutputRow: []string{
 						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 					},
-					AverageBalanced:  100,
-					PreviousBalanced: 100,
-					Balanced:         200,
+					AverageBalanced:  money.FromInt(100),
+					PreviousBalanced: money.FromInt(100),
+					Balanced:         money.FromInt(200),
 					FreeTransfer:     4,
-					ThreadNo1:        111,
-					ThreadNo2A:       21,
-					ThreadNo2B:       31,
-					ThreadNo3:        41,
+					Workers: map[string]int{
+						AverageCalculatorStageName: 111,
+						BenefitCalculatorStageName: 21,
+						BonusDistributorStageName:  41,
+					},
 				},
 			},
 			&EODRowData{
@@ -47,14 +50,15 @@ func TestAverageCalculator_Execute(t *testing.T) {
 				OutputRow: []string{
 					"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 				},
-				AverageBalanced:  150,
-				PreviousBalanced: 100,
-				Balanced:         200,
+				AverageBalanced:  money.FromInt(150),
+				PreviousBalanced: money.FromInt(100),
+				Balanced:         money.FromInt(200),
 				FreeTransfer:     4,
-				ThreadNo1:        111,
-				ThreadNo2A:       21,
-				ThreadNo2B:       31,
-				ThreadNo3:        41,
+				Workers: map[string]int{
+					AverageCalculatorStageName: 111,
+					BenefitCalculatorStageName: 21,
+					BonusDistributorStageName:  41,
+				},
 			},
 			false,
 		},
@@ -71,14 +75,15 @@ func TestAverageCalculator_Execute(t *testing.T) {
 					OutputRow: []string{
 						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 					},
-					AverageBalanced:  100,
-					PreviousBalanced: 100,
-					Balanced:         200,
+					AverageBalanced:  money.FromInt(100),
+					PreviousBalanced: money.FromInt(100),
+					Balanced:         money.FromInt(200),
 					FreeTransfer:     4,
-					ThreadNo1:        111,
-					ThreadNo2A:       21,
-					ThreadNo2B:       31,
-					ThreadNo3:        41,
+					Workers: map[string]int{
+						AverageCalculatorStageName: 111,
+						BenefitCalculatorStageName: 21,
+						BonusDistributorStageName:  41,
+					},
 				},
 			},
 			&EODRowData{
@@ -89,17 +94,71 @@ func TestAverageCalculator_Execute(t *testing.T) {
 				OutputRow: []string{
 					"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 				},
-				AverageBalanced:  150,
-				PreviousBalanced: 100,
-				Balanced:         200,
+				AverageBalanced:  money.FromInt(150),
+				PreviousBalanced: money.FromInt(100),
+				Balanced:         money.FromInt(200),
 				FreeTransfer:     4,
-				ThreadNo1:        111,
-				ThreadNo2A:       21,
-				ThreadNo2B:       31,
-				ThreadNo3:        41,
+				Workers: map[string]int{
+					AverageCalculatorStageName: 111,
+					BenefitCalculatorStageName: 21,
+					BonusDistributorStageName:  41,
+				},
 			},
 			false,
 		},
+		{
+			"Given decimal balance then it must not truncate",
+			args{
+				workerID: 111,
+				data: &EODRowData{
+					Index: 1,
+					InputRow: []string{
+						"1", "Test 1", "24", "2", "3", "4", "5",
+					},
+					OutputRow: []string{
+						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
+					},
+					PreviousBalanced: money.MustParse("100.0001"),
+					Balanced:         money.MustParse("199.0002"),
+					FreeTransfer:     4,
+				},
+			},
+			&EODRowData{
+				Index: 1,
+				InputRow: []string{
+					"1", "Test 1", "24", "2", "3", "4", "5",
+				},
+				OutputRow: []string{
+					"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
+				},
+				AverageBalanced:  money.MustParse("149.5002"),
+				PreviousBalanced: money.MustParse("100.0001"),
+				Balanced:         money.MustParse("199.0002"),
+				FreeTransfer:     4,
+				Workers: map[string]int{
+					AverageCalculatorStageName: 111,
+				},
+			},
+			false,
+		},
+		{
+			"Given cancelled run then it must fail",
+			args{
+				workerID: 1,
+				data: &EODRowData{
+					Index: 1,
+					InputRow: []string{
+						"1", "Test 1", "24", "2", "3", "4", "5",
+					},
+					OutputRow: []string{
+						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
+					},
+					Run: newCanceledRun(),
+				},
+			},
+			nil,
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -113,17 +172,17 @@ func TestAverageCalculator_Execute(t *testing.T) {
 			if tt.wantErr {
 				tt.args.data.FinishChannel = res
 			}
-			calculator.Execute(tt.args.workerID, tt.args.data)
+			calculator.Handle(tt.args.workerID, tt.args.data)
 			got := <-res
 			tt.args.data.FinishChannel = nil
 			if tt.wantErr {
 				if !reflect.DeepEqual(tt.wantErr, got.Error != nil) {
-					t.Errorf("AverageCalculator.Execute() err check = %v, want %v", got.Error != nil, tt.wantErr)
+					t.Errorf("AverageCalculator.Handle() err check = %v, want %v", got.Error != nil, tt.wantErr)
 				}
 				return
 			}
 			if !reflect.DeepEqual(tt.want, got) {
-				t.Errorf("AverageCalculator.Execute() = %v, want %v", got, tt.want)
+				t.Errorf("AverageCalculator.Handle() = %v, want %v", got, tt.want)
 			}
 		})
 	}
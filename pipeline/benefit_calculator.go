@@ -1,37 +1,73 @@
 package pipeline
 
+import (
+	"context"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
+
+// BenefitCalculatorStageName is the name of the BenefitCalculator stage used on adjustments and the pipeline config.
+const BenefitCalculatorStageName = "benefit-calculator"
+
 // BenefitCalculator represent pipeline stage to
 // compute given benefit to user based on current balanced.
 type BenefitCalculator struct {
-	*WorkerPool
-	next chan<- *EODRowData
+	*StageRunner
+	rules *BenefitRules
 }
 
-// NewBenefitCalculator will return a new BenefitCalculator.
+// NewBenefitCalculator will return a new BenefitCalculator using DefaultBenefitRules.
 func NewBenefitCalculator(next chan<- *EODRowData) *BenefitCalculator {
+	return NewBenefitCalculatorWithRules(next, DefaultBenefitRules())
+}
+
+// NewBenefitCalculatorWithRules will return a new BenefitCalculator using given rules.
+// The rules must already be validated.
+func NewBenefitCalculatorWithRules(next chan<- *EODRowData, rules *BenefitRules) *BenefitCalculator {
+	return newBenefitCalculator(next, rules, getOptimumParallelism())
+}
+
+// newBenefitCalculator will return a new BenefitCalculator with given amount of worker.
+func newBenefitCalculator(next chan<- *EODRowData, rules *BenefitRules, parallelism int) *BenefitCalculator {
 	calculator := &BenefitCalculator{
-		next: next,
+		rules: rules,
 	}
-	pool := NewWorkerPool(getOptimumParallelism(), calculator.Execute)
-	calculator.WorkerPool = pool
+	calculator.StageRunner = NewStageRunner(calculator, next, parallelism)
 	return calculator
 }
 
+// Name will return BenefitCalculatorStageName.
+func (b *BenefitCalculator) Name() string {
+	return BenefitCalculatorStageName
+}
+
 // Execute will process current data in the pipeline stage.
-// In this case will give benefit to current user given below condition :
-// - Will set the free transfer to 5 if balanced is between 100 to 150
-// - Will increase the balance by 25 if balanced is more than 150
-func (b *BenefitCalculator) Execute(workerID int, data *EODRowData) {
-	if data.Balanced >= 100 && data.Balanced <= 150 {
-		data.ThreadNo2A = workerID
-		data.FreeTransfer = 5
-	} else if data.Balanced > 150 {
-		data.ThreadNo2B = workerID
-		data.Balanced += 25
-	}
-	if b.next != nil {
-		b.next <- data
-	} else {
-		data.FinishChannel <- data
+// In this case will give benefit to current user given the first rule matching its balanced.
+// Every change of the rule is recorded on the data as an adjustment named after the rule,
+// which is how the rule applied to the row reach the audit log and the reconciliation.
+func (b *BenefitCalculator) Execute(ctx context.Context, data *EODRowData) error {
+	if rule := b.rules.Match(data.Balanced); rule != nil {
+		if rule.Actions.SetFreeTransfer != nil {
+			data.Adjustments = append(data.Adjustments, Adjustment{
+				Stage: BenefitCalculatorStageName,
+				Rule:  rule.Name,
+				Field: FieldFreeTransfer,
+				Old:   money.FromInt(int64(data.FreeTransfer)),
+				New:   money.FromInt(int64(*rule.Actions.SetFreeTransfer)),
+			})
+			data.FreeTransfer = *rule.Actions.SetFreeTransfer
+		}
+		if rule.Actions.AddBalanced != nil {
+			balanced := data.Balanced.Add(*rule.Actions.AddBalanced)
+			data.Adjustments = append(data.Adjustments, Adjustment{
+				Stage: BenefitCalculatorStageName,
+				Rule:  rule.Name,
+				Field: FieldBalanced,
+				Old:   data.Balanced,
+				New:   balanced,
+			})
+			data.Balanced = balanced
+		}
 	}
+	return nil
 }
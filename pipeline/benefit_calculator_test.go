@@ -3,9 +3,11 @@ package pipeline
 import (
 	"reflect"
 	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/money"
 )
 
-func TestBenefitCalculator_Execute(t *testing.T) {
+func TestBenefitCalculator_Handle(t *testing.T) {
 	type args struct {
 		workerID       int
 		isFinalChannel bool
@@ -30,12 +32,14 @@ func TestBenefitCalculator_Execute(t *testing.T) {
 					OutputRow: []string{
 						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 					},
-					AverageBalanced:  150,
-					PreviousBalanced: 100,
-					Balanced:         200,
+					AverageBalanced:  money.FromInt(150),
+					PreviousBalanced: money.FromInt(100),
+					Balanced:         money.FromInt(200),
 					FreeTransfer:     4,
-					ThreadNo1:        111,
-					ThreadNo3:        41,
+					Workers: map[string]int{
+						AverageCalculatorStageName: 111,
+						BonusDistributorStageName:  41,
+					},
 				},
 			},
 			&EODRowData{
@@ -46,13 +50,18 @@ func TestBenefitCalculator_Execute(t *testing.T) {
 				OutputRow: []string{
 					"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 				},
-				AverageBalanced:  150,
-				PreviousBalanced: 100,
-				Balanced:         225,
+				AverageBalanced:  money.FromInt(150),
+				PreviousBalanced: money.FromInt(100),
+				Balanced:         money.FromInt(225),
 				FreeTransfer:     4,
-				ThreadNo1:        111,
-				ThreadNo2B:       1,
-				ThreadNo3:        41,
+				Workers: map[string]int{
+					AverageCalculatorStageName: 111,
+					BenefitCalculatorStageName: 1,
+					BonusDistributorStageName:  41,
+				},
+				Adjustments: []Adjustment{
+					{Stage: BenefitCalculatorStageName, Rule: "balanced-bonus", Field: FieldBalanced, Old: money.FromInt(200), New: money.FromInt(225)},
+				},
 			},
 			false,
 		},
@@ -69,12 +78,14 @@ func TestBenefitCalculator_Execute(t *testing.T) {
 					OutputRow: []string{
 						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 					},
-					AverageBalanced:  150,
-					PreviousBalanced: 100,
-					Balanced:         100,
+					AverageBalanced:  money.FromInt(150),
+					PreviousBalanced: money.FromInt(100),
+					Balanced:         money.FromInt(100),
 					FreeTransfer:     4,
-					ThreadNo1:        111,
-					ThreadNo3:        41,
+					Workers: map[string]int{
+						AverageCalculatorStageName: 111,
+						BonusDistributorStageName:  41,
+					},
 				},
 			},
 			&EODRowData{
@@ -85,13 +96,18 @@ func TestBenefitCalculator_Execute(t *testing.T) {
 				OutputRow: []string{
 					"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 				},
-				AverageBalanced:  150,
-				PreviousBalanced: 100,
-				Balanced:         100,
+				AverageBalanced:  money.FromInt(150),
+				PreviousBalanced: money.FromInt(100),
+				Balanced:         money.FromInt(100),
 				FreeTransfer:     5,
-				ThreadNo1:        111,
-				ThreadNo2A:       1,
-				ThreadNo3:        41,
+				Workers: map[string]int{
+					AverageCalculatorStageName: 111,
+					BenefitCalculatorStageName: 1,
+					BonusDistributorStageName:  41,
+				},
+				Adjustments: []Adjustment{
+					{Stage: BenefitCalculatorStageName, Rule: "free-transfer", Field: FieldFreeTransfer, Old: money.FromInt(4), New: money.FromInt(5)},
+				},
 			},
 			false,
 		},
@@ -108,12 +124,14 @@ func TestBenefitCalculator_Execute(t *testing.T) {
 					OutputRow: []string{
 						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 					},
-					AverageBalanced:  150,
-					PreviousBalanced: 100,
-					Balanced:         99,
+					AverageBalanced:  money.FromInt(150),
+					PreviousBalanced: money.FromInt(100),
+					Balanced:         money.FromInt(99),
 					FreeTransfer:     4,
-					ThreadNo1:        111,
-					ThreadNo3:        41,
+					Workers: map[string]int{
+						AverageCalculatorStageName: 111,
+						BonusDistributorStageName:  41,
+					},
 				},
 			},
 			&EODRowData{
@@ -124,12 +142,15 @@ func TestBenefitCalculator_Execute(t *testing.T) {
 				OutputRow: []string{
 					"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 				},
-				AverageBalanced:  150,
-				PreviousBalanced: 100,
-				Balanced:         99,
+				AverageBalanced:  money.FromInt(150),
+				PreviousBalanced: money.FromInt(100),
+				Balanced:         money.FromInt(99),
 				FreeTransfer:     4,
-				ThreadNo1:        111,
-				ThreadNo3:        41,
+				Workers: map[string]int{
+					AverageCalculatorStageName: 111,
+					BenefitCalculatorStageName: 1,
+					BonusDistributorStageName:  41,
+				},
 			},
 			false,
 		},
@@ -146,12 +167,14 @@ func TestBenefitCalculator_Execute(t *testing.T) {
 					OutputRow: []string{
 						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 					},
-					AverageBalanced:  150,
-					PreviousBalanced: 100,
-					Balanced:         200,
+					AverageBalanced:  money.FromInt(150),
+					PreviousBalanced: money.FromInt(100),
+					Balanced:         money.FromInt(200),
 					FreeTransfer:     4,
-					ThreadNo1:        111,
-					ThreadNo3:        41,
+					Workers: map[string]int{
+						AverageCalculatorStageName: 111,
+						BonusDistributorStageName:  41,
+					},
 				},
 			},
 			&EODRowData{
@@ -162,16 +185,39 @@ func TestBenefitCalculator_Execute(t *testing.T) {
 				OutputRow: []string{
 					"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 				},
-				AverageBalanced:  150,
-				PreviousBalanced: 100,
-				Balanced:         225,
+				AverageBalanced:  money.FromInt(150),
+				PreviousBalanced: money.FromInt(100),
+				Balanced:         money.FromInt(225),
 				FreeTransfer:     4,
-				ThreadNo1:        111,
-				ThreadNo2B:       1,
-				ThreadNo3:        41,
+				Workers: map[string]int{
+					AverageCalculatorStageName: 111,
+					BenefitCalculatorStageName: 1,
+					BonusDistributorStageName:  41,
+				},
+				Adjustments: []Adjustment{
+					{Stage: BenefitCalculatorStageName, Rule: "balanced-bonus", Field: FieldBalanced, Old: money.FromInt(200), New: money.FromInt(225)},
+				},
 			},
 			false,
 		},
+		{
+			"Given cancelled run then it must fail",
+			args{
+				workerID: 1,
+				data: &EODRowData{
+					Index: 1,
+					InputRow: []string{
+						"1", "Test 1", "24", "2", "3", "4", "5",
+					},
+					OutputRow: []string{
+						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
+					},
+					Run: newCanceledRun(),
+				},
+			},
+			nil,
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -185,17 +231,17 @@ func TestBenefitCalculator_Execute(t *testing.T) {
 			if tt.wantErr {
 				tt.args.data.FinishChannel = res
 			}
-			calculator.Execute(tt.args.workerID, tt.args.data)
+			calculator.Handle(tt.args.workerID, tt.args.data)
 			got := <-res
 			tt.args.data.FinishChannel = nil
 			if tt.wantErr {
 				if !reflect.DeepEqual(tt.wantErr, got.Error != nil) {
-					t.Errorf("BenefitCalculator.Execute() err check = %v, want %v", got.Error != nil, tt.wantErr)
+					t.Errorf("BenefitCalculator.Handle() err check = %v, want %v", got.Error != nil, tt.wantErr)
 				}
 				return
 			}
 			if !reflect.DeepEqual(tt.want, got) {
-				t.Errorf("BenefitCalculator.Execute() = %v, want %v", got, tt.want)
+				t.Errorf("BenefitCalculator.Handle() = %v, want %v", got, tt.want)
 			}
 		})
 	}
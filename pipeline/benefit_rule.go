@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
+
+var (
+	ErrInvalidBenefitRules = errors.New("invalid benefit rules provided")
+)
+
+// BenefitRules represent ordered rules used by BenefitCalculator.
+// Only the first rule matching the balanced of a row is applied.
+type BenefitRules struct {
+	Rules []BenefitRule `json:"rules"`
+}
+
+// BenefitRule represent a single benefit tier given to rows which balanced is within its bounds.
+type BenefitRule struct {
+	// Name identify the rule and is recorded on every adjustment of the rows the rule is applied to.
+	Name string `json:"name"`
+	// Min is the lower bound of the balanced, nil means unbounded.
+	Min *money.Amount `json:"min,omitempty"`
+	// MinInclusive determine whether balanced equal to Min is within the bounds.
+	MinInclusive bool `json:"min_inclusive"`
+	// Max is the upper bound of the balanced, nil means unbounded.
+	Max *money.Amount `json:"max,omitempty"`
+	// MaxInclusive determine whether balanced equal to Max is within the bounds.
+	MaxInclusive bool `json:"max_inclusive"`
+	// Actions is the benefit given when the rule is applied.
+	Actions BenefitActions `json:"actions"`
+}
+
+// BenefitActions represent benefit given by a BenefitRule.
+type BenefitActions struct {
+	// SetFreeTransfer will replace the free transfer when not nil.
+	SetFreeTransfer *int `json:"set_free_transfer,omitempty"`
+	// AddBalanced will be added to the balanced when not nil.
+	AddBalanced *money.Amount `json:"add_balanced,omitempty"`
+}
+
+// DefaultBenefitRules return the default benefit rules :
+// - "free-transfer" will set the free transfer to 5 if balanced is between 100 to 150
+// - "balanced-bonus" will increase the balance by 25 if balanced is more than 150
+func DefaultBenefitRules() *BenefitRules {
+	freeTransfer := 5
+	freeTransferMin := money.FromInt(100)
+	freeTransferMax := money.FromInt(150)
+	balancedBonusMin := money.FromInt(150)
+	balancedBonus := money.FromInt(25)
+	return &BenefitRules{
+		Rules: []BenefitRule{
+			{
+				Name:         "free-transfer",
+				Min:          &freeTransferMin,
+				MinInclusive: true,
+				Max:          &freeTransferMax,
+				MaxInclusive: true,
+				Actions: BenefitActions{
+					SetFreeTransfer: &freeTransfer,
+				},
+			},
+			{
+				Name: "balanced-bonus",
+				Min:  &balancedBonusMin,
+				Actions: BenefitActions{
+					AddBalanced: &balancedBonus,
+				},
+			},
+		},
+	}
+}
+
+// LoadBenefitRules will read and validate benefit rules from given JSON file name.
+func LoadBenefitRules(fileName string) (*BenefitRules, error) {
+	fileHandle, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read provided benefit rules file %w`, err)
+	}
+	defer fileHandle.Close()
+	return ParseBenefitRules(fileHandle)
+}
+
+// ParseBenefitRules will read and validate benefit rules from given JSON reader.
+// Will return error on unknown field to catch typo in the configuration.
+func ParseBenefitRules(reader io.Reader) (*BenefitRules, error) {
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	rules := &BenefitRules{}
+	if err := decoder.Decode(rules); err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrInvalidBenefitRules, err)
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Validate will return error if any of the rules is invalid.
+func (b *BenefitRules) Validate() error {
+	names := make(map[string]bool, len(b.Rules))
+	for idx, rule := range b.Rules {
+		if len(rule.Name) == 0 {
+			return fmt.Errorf(`%w, rule at index "%d" has no name`, ErrInvalidBenefitRules, idx)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf(`%w, rule "%s" is defined more than once`, ErrInvalidBenefitRules, rule.Name)
+		}
+		names[rule.Name] = true
+		if rule.Actions.SetFreeTransfer == nil && rule.Actions.AddBalanced == nil {
+			return fmt.Errorf(`%w, rule "%s" has no action`, ErrInvalidBenefitRules, rule.Name)
+		}
+		if rule.Actions.SetFreeTransfer != nil && *rule.Actions.SetFreeTransfer < 0 {
+			return fmt.Errorf(`%w, rule "%s" set negative free transfer`, ErrInvalidBenefitRules, rule.Name)
+		}
+		if rule.Min != nil && rule.Max != nil {
+			cmp := rule.Min.Cmp(*rule.Max)
+			if cmp > 0 || (cmp == 0 && !(rule.MinInclusive && rule.MaxInclusive)) {
+				return fmt.Errorf(`%w, rule "%s" bounds can never match`, ErrInvalidBenefitRules, rule.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Match will return the first rule matching given balanced.
+// Will return nil if no rule match.
+func (b *BenefitRules) Match(balanced money.Amount) *BenefitRule {
+	for idx := range b.Rules {
+		if b.Rules[idx].Matches(balanced) {
+			return &b.Rules[idx]
+		}
+	}
+	return nil
+}
+
+// Matches will return true if given balanced is within the rule bounds.
+func (r *BenefitRule) Matches(balanced money.Amount) bool {
+	if r.Min != nil {
+		cmp := balanced.Cmp(*r.Min)
+		if cmp < 0 || (cmp == 0 && !r.MinInclusive) {
+			return false
+		}
+	}
+	if r.Max != nil {
+		cmp := balanced.Cmp(*r.Max)
+		if cmp > 0 || (cmp == 0 && !r.MaxInclusive) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
+
+func TestParseBenefitRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{
+			"Given valid rules then it must succeed",
+			`{"rules": [
+				{"name": "low", "max": 100, "actions": {"set_free_transfer": 1}},
+				{"name": "high", "min": "100.50", "min_inclusive": true, "actions": {"add_balanced": 12.5}}
+			]}`,
+			false,
+		},
+		{
+			"Given no name then it must fail",
+			`{"rules": [{"actions": {"set_free_transfer": 1}}]}`,
+			true,
+		},
+		{
+			"Given duplicate name then it must fail",
+			`{"rules": [
+				{"name": "low", "actions": {"set_free_transfer": 1}},
+				{"name": "low", "actions": {"set_free_transfer": 2}}
+			]}`,
+			true,
+		},
+		{
+			"Given no action then it must fail",
+			`{"rules": [{"name": "low", "actions": {}}]}`,
+			true,
+		},
+		{
+			"Given negative free transfer then it must fail",
+			`{"rules": [{"name": "low", "actions": {"set_free_transfer": -1}}]}`,
+			true,
+		},
+		{
+			"Given min over max then it must fail",
+			`{"rules": [{"name": "low", "min": 150, "max": 100, "actions": {"set_free_transfer": 1}}]}`,
+			true,
+		},
+		{
+			"Given equal exclusive bounds then it must fail",
+			`{"rules": [{"name": "low", "min": 100, "max": 100, "min_inclusive": true, "actions": {"set_free_transfer": 1}}]}`,
+			true,
+		},
+		{
+			"Given unknown field then it must fail",
+			`{"rules": [{"name": "low", "minimum": 100, "actions": {"set_free_transfer": 1}}]}`,
+			true,
+		},
+		{
+			"Given bad amount then it must fail",
+			`{"rules": [{"name": "low", "min": "BAD", "actions": {"set_free_transfer": 1}}]}`,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseBenefitRules(strings.NewReader(tt.config))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseBenefitRules() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && !errors.Is(err, ErrInvalidBenefitRules) {
+				t.Errorf("ParseBenefitRules() error = %v, want %v", err, ErrInvalidBenefitRules)
+			}
+		})
+	}
+}
+
+func TestBenefitRules_Match(t *testing.T) {
+	rules := DefaultBenefitRules()
+	tests := []struct {
+		name     string
+		balanced money.Amount
+		want     string
+	}{
+		{"Given below every rule then it must not match", money.MustParse("99.99"), ""},
+		{"Given inclusive lower bound then it must match", money.FromInt(100), "free-transfer"},
+		{"Given inclusive upper bound then it must match", money.FromInt(150), "free-transfer"},
+		{"Given exclusive lower bound then it must match next rule", money.MustParse("150.01"), "balanced-bonus"},
+		{"Given unbounded upper bound then it must match", money.FromInt(1000000), "balanced-bonus"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if rule := rules.Match(tt.balanced); rule != nil {
+				got = rule.Name
+			}
+			if got != tt.want {
+				t.Errorf("BenefitRules.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
+
+var (
+	ErrInvalidBonusConfig = errors.New("invalid bonus config provided")
+)
+
+// BonusConfig represent configuration of BonusDistributor.
+type BonusConfig struct {
+	// Quota is the maximum amount of rows given the bonus in a run.
+	Quota int `json:"quota"`
+	// Amount is the bonus added to the balanced of each selected row.
+	Amount money.Amount `json:"amount"`
+	// Eligibility is the criteria a row must satisfy to be selected.
+	Eligibility BonusEligibility `json:"eligibility"`
+	// OrderBy determine which eligible rows are selected first, one of "index", "id",
+	// "balanced-desc" or "column:<input column name>". Default to "index".
+	OrderBy string `json:"order_by,omitempty"`
+
+	order bonusOrder
+}
+
+// BonusEligibility represent criteria a row must satisfy to receive the bonus.
+// Every criteria that is set must be satisfied, unset criteria always pass.
+type BonusEligibility struct {
+	// MinAge is the inclusive minimum age.
+	MinAge *int `json:"min_age,omitempty"`
+	// MaxAge is the inclusive maximum age.
+	MaxAge *int `json:"max_age,omitempty"`
+	// MinBalanced is the inclusive minimum balanced before any adjustment of the run.
+	MinBalanced *money.Amount `json:"min_balanced,omitempty"`
+	// AccountIDs restrict the bonus to the listed account ids when not empty.
+	AccountIDs []string `json:"account_ids,omitempty"`
+
+	accountIDSet map[string]bool
+}
+
+// DefaultBonusConfig return the default bonus config which give 10 to the first 100 rows.
+func DefaultBonusConfig() *BonusConfig {
+	config := &BonusConfig{
+		Quota:  100,
+		Amount: money.FromInt(10),
+	}
+	// Default config is always valid, this only prepare it for use.
+	config.Validate()
+	return config
+}
+
+// LoadBonusConfig will read and validate bonus config from given JSON file name.
+func LoadBonusConfig(fileName string) (*BonusConfig, error) {
+	fileHandle, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read provided bonus config file %w`, err)
+	}
+	defer fileHandle.Close()
+	return ParseBonusConfig(fileHandle)
+}
+
+// ParseBonusConfig will read and validate bonus config from given JSON reader.
+// Will return error on unknown field to catch typo in the configuration.
+func ParseBonusConfig(reader io.Reader) (*BonusConfig, error) {
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	config := &BonusConfig{}
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrInvalidBonusConfig, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate will return error if the config is invalid.
+// Validate must be called before the config is used.
+func (c *BonusConfig) Validate() error {
+	if c.Quota < 0 {
+		return fmt.Errorf("%w, quota can't be negative", ErrInvalidBonusConfig)
+	}
+	if c.Amount.IsNegative() {
+		return fmt.Errorf("%w, amount can't be negative", ErrInvalidBonusConfig)
+	}
+	order, err := parseBonusOrder(c.OrderBy)
+	if err != nil {
+		return fmt.Errorf("%w, %v", ErrInvalidBonusConfig, err)
+	}
+	c.order = order
+	eligibility := c.Eligibility
+	if eligibility.MinAge != nil && eligibility.MaxAge != nil && *eligibility.MinAge > *eligibility.MaxAge {
+		return fmt.Errorf("%w, min age is greater than max age", ErrInvalidBonusConfig)
+	}
+	c.Eligibility.accountIDSet = make(map[string]bool, len(eligibility.AccountIDs))
+	for _, accountID := range eligibility.AccountIDs {
+		c.Eligibility.accountIDSet[accountID] = true
+	}
+	return nil
+}
+
+// isEmpty will return true if no criteria is set, so every row is eligible.
+func (e *BonusEligibility) isEmpty() bool {
+	return e.MinAge == nil && e.MaxAge == nil && e.MinBalanced == nil && len(e.AccountIDs) == 0
+}
+
+// Eligible will return true if given data satisfy every criteria.
+// Balanced is compared as it is, so it should be called before any adjustment is applied.
+// Data with invalid age is not eligible if any age criteria is set.
+func (e *BonusEligibility) Eligible(data *EODRowData) bool {
+	if (e.MinAge != nil || e.MaxAge != nil) && data.AgeError != nil {
+		return false
+	}
+	if e.MinAge != nil && data.Age < *e.MinAge {
+		return false
+	}
+	if e.MaxAge != nil && data.Age > *e.MaxAge {
+		return false
+	}
+	if e.MinBalanced != nil && data.Balanced.Cmp(*e.MinBalanced) < 0 {
+		return false
+	}
+	if len(e.AccountIDs) > 0 && !e.accountIDSet[data.ID] {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
+
+func TestParseBonusConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{
+			"Given valid config then it must succeed",
+			`{"quota": 10, "amount": "12.50", "eligibility": {"min_age": 17, "max_age": 60, "min_balanced": 100, "account_ids": ["1", "2"]}}`,
+			false,
+		},
+		{
+			"Given negative quota then it must fail",
+			`{"quota": -1, "amount": 10}`,
+			true,
+		},
+		{
+			"Given negative amount then it must fail",
+			`{"quota": 10, "amount": -10}`,
+			true,
+		},
+		{
+			"Given min age over max age then it must fail",
+			`{"quota": 10, "amount": 10, "eligibility": {"min_age": 60, "max_age": 17}}`,
+			true,
+		},
+		{
+			"Given unknown field then it must fail",
+			`{"quota": 10, "amount": 10, "eligibility": {"age": 17}}`,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseBonusConfig(strings.NewReader(tt.config))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseBonusConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && !errors.Is(err, ErrInvalidBonusConfig) {
+				t.Errorf("ParseBonusConfig() error = %v, want %v", err, ErrInvalidBonusConfig)
+			}
+		})
+	}
+}
+
+func TestBonusEligibility_Eligible(t *testing.T) {
+	config, err := ParseBonusConfig(strings.NewReader(
+		`{"quota": 10, "amount": 10, "eligibility": {"min_age": 17, "max_age": 60, "min_balanced": 100, "account_ids": ["1", "2"]}}`,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data *EODRowData
+		want bool
+	}{
+		{"Given every criteria satisfied then it must be eligible", &EODRowData{ID: "1", Age: 17, Balanced: money.FromInt(100)}, true},
+		{"Given too young then it must not be eligible", &EODRowData{ID: "1", Age: 16, Balanced: money.FromInt(100)}, false},
+		{"Given too old then it must not be eligible", &EODRowData{ID: "1", Age: 61, Balanced: money.FromInt(100)}, false},
+		{"Given invalid age then it must not be eligible", &EODRowData{ID: "1", AgeError: ErrInvalidAge, Balanced: money.FromInt(100)}, false},
+		{"Given low balanced then it must not be eligible", &EODRowData{ID: "2", Age: 30, Balanced: money.MustParse("99.99")}, false},
+		{"Given unlisted account then it must not be eligible", &EODRowData{ID: "3", Age: 30, Balanced: money.FromInt(100)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := config.Eligibility.Eligible(tt.data); got != tt.want {
+				t.Errorf("BonusEligibility.Eligible() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
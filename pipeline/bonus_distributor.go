@@ -1,36 +1,173 @@
 package pipeline
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
 const (
 	bonusDistributorRequiredParallelism = 8
+
+	// BonusDistributorStageName is the name of the BonusDistributor stage used on adjustments and the pipeline config.
+	BonusDistributorStageName = "bonus-distributor"
+	// BonusRuleName is the name of the rule of the adjustment given by BonusDistributor.
+	BonusRuleName = "bonus"
+)
+
+var (
+	ErrBonusNotPlanned = errors.New("bonus need the run to be planned")
 )
 
 // BonusDistributor represent a pipeline stage which will give
-// bonus to the first 100 user in the input.
+// bonus to the first eligible users up to its quota by the configured order.
 type BonusDistributor struct {
-	*WorkerPool
-	next chan<- *EODRowData
+	*StageRunner
+	config *BonusConfig
 }
 
-// NewBonusDistributor will return a new BonusDistributor.
+// NewBonusDistributor will return a new BonusDistributor using DefaultBonusConfig.
 func NewBonusDistributor(next chan<- *EODRowData) *BonusDistributor {
+	return NewBonusDistributorWithConfig(next, DefaultBonusConfig())
+}
+
+// NewBonusDistributorWithConfig will return a new BonusDistributor using given config.
+// The config must already be validated.
+func NewBonusDistributorWithConfig(next chan<- *EODRowData, config *BonusConfig) *BonusDistributor {
+	return newBonusDistributor(next, config, bonusDistributorRequiredParallelism)
+}
+
+// newBonusDistributor will return a new BonusDistributor with given amount of worker.
+func newBonusDistributor(next chan<- *EODRowData, config *BonusConfig, parallelism int) *BonusDistributor {
 	distributor := &BonusDistributor{
-		next: next,
+		config: config,
 	}
-	pool := NewWorkerPool(bonusDistributorRequiredParallelism, distributor.Execute)
-	distributor.WorkerPool = pool
+	distributor.StageRunner = NewStageRunner(distributor, next, parallelism)
 	return distributor
 }
 
-// Execute will process current data in the pipeline stage.
-// In this case will increase the balanced for the first 100 user in the pipeline.
-func (a *BonusDistributor) Execute(workerID int, data *EODRowData) {
-	if data.Index < 100 {
-		data.ThreadNo3 = workerID
-		data.Balanced += 10
-	}
-	if a.next != nil {
-		a.next <- data
+// Name will return BonusDistributorStageName.
+func (a *BonusDistributor) Name() string {
+	return BonusDistributorStageName
+}
+
+// bonusPlan represent the bonus allocation of a run.
+type bonusPlan struct {
+	mutex       sync.Mutex
+	inOrder     bool
+	candidates  *bonusCandidateHeap
+	columnIndex int
+	selected    map[int]bool
+}
+
+// isSelected will return true if the row of given index is selected for the bonus.
+// Ordered by index, rows are selected as they are planned. Otherwise the selection is computed once
+// on the first call, after every row of the run has been planned.
+func (p *bonusPlan) isSelected(index int) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.candidates != nil {
+		for _, candidate := range p.candidates.candidates {
+			p.selected[candidate.index] = true
+		}
+		p.candidates = nil
+	}
+	return p.selected[index]
+}
+
+// PlanAhead will return true if the bonus is not ordered by index, since the best rows
+// can only be known once every row of the run has been planned.
+func (a *BonusDistributor) PlanAhead() bool {
+	return a.config.order.key != BonusOrderIndex
+}
+
+// Plan will offer eligible data of the run as candidate for the bonus.
+// Ordered by index, the first eligible rows up to the quota are selected as they are planned so
+// only the selected rows are kept. Otherwise only the best candidates up to the quota by the configured
+// order are kept. Either way the same rows are selected regardless of the order they arrive at Execute.
+// Will return error if the order column is not found in the input header.
+func (a *BonusDistributor) Plan(data *EODRowData) error {
+	var plan *bonusPlan
+	if value, ok := data.Run.Load(a); ok {
+		plan = value.(*bonusPlan)
 	} else {
-		data.FinishChannel <- data
+		plan = &bonusPlan{
+			inOrder:     !a.PlanAhead(),
+			columnIndex: -1,
+			selected:    make(map[int]bool),
+		}
+		if !plan.inOrder {
+			plan.candidates = &bonusCandidateHeap{order: a.config.order}
+		}
+		if a.config.order.key == bonusOrderColumnPrefix {
+			for idx, column := range data.Run.InputHeader {
+				if column == a.config.order.column {
+					plan.columnIndex = idx
+				}
+			}
+			if plan.columnIndex < 0 {
+				return fmt.Errorf(`%w, column "%s"`, ErrMissingBonusOrder, a.config.order.column)
+			}
+		}
+		data.Run.Store(a, plan)
+	}
+	if !a.config.Eligibility.Eligible(data) {
+		return nil
+	}
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+	if plan.inOrder {
+		if len(plan.selected) < a.config.Quota {
+			plan.selected[data.Index] = true
+		}
+		return nil
+	}
+	candidate := &bonusCandidate{
+		index:    data.Index,
+		id:       data.ID,
+		balanced: data.Balanced,
+	}
+	if plan.columnIndex >= 0 {
+		candidate.column = parseColumnValue(data.InputRow[plan.columnIndex])
+	}
+	plan.candidates.offer(candidate, a.config.Quota)
+	return nil
+}
+
+// Execute will process current data in the pipeline stage.
+// In this case will increase the balanced of the data selected on Plan.
+// If the run was not planned, the data is selected if its index is within the quota, which is the same
+// selection as Plan only when every row is eligible and ordered by index. Otherwise will return ErrBonusNotPlanned.
+func (a *BonusDistributor) Execute(ctx context.Context, data *EODRowData) error {
+	selected, err := a.isSelected(data)
+	if err != nil {
+		return err
+	}
+	if selected {
+		balanced := data.Balanced.Add(a.config.Amount)
+		data.Adjustments = append(data.Adjustments, Adjustment{
+			Stage: BonusDistributorStageName,
+			Rule:  BonusRuleName,
+			Field: FieldBalanced,
+			Old:   data.Balanced,
+			New:   balanced,
+		})
+		data.Balanced = balanced
+	}
+	return nil
+}
+
+// isSelected will return true if given data should be given bonus.
+// Will return ErrBonusNotPlanned if the run was not planned and the selection depend on the plan.
+func (a *BonusDistributor) isSelected(data *EODRowData) (bool, error) {
+	if data.Run != nil {
+		if value, ok := data.Run.Load(a); ok {
+			return value.(*bonusPlan).isSelected(data.Index), nil
+		}
+	}
+	if a.config.order.key != BonusOrderIndex || !a.config.Eligibility.isEmpty() {
+		return false, ErrBonusNotPlanned
 	}
+	return data.Index < a.config.Quota, nil
 }
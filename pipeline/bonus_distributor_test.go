@@ -1,11 +1,16 @@
 package pipeline
 
 import (
+	"context"
+	"errors"
 	"reflect"
+	"sort"
 	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/money"
 )
 
-func TestBonusDistributor_Execute(t *testing.T) {
+func TestBonusDistributor_Handle(t *testing.T) {
 	type args struct {
 		workerID       int
 		isFinalChannel bool
@@ -31,13 +36,14 @@ func TestBonusDistributor_Execute(t *testing.T) {
 					OutputRow: []string{
 						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 					},
-					AverageBalanced:  100,
-					PreviousBalanced: 100,
-					Balanced:         200,
+					AverageBalanced:  money.FromInt(100),
+					PreviousBalanced: money.FromInt(100),
+					Balanced:         money.FromInt(200),
 					FreeTransfer:     4,
-					ThreadNo1:        111,
-					ThreadNo2A:       21,
-					ThreadNo2B:       31,
+					Workers: map[string]int{
+						AverageCalculatorStageName: 111,
+						BenefitCalculatorStageName: 21,
+					},
 				},
 			},
 			&EODRowData{
@@ -48,14 +54,18 @@ func TestBonusDistributor_Execute(t *testing.T) {
 				OutputRow: []string{
 					"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 				},
-				AverageBalanced:  100,
-				PreviousBalanced: 100,
-				Balanced:         210,
+				AverageBalanced:  money.FromInt(100),
+				PreviousBalanced: money.FromInt(100),
+				Balanced:         money.FromInt(210),
 				FreeTransfer:     4,
-				ThreadNo1:        111,
-				ThreadNo2A:       21,
-				ThreadNo2B:       31,
-				ThreadNo3:        1,
+				Workers: map[string]int{
+					AverageCalculatorStageName: 111,
+					BenefitCalculatorStageName: 21,
+					BonusDistributorStageName:  1,
+				},
+				Adjustments: []Adjustment{
+					{Stage: BonusDistributorStageName, Rule: BonusRuleName, Field: FieldBalanced, Old: money.FromInt(200), New: money.FromInt(210)},
+				},
 			},
 			false,
 		},
@@ -72,13 +82,14 @@ func TestBonusDistributor_Execute(t *testing.T) {
 					OutputRow: []string{
 						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 					},
-					AverageBalanced:  100,
-					PreviousBalanced: 100,
-					Balanced:         200,
+					AverageBalanced:  money.FromInt(100),
+					PreviousBalanced: money.FromInt(100),
+					Balanced:         money.FromInt(200),
 					FreeTransfer:     4,
-					ThreadNo1:        111,
-					ThreadNo2A:       21,
-					ThreadNo2B:       31,
+					Workers: map[string]int{
+						AverageCalculatorStageName: 111,
+						BenefitCalculatorStageName: 21,
+					},
 				},
 			},
 			&EODRowData{
@@ -89,13 +100,15 @@ func TestBonusDistributor_Execute(t *testing.T) {
 				OutputRow: []string{
 					"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 				},
-				AverageBalanced:  100,
-				PreviousBalanced: 100,
-				Balanced:         200,
+				AverageBalanced:  money.FromInt(100),
+				PreviousBalanced: money.FromInt(100),
+				Balanced:         money.FromInt(200),
 				FreeTransfer:     4,
-				ThreadNo1:        111,
-				ThreadNo2A:       21,
-				ThreadNo2B:       31,
+				Workers: map[string]int{
+					AverageCalculatorStageName: 111,
+					BenefitCalculatorStageName: 21,
+					BonusDistributorStageName:  1,
+				},
 			},
 			false,
 		},
@@ -112,13 +125,14 @@ func TestBonusDistributor_Execute(t *testing.T) {
 					OutputRow: []string{
 						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 					},
-					AverageBalanced:  100,
-					PreviousBalanced: 100,
-					Balanced:         200,
+					AverageBalanced:  money.FromInt(100),
+					PreviousBalanced: money.FromInt(100),
+					Balanced:         money.FromInt(200),
 					FreeTransfer:     4,
-					ThreadNo1:        111,
-					ThreadNo2A:       21,
-					ThreadNo2B:       31,
+					Workers: map[string]int{
+						AverageCalculatorStageName: 111,
+						BenefitCalculatorStageName: 21,
+					},
 				},
 			},
 			&EODRowData{
@@ -129,17 +143,39 @@ func TestBonusDistributor_Execute(t *testing.T) {
 				OutputRow: []string{
 					"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 				},
-				AverageBalanced:  100,
-				PreviousBalanced: 100,
-				Balanced:         210,
+				AverageBalanced:  money.FromInt(100),
+				PreviousBalanced: money.FromInt(100),
+				Balanced:         money.FromInt(210),
 				FreeTransfer:     4,
-				ThreadNo1:        111,
-				ThreadNo2A:       21,
-				ThreadNo2B:       31,
-				ThreadNo3:        1,
+				Workers: map[string]int{
+					AverageCalculatorStageName: 111,
+					BenefitCalculatorStageName: 21,
+					BonusDistributorStageName:  1,
+				},
+				Adjustments: []Adjustment{
+					{Stage: BonusDistributorStageName, Rule: BonusRuleName, Field: FieldBalanced, Old: money.FromInt(200), New: money.FromInt(210)},
+				},
 			},
 			false,
 		},
+		{
+			"Given cancelled run then it must fail",
+			args{
+				workerID: 1,
+				data: &EODRowData{
+					Index: 1,
+					InputRow: []string{
+						"1", "Test 1", "24", "2", "3", "4", "5",
+					},
+					OutputRow: []string{
+						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
+					},
+					Run: newCanceledRun(),
+				},
+			},
+			nil,
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -153,17 +189,174 @@ func TestBonusDistributor_Execute(t *testing.T) {
 			if tt.wantErr {
 				tt.args.data.FinishChannel = res
 			}
-			distributor.Execute(tt.args.workerID, tt.args.data)
+			distributor.Handle(tt.args.workerID, tt.args.data)
 			got := <-res
 			tt.args.data.FinishChannel = nil
 			if tt.wantErr {
 				if !reflect.DeepEqual(tt.wantErr, got.Error != nil) {
-					t.Errorf("BonusDistributor.Execute() err check = %v, want %v", got.Error != nil, tt.wantErr)
+					t.Errorf("BonusDistributor.Handle() err check = %v, want %v", got.Error != nil, tt.wantErr)
 				}
 				return
 			}
 			if !reflect.DeepEqual(tt.want, got) {
-				t.Errorf("BonusDistributor.Execute() = %v, want %v", got, tt.want)
+				t.Errorf("BonusDistributor.Handle() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBonusDistributor_Plan(t *testing.T) {
+	minAge := 30
+	config := &BonusConfig{
+		Quota:  2,
+		Amount: money.FromInt(10),
+		Eligibility: BonusEligibility{
+			MinAge: &minAge,
+		},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	res := make(chan *EODRowData, 5)
+	distributor := NewBonusDistributorWithConfig(nil, config)
+	run := NewRun(context.Background(), nil)
+	ages := []int{20, 30, 25, 40, 50}
+	rows := make([]*EODRowData, len(ages))
+	for idx, age := range ages {
+		rows[idx] = &EODRowData{
+			Index:         idx,
+			Age:           age,
+			Run:           run,
+			FinishChannel: res,
+		}
+		if err := distributor.Plan(rows[idx]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Execute in reverse order to make sure arrival order doesn't matter.
+	for idx := len(rows) - 1; idx >= 0; idx-- {
+		distributor.Handle(1, rows[idx])
+		<-res
+	}
+	want := []money.Amount{money.FromInt(0), money.FromInt(10), money.FromInt(0), money.FromInt(10), money.FromInt(0)}
+	for idx, row := range rows {
+		if row.Balanced != want[idx] {
+			t.Errorf("BonusDistributor.Plan() balanced = %v, want %v, idx %v", row.Balanced, want[idx], idx)
+		}
+	}
+}
+
+func TestBonusDistributor_PlanOrder(t *testing.T) {
+	header := []string{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer", "Open Date", "Points"}
+	rows := []struct {
+		id       string
+		balanced money.Amount
+		openDate string
+		points   string
+	}{
+		{"10", money.FromInt(100), "2020-01-01", "100"},
+		{"9", money.FromInt(300), "2019-05-01", "20"},
+		{"2", money.FromInt(200), "2021-01-01", "1000"},
+		{"31", money.FromInt(300), "2018-01-01", "300"},
+		{"4", money.FromInt(50), "2019-01-01", "9"},
+	}
+	tests := []struct {
+		name    string
+		orderBy string
+		want    []string
+		wantErr bool
+	}{
+		{"Given index order then it must select by position", BonusOrderIndex, []string{"10", "9"}, false},
+		{"Given id order then it must select by numeric id", BonusOrderID, []string{"2", "4"}, false},
+		{"Given balanced order then it must select highest and break tie by id", BonusOrderBalancedDesc, []string{"9", "31"}, false},
+		{"Given column order then it must select by column", "column:Open Date", []string{"31", "4"}, false},
+		{"Given numeric column order then it must select by number", "column:Points", []string{"4", "9"}, false},
+		{"Given missing column then it must fail", "column:Closed Date", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &BonusConfig{
+				Quota:   2,
+				Amount:  money.FromInt(10),
+				OrderBy: tt.orderBy,
+			}
+			if err := config.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			distributor := NewBonusDistributorWithConfig(nil, config)
+			defer distributor.Close()
+			run := NewRun(context.Background(), header)
+			res := make(chan *EODRowData, len(rows))
+			planned := make([]*EODRowData, len(rows))
+			for idx, row := range rows {
+				planned[idx] = &EODRowData{
+					Index:         idx,
+					ID:            row.id,
+					InputRow:      []string{row.id, "", "", "", "", "", "", row.openDate, row.points},
+					Balanced:      row.balanced,
+					Run:           run,
+					FinishChannel: res,
+				}
+				if err := distributor.Plan(planned[idx]); err != nil {
+					if !tt.wantErr {
+						t.Errorf("BonusDistributor.Plan() error = %v, wantErr %v", err, tt.wantErr)
+					}
+					return
+				}
+			}
+			if tt.wantErr {
+				t.Errorf("BonusDistributor.Plan() error = nil, wantErr %v", tt.wantErr)
+				return
+			}
+			got := []string{}
+			for _, data := range planned {
+				distributor.Handle(1, data)
+				<-res
+				if len(data.Adjustments) > 0 {
+					got = append(got, data.ID)
+				}
+			}
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BonusDistributor.Plan() selected = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBonusDistributor_ExecuteUnplanned(t *testing.T) {
+	minAge := 30
+	tests := []struct {
+		name    string
+		config  *BonusConfig
+		index   int
+		want    money.Amount
+		wantErr error
+	}{
+		{"Given no criteria and index within quota then it must be selected", &BonusConfig{Quota: 2, Amount: money.FromInt(10)}, 1, money.FromInt(110), nil},
+		{"Given no criteria and index outside quota then it must not be selected", &BonusConfig{Quota: 2, Amount: money.FromInt(10)}, 2, money.FromInt(100), nil},
+		{"Given eligibility criteria then it must fail", &BonusConfig{Quota: 2, Amount: money.FromInt(10), Eligibility: BonusEligibility{MinAge: &minAge}}, 0, money.FromInt(100), ErrBonusNotPlanned},
+		{"Given order other than index then it must fail", &BonusConfig{Quota: 2, Amount: money.FromInt(10), OrderBy: BonusOrderID}, 0, money.FromInt(100), ErrBonusNotPlanned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			distributor := NewBonusDistributorWithConfig(nil, tt.config)
+			defer distributor.Close()
+			data := &EODRowData{
+				Index:    tt.index,
+				Age:      40,
+				Balanced: money.FromInt(100),
+				Run:      NewRun(context.Background(), nil),
+			}
+			if err := distributor.Execute(context.Background(), data); !errors.Is(err, tt.wantErr) {
+				t.Errorf("BonusDistributor.Execute() error = %v, want %v", err, tt.wantErr)
+			}
+			if data.Balanced != tt.want {
+				t.Errorf("BonusDistributor.Execute() balanced = %v, want %v", data.Balanced, tt.want)
 			}
 		})
 	}
@@ -0,0 +1,218 @@
+package pipeline
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
+
+const (
+	// BonusOrderIndex select the bonus by the position of the row in the input.
+	BonusOrderIndex = "index"
+	// BonusOrderID select the bonus by the account id ascending.
+	BonusOrderID = "id"
+	// BonusOrderBalancedDesc select the bonus by the balanced descending.
+	BonusOrderBalancedDesc = "balanced-desc"
+	// bonusOrderColumnPrefix select the bonus by the named input column ascending, see parseColumnValue.
+	bonusOrderColumnPrefix = "column:"
+)
+
+// columnValueKind represent how a value of the bonus order column is compared, in the order kinds are sorted.
+type columnValueKind int
+
+const (
+	columnValueNumber columnValueKind = iota
+	columnValueDate
+	columnValueText
+)
+
+var (
+	// columnDateLayouts are the accepted layouts of a date of the bonus order column, day first when ambiguous.
+	columnDateLayouts = []string{"2006-01-02", "2/1/2006", "2-1-2006", "2006/1/2", "2 Jan 2006", "2 January 2006"}
+)
+
+var (
+	ErrInvalidBonusOrder = errors.New("invalid bonus order provided")
+	ErrMissingBonusOrder = errors.New("bonus order column is not found in the input")
+)
+
+// bonusOrder represent ordering used to select which rows are given bonus first.
+type bonusOrder struct {
+	key    string
+	column string
+}
+
+// parseBonusOrder will parse given order such as "index", "id", "balanced-desc" or "column:Open Date".
+// Empty order is treated as "index".
+func parseBonusOrder(order string) (bonusOrder, error) {
+	switch order {
+	case "", BonusOrderIndex:
+		return bonusOrder{key: BonusOrderIndex}, nil
+	case BonusOrderID, BonusOrderBalancedDesc:
+		return bonusOrder{key: order}, nil
+	}
+	if column := strings.TrimPrefix(order, bonusOrderColumnPrefix); column != order && len(column) > 0 {
+		return bonusOrder{key: bonusOrderColumnPrefix, column: column}, nil
+	}
+	return bonusOrder{}, fmt.Errorf(`%w "%s"`, ErrInvalidBonusOrder, order)
+}
+
+// columnValue represent a value of the bonus order column parsed once so candidates don't parse it on every comparison.
+type columnValue struct {
+	kind   columnValueKind
+	number money.Amount
+	date   time.Time
+	text   string
+}
+
+// parseColumnValue will parse given value of the bonus order column as a number, else as a date
+// in one of columnDateLayouts, else keep it as text.
+func parseColumnValue(value string) columnValue {
+	value = strings.TrimSpace(value)
+	if number, err := money.Parse(value); err == nil {
+		return columnValue{kind: columnValueNumber, number: number}
+	}
+	for _, layout := range columnDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return columnValue{kind: columnValueDate, date: date}
+		}
+	}
+	return columnValue{kind: columnValueText, text: value}
+}
+
+// compare will compare numbers numerically, dates chronologically and text lexically.
+// Values of different kinds are ordered by kind, so numbers come first, then dates, then text.
+// Will return -1 if v is less than, 0 if equal to and +1 if greater than other.
+func (v columnValue) compare(other columnValue) int {
+	if v.kind != other.kind {
+		if v.kind < other.kind {
+			return -1
+		}
+		return 1
+	}
+	switch v.kind {
+	case columnValueNumber:
+		return v.number.Cmp(other.number)
+	case columnValueDate:
+		switch {
+		case v.date.Before(other.date):
+			return -1
+		case v.date.After(other.date):
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(v.text, other.text)
+}
+
+// bonusCandidate represent an eligible row competing for the bonus quota.
+type bonusCandidate struct {
+	index    int
+	id       string
+	balanced money.Amount
+	column   columnValue
+}
+
+// less will return true if candidate a must be given bonus before candidate b.
+// Ties are broken by account id then by index so the selection is reproducible.
+func (o bonusOrder) less(a, b *bonusCandidate) bool {
+	switch o.key {
+	case BonusOrderIndex:
+		return a.index < b.index
+	case BonusOrderBalancedDesc:
+		if cmp := a.balanced.Cmp(b.balanced); cmp != 0 {
+			return cmp > 0
+		}
+	case bonusOrderColumnPrefix:
+		if cmp := a.column.compare(b.column); cmp != 0 {
+			return cmp < 0
+		}
+	}
+	if cmp := CompareAccountID(a.id, b.id); cmp != 0 {
+		return cmp < 0
+	}
+	return a.index < b.index
+}
+
+// CompareAccountID will compare given account ids numerically if both are integer, otherwise as text.
+// Will return -1 if a is less than, 0 if equal to and +1 if greater than b.
+func CompareAccountID(a, b string) int {
+	if isDigits(a) && isDigits(b) {
+		a = strings.TrimLeft(a, "0")
+		b = strings.TrimLeft(b, "0")
+		if len(a) != len(b) {
+			if len(a) < len(b) {
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// isDigits will return true if given value is non empty and only contain digits.
+func isDigits(value string) bool {
+	if len(value) == 0 {
+		return false
+	}
+	for _, char := range value {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// bonusCandidateHeap keep the best candidates up to a limit.
+// The worst kept candidate is at the top so it can be replaced by a better one.
+type bonusCandidateHeap struct {
+	order      bonusOrder
+	candidates []*bonusCandidate
+}
+
+// Len implement heap.Interface.
+func (h *bonusCandidateHeap) Len() int {
+	return len(h.candidates)
+}
+
+// Less implement heap.Interface, the worst candidate is the least element.
+func (h *bonusCandidateHeap) Less(i, j int) bool {
+	return h.order.less(h.candidates[j], h.candidates[i])
+}
+
+// Swap implement heap.Interface.
+func (h *bonusCandidateHeap) Swap(i, j int) {
+	h.candidates[i], h.candidates[j] = h.candidates[j], h.candidates[i]
+}
+
+// Push implement heap.Interface.
+func (h *bonusCandidateHeap) Push(value interface{}) {
+	h.candidates = append(h.candidates, value.(*bonusCandidate))
+}
+
+// Pop implement heap.Interface.
+func (h *bonusCandidateHeap) Pop() interface{} {
+	last := len(h.candidates) - 1
+	candidate := h.candidates[last]
+	h.candidates = h.candidates[:last]
+	return candidate
+}
+
+// offer will keep given candidate if it is within the best limit candidates.
+func (h *bonusCandidateHeap) offer(candidate *bonusCandidate, limit int) {
+	if limit <= 0 {
+		return
+	}
+	if h.Len() < limit {
+		heap.Push(h, candidate)
+		return
+	}
+	if h.order.less(candidate, h.candidates[0]) {
+		h.candidates[0] = candidate
+		heap.Fix(h, 0)
+	}
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseBonusOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   string
+		want    bonusOrder
+		wantErr bool
+	}{
+		{"Given empty order then it must default to index", "", bonusOrder{key: BonusOrderIndex}, false},
+		{"Given id order then it must succeed", "id", bonusOrder{key: BonusOrderID}, false},
+		{"Given balanced order then it must succeed", "balanced-desc", bonusOrder{key: BonusOrderBalancedDesc}, false},
+		{"Given column order then it must succeed", "column:Open Date", bonusOrder{key: bonusOrderColumnPrefix, column: "Open Date"}, false},
+		{"Given empty column then it must fail", "column:", bonusOrder{}, true},
+		{"Given unknown order then it must fail", "age", bonusOrder{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBonusOrder(tt.order)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseBonusOrder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && !errors.Is(err, ErrInvalidBonusOrder) {
+				t.Errorf("parseBonusOrder() error = %v, want %v", err, ErrInvalidBonusOrder)
+			}
+			if got != tt.want {
+				t.Errorf("parseBonusOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareAccountID(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want int
+	}{
+		{"Given shorter number then it must be less", "9", "10", -1},
+		{"Given leading zero then it must be equal", "007", "7", 0},
+		{"Given greater number then it must be greater", "31", "4", 1},
+		{"Given text then it must compare as text", "A10", "A9", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompareAccountID(tt.a, tt.b); got != tt.want {
+				t.Errorf("CompareAccountID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnValue_Compare(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want int
+	}{
+		{"Given shorter number then it must be less", "9", "10", -1},
+		{"Given decimal number then it must compare numerically", "10.5", "9.75", 1},
+		{"Given negative number then it must be less", "-20", "3", -1},
+		{"Given same number with different text then it must be equal", "007", "7.0", 0},
+		{"Given day first date then it must compare chronologically", "31/12/2019", "1/2/2020", -1},
+		{"Given dates of different layouts then it must compare chronologically", "2020-02-01", "1 Feb 2020", 0},
+		{"Given number and date then the number must be less", "20200101", "2019-01-01", -1},
+		{"Given date and text then the date must be less", "2019-01-01", "A", -1},
+		{"Given empty value then it must be greater than a date", "", "2019-01-01", 1},
+		{"Given text then it must compare as text", "Bronze", "Gold", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseColumnValue(tt.a).compare(parseColumnValue(tt.b)); got != tt.want {
+				t.Errorf("columnValue.compare() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrPlanNotShareable = errors.New("plan can't be shared between chains of different stages")
+)
+
+// Chain represent ordered pipeline stages where each stage push its work to the next one.
+// Chain own its stages and will tear them down in order when closed.
+type Chain struct {
+	stages []IPipeline
+}
+
+// NewChain return a new Chain given at least one stage ordered from the first to the last stage.
+// Each stage must already be configured to push its work to the next given stage.
+func NewChain(stages ...IPipeline) *Chain {
+	return &Chain{
+		stages: stages,
+	}
+}
+
+// Channel will return the first stage's channel to push work into the chain.
+func (c *Chain) Channel() chan<- *EODRowData {
+	return c.stages[0].Channel()
+}
+
+// Close will close every stage from the first to the last one.
+// Each stage is drained before the next stage is closed so no work is lost in between.
+func (c *Chain) Close() {
+	for _, stage := range c.stages {
+		stage.Close()
+		stage.Wait()
+	}
+}
+
+// Wait will block until every stage has stopped.
+func (c *Chain) Wait() {
+	for _, stage := range c.stages {
+		stage.Wait()
+	}
+}
+
+// Plan will plan given data on every stage implementing Planner.
+// Later stages don't plan the data once a stage reject it.
+// Will return the first error returned by any of the stage.
+func (c *Chain) Plan(data *EODRowData) error {
+	for _, stage := range c.stages {
+		if data.Error != nil {
+			return nil
+		}
+		if planner, ok := stagePlanner(stage); ok {
+			if err := planner.Plan(data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PlanAhead will return true if any stage need every row of a run planned before any of them is processed.
+func (c *Chain) PlanAhead() bool {
+	for _, stage := range c.stages {
+		if planner, ok := stagePlanner(stage); ok && NeedPlanAhead(planner) {
+			return true
+		}
+	}
+	return false
+}
+
+// SharePlan will make every planned stage of the chain use the plan of the same stage of given source chain
+// in given run, so a run planned once on the source can be processed by both chains.
+// Must be called after the run is planned on the source and before any data of the run is pushed into the chain.
+// Will return ErrPlanNotShareable if the chains are not made of the same stages.
+func (c *Chain) SharePlan(run *Run, source *Chain) error {
+	if len(c.stages) != len(source.stages) {
+		return fmt.Errorf("%w, %d and %d stages", ErrPlanNotShareable, len(c.stages), len(source.stages))
+	}
+	for idx, stage := range c.stages {
+		planner, ok := stagePlanner(stage)
+		sourcePlanner, sourceOk := stagePlanner(source.stages[idx])
+		if ok != sourceOk {
+			return fmt.Errorf("%w, stage %d", ErrPlanNotShareable, idx)
+		}
+		if !ok {
+			continue
+		}
+		if value, ok := run.Load(sourcePlanner); ok {
+			run.Store(planner, value)
+		}
+	}
+	return nil
+}
+
+// stagePlanner will return given stage as Planner, or the Stage it runs if it is a StageRunner
+// which doesn't implement Planner itself.
+func stagePlanner(stage IPipeline) (Planner, bool) {
+	if planner, ok := stage.(Planner); ok {
+		return planner, true
+	}
+	if runner, ok := stage.(interface{ Stage() Stage }); ok {
+		planner, ok := runner.Stage().(Planner)
+		return planner, ok
+	}
+	return nil, false
+}
@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
+
+func TestChain_Close(t *testing.T) {
+	bonusDistributor := NewBonusDistributor(nil)
+	benefitCalculator := NewBenefitCalculator(bonusDistributor.Channel())
+	averageCalculator := NewAverageCalculator(benefitCalculator.Channel())
+	chain := NewChain(averageCalculator, benefitCalculator, bonusDistributor)
+
+	res := make(chan *EODRowData, 100)
+	for i := 0; i < 100; i++ {
+		chain.Channel() <- &EODRowData{
+			Index:         i,
+			FinishChannel: res,
+		}
+	}
+	// Every pushed work must reach the end of the chain before it is torn down.
+	chain.Close()
+	chain.Wait()
+	if got := len(res); got != 100 {
+		t.Errorf("Chain.Close() finished = %v, want %v", got, 100)
+	}
+}
+
+func TestChain_Plan(t *testing.T) {
+	config := &BonusConfig{
+		Quota:  1,
+		Amount: money.FromInt(10),
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	bonusDistributor := NewBonusDistributorWithConfig(nil, config)
+	validator := NewValidator(bonusDistributor.Channel())
+	chain := NewChain(validator, bonusDistributor)
+	defer chain.Close()
+
+	run := NewRun(context.Background(), nil)
+	res := make(chan *EODRowData, 2)
+	rows := []*EODRowData{
+		{Index: 0, ID: "1", Name: "Test 1", Age: 200},
+		{Index: 1, ID: "2", Name: "Test 2", Age: 24},
+	}
+	for _, data := range rows {
+		data.Run = run
+		data.FinishChannel = res
+		planned := *data
+		if err := chain.Plan(&planned); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Row rejected by the validator must not hold the bonus quota.
+	for _, data := range rows {
+		chain.Channel() <- data
+		<-res
+	}
+	if rows[0].Error == nil {
+		t.Errorf("Chain.Plan() first row error = nil, want error")
+	}
+	if got := rows[1].Balanced; got.Cmp(money.FromInt(10)) != 0 {
+		t.Errorf("Chain.Plan() second row balanced = %v, want %v", got, 10)
+	}
+}
+
+func TestChain_SharePlan(t *testing.T) {
+	config := &BonusConfig{
+		Quota:   1,
+		Amount:  money.FromInt(10),
+		OrderBy: BonusOrderBalancedDesc,
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	newChain := func() *Chain {
+		bonusDistributor := NewBonusDistributorWithConfig(nil, config)
+		return NewChain(NewValidator(bonusDistributor.Channel()), bonusDistributor)
+	}
+	source := newChain()
+	defer source.Close()
+	target := newChain()
+	defer target.Close()
+
+	run := NewRun(context.Background(), nil)
+	rows := []*EODRowData{
+		{Index: 0, ID: "1", Name: "Test 1", Balanced: money.FromInt(100)},
+		{Index: 1, ID: "2", Name: "Test 2", Balanced: money.FromInt(200)},
+	}
+	for _, data := range rows {
+		data.Run = run
+		planned := *data
+		if err := source.Plan(&planned); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := target.SharePlan(run, source); err != nil {
+		t.Fatal(err)
+	}
+	// The row selected on the source plan must be selected by the target chain which was never planned.
+	res := make(chan *EODRowData, 1)
+	rows[1].FinishChannel = res
+	target.Channel() <- rows[1]
+	<-res
+	if got := rows[1].Balanced; got.Cmp(money.FromInt(210)) != 0 {
+		t.Errorf("Chain.SharePlan() balanced = %v, want %v", got, 210)
+	}
+
+	other := NewChain(NewValidator(nil))
+	defer other.Close()
+	if err := other.SharePlan(run, source); !errors.Is(err, ErrPlanNotShareable) {
+		t.Errorf("Chain.SharePlan() error = %v, want %v", err, ErrPlanNotShareable)
+	}
+}
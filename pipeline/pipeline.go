@@ -1,30 +1,179 @@
 package pipeline
 
-import "runtime"
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
 
 // IPipeline represent interface for pipeline executor.
 type IPipeline interface {
 	// Channel return pipeline's channel to push the work.
 	Channel() chan<- *EODRowData
+	// Close stop the pipeline from accepting new work.
+	// Work that is already pushed will still be processed.
+	Close()
+	// Wait block until the pipeline has stopped after Close.
+	Wait()
+}
+
+// Run represent a single processing of an input through the pipeline.
+// Every EODRowData created for the same input share the same Run.
+type Run struct {
+	// Context is the context of the processing.
+	// Stages must stop processing data of the run once it is done.
+	Context context.Context
+	// InputHeader is the header of the input processed by the run.
+	InputHeader []string
+	// Currency is the currency of the accounts processed by the run, empty to accept any currency.
+	Currency string
+
+	stateLock sync.RWMutex
+	state     map[interface{}]interface{}
+}
+
+// NewRun will return a new Run bound to given context and given input header.
+func NewRun(ctx context.Context, inputHeader []string) *Run {
+	return &Run{
+		Context:     ctx,
+		InputHeader: inputHeader,
+		state:       make(map[interface{}]interface{}),
+	}
+}
+
+// Store will store stage specific state of the run given its key.
+// Stage should use a key unique to itself such as its own pointer.
+func (r *Run) Store(key, value interface{}) {
+	r.stateLock.Lock()
+	defer r.stateLock.Unlock()
+	r.state[key] = value
+}
+
+// Load will return stage specific state of the run given its key.
+// Will return nil and false if no state is stored for the key.
+func (r *Run) Load(key interface{}) (interface{}, bool) {
+	r.stateLock.RLock()
+	defer r.stateLock.RUnlock()
+	value, ok := r.state[key]
+	return value, ok
+}
+
+// Planner is implemented by stage which need to see the rows of a run in input order before
+// they are processed, for example to allocate a quota deterministically.
+type Planner interface {
+	// Plan is called sequentially in input order with every successfully parsed data of a run,
+	// each data is planned before it is pushed into the pipeline while the rows before it may already be processed.
+	// Planner implementing AheadPlanner may instead ask for every data to be planned before any is pushed.
+	// The plan should be stored in the data's Run.
+	// Setting the data's Error reject the data from the plan of the later stages.
+	// Returning an error will fail the whole run.
+	Plan(data *EODRowData) error
+}
+
+// AheadPlanner is implemented by Planner which may need to see every row of a run before any of them is processed,
+// for example to select rows by an order other than the input order.
+type AheadPlanner interface {
+	Planner
+	// PlanAhead return true if every data of a run must be planned before any of them is pushed into the pipeline.
+	PlanAhead() bool
+}
+
+// NeedPlanAhead will return true if given planner is an AheadPlanner which need every row planned before any is processed.
+// Planning ahead need the whole input to be read twice.
+func NeedPlanAhead(planner Planner) bool {
+	ahead, ok := planner.(AheadPlanner)
+	return ok && ahead.PlanAhead()
 }
 
 // EODRowData represent row data that is used for pipeline execution on
 // the EoD data.
 type EODRowData struct {
-	Index            int
-	InputRow         []string
-	OutputRow        []string
-	AverageBalanced  int
-	PreviousBalanced int
-	Balanced         int
+	Index     int
+	InputRow  []string
+	OutputRow []string
+	ID        string
+	Name      string
+	Age       int
+	// AgeError is the error of parsing the age, the age is zero when it is set.
+	// Invalid age doesn't reject the row on parsing, it is only checked by the stages using the age.
+	AgeError         error
+	AverageBalanced  money.Amount
+	PreviousBalanced money.Amount
+	Balanced         money.Amount
 	FreeTransfer     int
-	ThreadNo1        int
-	ThreadNo2A       int
-	ThreadNo2B       int
-	ThreadNo3        int
+	// Workers is the id of the worker which processed the row keyed by the stage name.
+	Workers map[string]int
 
+	Run           *Run
 	FinishChannel chan<- *EODRowData
 	Error         error
+	// ErrorStage is the name of the stage which produced the Error.
+	ErrorStage string
+	// Warnings is the violated validation rules that don't reject the row.
+	Warnings []Violation
+	// Adjustments is every change applied to the row by the rules of the stages in order.
+	Adjustments []Adjustment
+}
+
+const (
+	// FieldBalanced is the field of the adjustment changing the balanced.
+	FieldBalanced = "balanced"
+	// FieldFreeTransfer is the field of the adjustment changing the free transfer.
+	FieldFreeTransfer = "free-transfer"
+)
+
+// Adjustment represent a change of a field of a row applied by a rule of a stage.
+type Adjustment struct {
+	// Stage is the name of the stage applying the rule.
+	Stage string
+	// Rule is the name of the applied rule.
+	Rule string
+	// Field is the changed field, either FieldBalanced or FieldFreeTransfer.
+	Field string
+	// Old is the value of the field before the rule is applied.
+	Old money.Amount
+	// New is the value of the field after the rule is applied.
+	New money.Amount
+}
+
+// Delta will return the difference between the new and the old value.
+func (a Adjustment) Delta() money.Amount {
+	return a.New.Sub(a.Old)
+}
+
+// AbortIfCanceled will check whether the run owning the data has been cancelled.
+// If so it will set the cancellation as the data error and send the data to the finish channel.
+// Will return true when the data is aborted and must not be processed further.
+func (d *EODRowData) AbortIfCanceled() bool {
+	if d.Run == nil || d.Run.Context.Err() == nil {
+		return false
+	}
+	d.Error = d.Run.Context.Err()
+	d.Finish()
+	return true
+}
+
+// Finish will send the data to its finish channel, ending its processing.
+func (d *EODRowData) Finish() {
+	d.FinishChannel <- d
+}
+
+// Context will return the context of the run owning the data, or a background context if it has no run.
+func (d *EODRowData) Context() context.Context {
+	if d.Run == nil {
+		return context.Background()
+	}
+	return d.Run.Context
+}
+
+// RecordWorker will record given worker id as the worker which processed the data on given stage.
+func (d *EODRowData) RecordWorker(stage string, workerID int) {
+	if d.Workers == nil {
+		d.Workers = make(map[string]int)
+	}
+	d.Workers[stage] = workerID
 }
 
 // getOptimumParallelism will return value that is optimum for the worker pool (assuming for CPU intensive operation).
@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var (
+	ErrInvalidPipelineConfig = errors.New("invalid pipeline config provided")
+)
+
+// PipelineConfig represent the ordered stages of a pipeline built by BuildPipeline.
+type PipelineConfig struct {
+	// Stages is the stages ordered from the first to the last stage.
+	Stages []StageConfig `json:"stages"`
+}
+
+// StageConfig represent the configuration of a single stage of the pipeline.
+type StageConfig struct {
+	// Name is the name the stage is registered under.
+	Name string `json:"name"`
+	// Disabled remove the stage from the pipeline while keeping it in the config.
+	Disabled bool `json:"disabled,omitempty"`
+	// Parallelism is the amount of worker of the stage, the stage default is used if zero.
+	Parallelism int `json:"parallelism,omitempty"`
+	// Params is the stage specific configuration, the stage default is used if empty.
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// LoadPipelineConfig will read and validate pipeline config from given JSON file name.
+func LoadPipelineConfig(fileName string) (*PipelineConfig, error) {
+	fileHandle, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read provided pipeline config file %w`, err)
+	}
+	defer fileHandle.Close()
+	return ParsePipelineConfig(fileHandle)
+}
+
+// ParsePipelineConfig will read and validate pipeline config from given JSON reader.
+// Will return error on unknown field to catch typo in the configuration.
+func ParsePipelineConfig(reader io.Reader) (*PipelineConfig, error) {
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	config := &PipelineConfig{}
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrInvalidPipelineConfig, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate will return error if any of the stages is unknown, has a negative parallelism
+// or if there is no enabled stage. If a parser stage is registered, the first enabled stage must be a parser
+// and no other stage may be a parser, since the other stages need the parsed value of the row.
+// Stage params are validated when the pipeline is built.
+func (p *PipelineConfig) Validate() error {
+	enabled := 0
+	for idx, stage := range p.Stages {
+		if _, err := lookupStage(stage.Name); err != nil {
+			return fmt.Errorf(`%w, stage at index "%d", %v`, ErrInvalidPipelineConfig, idx, err)
+		}
+		if stage.Parallelism < 0 {
+			return fmt.Errorf(`%w, stage "%s" has negative parallelism`, ErrInvalidPipelineConfig, stage.Name)
+		}
+		if stage.Disabled {
+			continue
+		}
+		parser, parserRegistered := isParserStage(stage.Name)
+		if parserRegistered && parser != (enabled == 0) {
+			return fmt.Errorf(`%w, stage "%s" at index "%d", the parser must be the first enabled stage`, ErrInvalidPipelineConfig, stage.Name, idx)
+		}
+		enabled++
+	}
+	if enabled == 0 {
+		return fmt.Errorf("%w, no enabled stage", ErrInvalidPipelineConfig)
+	}
+	return nil
+}
+
+// SetDefaultParams will set given params on every stage with given name that has no params.
+func (p *PipelineConfig) SetDefaultParams(name string, params json.RawMessage) {
+	for idx := range p.Stages {
+		if p.Stages[idx].Name == name && len(p.Stages[idx].Params) == 0 {
+			p.Stages[idx].Params = params
+		}
+	}
+}
+
+// BuildPipeline will build a Chain of the enabled stages of given config in order.
+// Each stage push its work to the next enabled stage and the last stage finish the data.
+// Stages that are already built are closed if any of the stage fail to be built.
+func BuildPipeline(config *PipelineConfig) (*Chain, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	stages := []IPipeline{}
+	var next chan<- *EODRowData
+	// Stages are built from the last one since each stage need the channel of its next stage.
+	for idx := len(config.Stages) - 1; idx >= 0; idx-- {
+		stageConfig := config.Stages[idx]
+		if stageConfig.Disabled {
+			continue
+		}
+		stage, err := buildStage(stageConfig, next)
+		if err != nil {
+			// Nothing has been pushed yet so the built stages can be closed right away.
+			NewChain(stages...).Close()
+			return nil, fmt.Errorf(`failed to build stage "%s", %w`, stageConfig.Name, err)
+		}
+		stages = append([]IPipeline{stage}, stages...)
+		next = stage.Channel()
+	}
+	return NewChain(stages...), nil
+}
+
+// buildStage will build the stage of given config pushing its work to given next channel.
+func buildStage(config StageConfig, next chan<- *EODRowData) (IPipeline, error) {
+	factory, err := lookupStage(config.Name)
+	if err != nil {
+		return nil, err
+	}
+	return factory(next, config.Params, config.Parallelism)
+}
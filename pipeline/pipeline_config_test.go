@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
+
+func TestParsePipelineConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr error
+	}{
+		{
+			"Given valid config then it must succeed",
+			`{"stages": [{"name": "validator", "parallelism": 2}, {"name": "bonus-distributor", "params": {"quota": 1, "amount": 10}}]}`,
+			nil,
+		},
+		{
+			"Given unknown stage then it must fail",
+			`{"stages": [{"name": "validator"}, {"name": "interest-calculator"}]}`,
+			ErrUnknownStage,
+		},
+		{
+			"Given negative parallelism then it must fail",
+			`{"stages": [{"name": "validator", "parallelism": -1}]}`,
+			ErrInvalidPipelineConfig,
+		},
+		{
+			"Given every stage disabled then it must fail",
+			`{"stages": [{"name": "validator", "disabled": true}]}`,
+			ErrInvalidPipelineConfig,
+		},
+		{
+			"Given unknown field then it must fail",
+			`{"stages": [{"name": "validator", "workers": 2}]}`,
+			ErrInvalidPipelineConfig,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePipelineConfig(strings.NewReader(tt.config))
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("ParsePipelineConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != nil && !strings.Contains(err.Error(), tt.wantErr.Error()) {
+				t.Errorf("ParsePipelineConfig() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildPipeline(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr error
+	}{
+		{
+			"Given invalid stage params then it must fail",
+			`{"stages": [{"name": "validator"}, {"name": "average-calculator", "params": {"rounding": "up"}}]}`,
+			money.ErrInvalidRoundingMode,
+		},
+		{
+			"Given invalid rules then it must fail",
+			`{"stages": [{"name": "benefit-calculator", "params": {"rules": [{"name": "empty"}]}}]}`,
+			ErrInvalidBenefitRules,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParsePipelineConfig(strings.NewReader(tt.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := BuildPipeline(config); !errors.Is(err, tt.wantErr) {
+				t.Errorf("BuildPipeline() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildPipeline_Order(t *testing.T) {
+	// Validator would reject the row, benefit run before the average so the average include the benefit rounded half up.
+	config, err := ParsePipelineConfig(strings.NewReader(`{"stages": [
+		{"name": "validator", "disabled": true},
+		{"name": "benefit-calculator", "parallelism": 1, "params": {"rules": [{"name": "smallest-unit", "actions": {"add_balanced": "0.0001"}}]}},
+		{"name": "average-calculator", "params": {"rounding": "half-up"}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := BuildPipeline(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	res := make(chan *EODRowData, 1)
+	chain.Channel() <- &EODRowData{
+		ID:            "1",
+		Age:           200,
+		Balanced:      money.FromInt(100),
+		FinishChannel: res,
+	}
+	got := <-res
+	if got.Error != nil {
+		t.Fatalf("BuildPipeline() row error = %v, want nil", got.Error)
+	}
+	if want := money.MustParse("100.0001"); got.Balanced != want {
+		t.Errorf("BuildPipeline() balanced = %v, want %v", got.Balanced, want)
+	}
+	if want := money.MustParse("50.0001"); got.AverageBalanced != want {
+		t.Errorf("BuildPipeline() average balanced = %v, want %v", got.AverageBalanced, want)
+	}
+}
+
+func TestRegisterStage(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RegisterStage() must panic on already registered name")
+		}
+	}()
+	RegisterStage(ValidatorStageName, newValidatorStage)
+}
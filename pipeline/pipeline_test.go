@@ -0,0 +1,56 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+)
+
+// newCanceledRun return a run which context is already cancelled.
+func newCanceledRun() *Run {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return NewRun(ctx, nil)
+}
+
+func TestEODRowData_AbortIfCanceled(t *testing.T) {
+	tests := []struct {
+		name string
+		run  *Run
+		want bool
+	}{
+		{
+			"Given no run then it must not abort",
+			nil,
+			false,
+		},
+		{
+			"Given active run then it must not abort",
+			NewRun(context.Background(), nil),
+			false,
+		},
+		{
+			"Given cancelled run then it must abort",
+			newCanceledRun(),
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := make(chan *EODRowData, 1)
+			data := &EODRowData{
+				Run:           tt.run,
+				FinishChannel: res,
+			}
+			if got := data.AbortIfCanceled(); got != tt.want {
+				t.Errorf("EODRowData.AbortIfCanceled() = %v, want %v", got, tt.want)
+			}
+			if !tt.want {
+				return
+			}
+			got := <-res
+			if got.Error != context.Canceled {
+				t.Errorf("EODRowData.AbortIfCanceled() error = %v, want %v", got.Error, context.Canceled)
+			}
+		})
+	}
+}
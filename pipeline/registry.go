@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
+
+var (
+	ErrUnknownStage = errors.New("unknown stage provided")
+
+	registryMutex sync.RWMutex
+	registry      = map[string]StageFactory{}
+	parserStages  = map[string]bool{}
+)
+
+// StageFactory represent a function which create a configured stage pushing its work to given next channel.
+// Given params is the stage specific JSON configuration, empty if the stage must use its default configuration.
+// Given parallelism is the amount of worker of the stage, zero if the stage must use its default parallelism.
+// A nil next channel means the stage is the last stage and must finish the data itself.
+type StageFactory func(next chan<- *EODRowData, params json.RawMessage, parallelism int) (IPipeline, error)
+
+// RegisterStage will register given factory under given stage name so it can be used on the pipeline config.
+// Will panic if the name is empty, the factory is nil or the name is already registered.
+func RegisterStage(name string, factory StageFactory) {
+	if len(name) == 0 || factory == nil {
+		panic("pipeline: stage must have a name and a factory")
+	}
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, exist := registry[name]; exist {
+		panic(fmt.Sprintf(`pipeline: stage "%s" is already registered`, name))
+	}
+	registry[name] = factory
+}
+
+// RegisterParserStage will register given factory under given stage name like RegisterStage and mark the stage
+// as a parser, which set the parsed value of the input row every other stage need.
+// Once a parser is registered, every pipeline config must start with a parser stage.
+// Will panic if the name is empty, the factory is nil or the name is already registered.
+func RegisterParserStage(name string, factory StageFactory) {
+	RegisterStage(name, factory)
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	parserStages[name] = true
+}
+
+// isParserStage will return true if given stage name is registered as a parser, and true as second value
+// if any parser is registered.
+func isParserStage(name string) (bool, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	return parserStages[name], len(parserStages) > 0
+}
+
+// StageNames will return the name of every registered stage in ascending order.
+func StageNames() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupStage will return the factory registered under given stage name.
+func lookupStage(name string) (StageFactory, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	factory, exist := registry[name]
+	if !exist {
+		return nil, fmt.Errorf(`%w "%s"`, ErrUnknownStage, name)
+	}
+	return factory, nil
+}
+
+// ParallelismOr will return given parallelism, or given fallback if the parallelism is zero.
+func ParallelismOr(parallelism, fallback int) int {
+	if parallelism == 0 {
+		return fallback
+	}
+	return parallelism
+}
+
+// averageCalculatorParams represent the params of the AverageCalculator stage.
+type averageCalculatorParams struct {
+	// Rounding is the name of the rounding mode of the average balanced. Default to half-even.
+	Rounding string `json:"rounding,omitempty"`
+}
+
+// newAverageCalculatorStage will return a new AverageCalculator given its params.
+func newAverageCalculatorStage(next chan<- *EODRowData, params json.RawMessage, parallelism int) (IPipeline, error) {
+	rounding := money.RoundHalfEven
+	if len(params) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(params))
+		decoder.DisallowUnknownFields()
+		config := averageCalculatorParams{}
+		if err := decoder.Decode(&config); err != nil {
+			return nil, fmt.Errorf("%w, %v", ErrInvalidPipelineConfig, err)
+		}
+		if len(config.Rounding) > 0 {
+			var err error
+			if rounding, err = money.ParseRoundingMode(config.Rounding); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return newAverageCalculator(next, rounding, ParallelismOr(parallelism, getOptimumParallelism())), nil
+}
+
+// newBenefitCalculatorStage will return a new BenefitCalculator given its benefit rules as params.
+func newBenefitCalculatorStage(next chan<- *EODRowData, params json.RawMessage, parallelism int) (IPipeline, error) {
+	rules := DefaultBenefitRules()
+	if len(params) > 0 {
+		var err error
+		if rules, err = ParseBenefitRules(bytes.NewReader(params)); err != nil {
+			return nil, err
+		}
+	}
+	return newBenefitCalculator(next, rules, ParallelismOr(parallelism, getOptimumParallelism())), nil
+}
+
+// newBonusDistributorStage will return a new BonusDistributor given its bonus config as params.
+func newBonusDistributorStage(next chan<- *EODRowData, params json.RawMessage, parallelism int) (IPipeline, error) {
+	config := DefaultBonusConfig()
+	if len(params) > 0 {
+		var err error
+		if config, err = ParseBonusConfig(bytes.NewReader(params)); err != nil {
+			return nil, err
+		}
+	}
+	return newBonusDistributor(next, config, ParallelismOr(parallelism, bonusDistributorRequiredParallelism)), nil
+}
+
+// newValidatorStage will return a new Validator given its validation rules as params.
+func newValidatorStage(next chan<- *EODRowData, params json.RawMessage, parallelism int) (IPipeline, error) {
+	rules := DefaultValidationRules()
+	if len(params) > 0 {
+		var err error
+		if rules, err = ParseValidationRules(bytes.NewReader(params)); err != nil {
+			return nil, err
+		}
+	}
+	return newValidator(next, rules, ParallelismOr(parallelism, getOptimumParallelism())), nil
+}
+
+func init() {
+	RegisterStage(ValidatorStageName, newValidatorStage)
+	RegisterStage(AverageCalculatorStageName, newAverageCalculatorStage)
+	RegisterStage(BenefitCalculatorStageName, newBenefitCalculatorStage)
+	RegisterStage(BonusDistributorStageName, newBonusDistributorStage)
+}
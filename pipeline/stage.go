@@ -0,0 +1,66 @@
+package pipeline
+
+import "context"
+
+// Stage represent a single step of the pipeline applied to every row.
+// Stage only implement its own processing, running it on a worker pool, forwarding the data
+// to the next stage and recording which worker processed the data is done by StageRunner.
+// Stage may also implement Planner to see the rows of a run in input order before they are executed.
+type Stage interface {
+	// Name return the name of the stage used on failed row report, worker attribution and the pipeline config.
+	Name() string
+	// Execute process given data using given context of its run.
+	// Returning an error reject the data, which is finished without going through the next stages.
+	Execute(ctx context.Context, data *EODRowData) error
+}
+
+// StageRunner represent a worker pool running a Stage on every pushed data.
+// Processed data is pushed to the next stage, or finished when there is no next stage.
+type StageRunner struct {
+	*WorkerPool
+	stage Stage
+	next  chan<- *EODRowData
+}
+
+// NewStageRunner will return a new StageRunner running given stage with given amount of worker.
+// Given next is the channel of the next stage, nil if the stage is the last stage.
+func NewStageRunner(stage Stage, next chan<- *EODRowData, parallelism int) *StageRunner {
+	runner := &StageRunner{
+		stage: stage,
+		next:  next,
+	}
+	runner.WorkerPool = NewWorkerPool(parallelism, runner.Handle)
+	return runner
+}
+
+// Handle will run the stage on given data then push it to the next stage.
+// The worker is recorded on the data under the stage name before the stage is executed.
+// Data of a cancelled run or rejected by the stage is finished right away.
+func (r *StageRunner) Handle(workerID int, data *EODRowData) {
+	if data.AbortIfCanceled() {
+		return
+	}
+	name := r.stage.Name()
+	data.RecordWorker(name, workerID)
+	if err := r.stage.Execute(data.Context(), data); err != nil {
+		data.Error = err
+		data.ErrorStage = name
+		data.Finish()
+		return
+	}
+	r.Next(data)
+}
+
+// Next will push given data to the next stage, or finish it if there is no next stage.
+func (r *StageRunner) Next(data *EODRowData) {
+	if r.next != nil {
+		r.next <- data
+		return
+	}
+	data.Finish()
+}
+
+// Stage will return the stage run by the runner.
+func (r *StageRunner) Stage() Stage {
+	return r.stage
+}
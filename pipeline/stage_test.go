@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
+
+var errNegativeBalanced = errors.New("negative balanced")
+
+// feeStage represent a third-party stage charging a fee which reject row that can't afford it.
+type feeStage struct {
+	fee     money.Amount
+	planned int
+}
+
+func (f *feeStage) Name() string {
+	return "fee"
+}
+
+func (f *feeStage) Plan(data *EODRowData) error {
+	f.planned++
+	return nil
+}
+
+func (f *feeStage) Execute(ctx context.Context, data *EODRowData) error {
+	balanced := data.Balanced.Sub(f.fee)
+	if balanced.Cmp(money.Amount{}) < 0 {
+		return errNegativeBalanced
+	}
+	data.Balanced = balanced
+	return nil
+}
+
+func TestStageRunner_Handle(t *testing.T) {
+	tests := []struct {
+		name         string
+		balanced     money.Amount
+		want         money.Amount
+		wantErr      error
+		wantForward  bool
+		wantCanceled bool
+	}{
+		{"Given affordable fee then it must forward the data", money.FromInt(10), money.FromInt(7), nil, true, false},
+		{"Given unaffordable fee then it must finish the data with the stage error", money.FromInt(2), money.FromInt(2), errNegativeBalanced, false, false},
+		{"Given cancelled run then it must not execute the stage", money.FromInt(10), money.FromInt(10), context.Canceled, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := make(chan *EODRowData, 1)
+			finish := make(chan *EODRowData, 1)
+			runner := NewStageRunner(&feeStage{fee: money.FromInt(3)}, next, 1)
+			defer runner.Close()
+			data := &EODRowData{
+				Balanced:      tt.balanced,
+				FinishChannel: finish,
+			}
+			if tt.wantCanceled {
+				data.Run = newCanceledRun()
+			}
+			runner.Handle(2, data)
+			if forwarded := len(next) == 1; forwarded != tt.wantForward {
+				t.Errorf("StageRunner.Handle() forwarded = %v, want %v", forwarded, tt.wantForward)
+			}
+			if !errors.Is(data.Error, tt.wantErr) {
+				t.Errorf("StageRunner.Handle() error = %v, want %v", data.Error, tt.wantErr)
+			}
+			if data.Balanced != tt.want {
+				t.Errorf("StageRunner.Handle() balanced = %v, want %v", data.Balanced, tt.want)
+			}
+			if tt.wantErr == errNegativeBalanced && data.ErrorStage != "fee" {
+				t.Errorf("StageRunner.Handle() error stage = %v, want %v", data.ErrorStage, "fee")
+			}
+			if !tt.wantCanceled && data.Workers["fee"] != 2 {
+				t.Errorf("StageRunner.Handle() workers = %v, want fee worker 2", data.Workers)
+			}
+		})
+	}
+}
+
+func TestChain_PlanStageRunner(t *testing.T) {
+	stage := &feeStage{fee: money.FromInt(3)}
+	chain := NewChain(NewStageRunner(stage, nil, 1))
+	defer chain.Close()
+	if err := chain.Plan(&EODRowData{}); err != nil {
+		t.Fatal(err)
+	}
+	if stage.planned != 1 {
+		t.Errorf("Chain.Plan() planned = %v, want %v", stage.planned, 1)
+	}
+}
@@ -0,0 +1,193 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
+
+// Severity represent how a violated validation rule affect the row.
+type Severity string
+
+const (
+	// SeverityWarning let the row continue with the violation flagged.
+	SeverityWarning Severity = "warning"
+	// SeverityError reject the row.
+	SeverityError Severity = "error"
+)
+
+const (
+	// CheckIDRequired require the id to be non empty.
+	CheckIDRequired = "id-required"
+	// CheckNameRequired require the name to be non empty.
+	CheckNameRequired = "name-required"
+	// CheckAgeRange require the age to be within min and max.
+	CheckAgeRange = "age-range"
+	// CheckBalancedRange require the balanced to be within min and max.
+	CheckBalancedRange = "balanced-range"
+	// CheckPreviousBalancedRange require the previous balanced to be within min and max.
+	CheckPreviousBalancedRange = "previous-balanced-range"
+	// CheckAverageBalancedRange require the average balanced to be within min and max.
+	CheckAverageBalancedRange = "average-balanced-range"
+	// CheckFreeTransferRange require the free transfer to be within min and max.
+	CheckFreeTransferRange = "free-transfer-range"
+)
+
+var (
+	ErrInvalidValidationRules = errors.New("invalid validation rules")
+	ErrInvalidAge             = errors.New("invalid age provided")
+)
+
+// ValidationRules represent ordered rules validated on every row.
+type ValidationRules struct {
+	Rules []ValidationRule `json:"rules"`
+}
+
+// ValidationRule represent a single check validated on every row.
+// Min and max are inclusive and only used by range checks, nil means unbounded.
+type ValidationRule struct {
+	Check    string        `json:"check"`
+	Severity Severity      `json:"severity"`
+	Min      *money.Amount `json:"min,omitempty"`
+	Max      *money.Amount `json:"max,omitempty"`
+}
+
+// Violation represent a validation rule violated by a row.
+type Violation struct {
+	Check    string
+	Severity Severity
+	Message  string
+}
+
+// ValidationError represent every violation with error severity of a row.
+type ValidationError struct {
+	Violations []Violation
+}
+
+// Error will return the message of every violation.
+func (v *ValidationError) Error() string {
+	messages := make([]string, len(v.Violations))
+	for idx, violation := range v.Violations {
+		messages[idx] = violation.Message
+	}
+	return strings.Join(messages, ", ")
+}
+
+// DefaultValidationRules will return the rules used when no rules are configured.
+func DefaultValidationRules() *ValidationRules {
+	zero := money.FromInt(0)
+	maxAge := money.FromInt(150)
+	return &ValidationRules{
+		Rules: []ValidationRule{
+			{Check: CheckIDRequired, Severity: SeverityError},
+			{Check: CheckNameRequired, Severity: SeverityWarning},
+			{Check: CheckAgeRange, Severity: SeverityError, Min: &zero, Max: &maxAge},
+			{Check: CheckBalancedRange, Severity: SeverityError, Min: &zero},
+			{Check: CheckPreviousBalancedRange, Severity: SeverityError, Min: &zero},
+			{Check: CheckAverageBalancedRange, Severity: SeverityWarning, Min: &zero},
+			{Check: CheckFreeTransferRange, Severity: SeverityError, Min: &zero},
+		},
+	}
+}
+
+// LoadValidationRules will read and validate validation rules from given JSON file name.
+func LoadValidationRules(fileName string) (*ValidationRules, error) {
+	fileHandle, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read provided validation rules file %w`, err)
+	}
+	defer fileHandle.Close()
+	return ParseValidationRules(fileHandle)
+}
+
+// ParseValidationRules will read and validate validation rules from given JSON reader.
+// Will return error on unknown field to catch typo in the configuration.
+func ParseValidationRules(reader io.Reader) (*ValidationRules, error) {
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	rules := &ValidationRules{}
+	if err := decoder.Decode(rules); err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrInvalidValidationRules, err)
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Validate will return error if any of the rules is invalid.
+func (v *ValidationRules) Validate() error {
+	for idx, rule := range v.Rules {
+		switch rule.Check {
+		case CheckIDRequired, CheckNameRequired:
+			if rule.Min != nil || rule.Max != nil {
+				return fmt.Errorf(`%w, rule "%s" at index "%d" doesn't accept min or max`, ErrInvalidValidationRules, rule.Check, idx)
+			}
+		case CheckAgeRange, CheckBalancedRange, CheckPreviousBalancedRange, CheckAverageBalancedRange, CheckFreeTransferRange:
+			if rule.Min != nil && rule.Max != nil && rule.Min.Cmp(*rule.Max) > 0 {
+				return fmt.Errorf(`%w, rule "%s" at index "%d" has min greater than max`, ErrInvalidValidationRules, rule.Check, idx)
+			}
+		default:
+			return fmt.Errorf(`%w, unknown check "%s" at index "%d"`, ErrInvalidValidationRules, rule.Check, idx)
+		}
+		if rule.Severity != SeverityWarning && rule.Severity != SeverityError {
+			return fmt.Errorf(`%w, rule "%s" at index "%d" has unknown severity "%s"`, ErrInvalidValidationRules, rule.Check, idx, rule.Severity)
+		}
+	}
+	return nil
+}
+
+// violation will return the violation of given data, or false if the data doesn't violate the rule.
+// Duplicate id is not a validation rule, it is handled by the duplicate policy of the processor.
+func (r *ValidationRule) violation(data *EODRowData) (Violation, bool) {
+	var message string
+	switch r.Check {
+	case CheckIDRequired:
+		if len(strings.TrimSpace(data.ID)) == 0 {
+			message = "id is empty"
+		}
+	case CheckNameRequired:
+		if len(strings.TrimSpace(data.Name)) == 0 {
+			message = "name is empty"
+		}
+	case CheckAgeRange:
+		if data.AgeError != nil {
+			message = data.AgeError.Error()
+			break
+		}
+		message = r.outOfRange("age", money.FromInt(int64(data.Age)))
+	case CheckBalancedRange:
+		message = r.outOfRange("balanced", data.Balanced)
+	case CheckPreviousBalancedRange:
+		message = r.outOfRange("previous balanced", data.PreviousBalanced)
+	case CheckAverageBalancedRange:
+		message = r.outOfRange("average balanced", data.AverageBalanced)
+	case CheckFreeTransferRange:
+		message = r.outOfRange("free transfer", money.FromInt(int64(data.FreeTransfer)))
+	}
+	if len(message) == 0 {
+		return Violation{}, false
+	}
+	return Violation{
+		Check:    r.Check,
+		Severity: r.Severity,
+		Message:  message,
+	}, true
+}
+
+// outOfRange will return the violation message if given value is not within min and max of the rule.
+// Will return empty string if the value is within range.
+func (r *ValidationRule) outOfRange(field string, value money.Amount) string {
+	if r.Min != nil && value.Cmp(*r.Min) < 0 {
+		return fmt.Sprintf("%s %s is less than %s", field, value, r.Min)
+	}
+	if r.Max != nil && value.Cmp(*r.Max) > 0 {
+		return fmt.Sprintf("%s %s is greater than %s", field, value, r.Max)
+	}
+	return ""
+}
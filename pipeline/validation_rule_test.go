@@ -0,0 +1,42 @@
+package pipeline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseValidationRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"Given valid rules then it must succeed", `{"rules": [{"check": "age-range", "severity": "warning", "min": 17, "max": 60}]}`, false},
+		{"Given empty rules then it must succeed", `{"rules": []}`, false},
+		{"Given unknown check then it must fail", `{"rules": [{"check": "age", "severity": "error"}]}`, true},
+		{"Given unknown severity then it must fail", `{"rules": [{"check": "id-required", "severity": "fatal"}]}`, true},
+		{"Given unique id check then it must fail since duplicates are handled by the duplicate policy", `{"rules": [{"check": "id-unique", "severity": "error"}]}`, true},
+		{"Given range on non range check then it must fail", `{"rules": [{"check": "name-required", "severity": "error", "min": 1}]}`, true},
+		{"Given min greater than max then it must fail", `{"rules": [{"check": "age-range", "severity": "error", "min": 60, "max": 17}]}`, true},
+		{"Given unknown field then it must fail", `{"rules": [{"check": "age-range", "severity": "error", "minimum": 17}]}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseValidationRules(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseValidationRules() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && !errors.Is(err, ErrInvalidValidationRules) {
+				t.Errorf("ParseValidationRules() error = %v, want %v", err, ErrInvalidValidationRules)
+			}
+		})
+	}
+}
+
+func TestDefaultValidationRules(t *testing.T) {
+	if err := DefaultValidationRules().Validate(); err != nil {
+		t.Errorf("DefaultValidationRules().Validate() error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+)
+
+// ValidatorStageName is the name of the Validator stage used on failed row report and the pipeline config.
+const ValidatorStageName = "validator"
+
+// Validator represent pipeline stage that validate every row against configured rules.
+// Violation with warning severity is flagged on the data which continue to the next stage,
+// while violation with error severity reject the data.
+type Validator struct {
+	*StageRunner
+	rules *ValidationRules
+}
+
+// NewValidator will return a new Validator using DefaultValidationRules.
+func NewValidator(next chan<- *EODRowData) *Validator {
+	return NewValidatorWithRules(next, DefaultValidationRules())
+}
+
+// NewValidatorWithRules will return a new Validator using given rules.
+// The rules must already be validated.
+func NewValidatorWithRules(next chan<- *EODRowData, rules *ValidationRules) *Validator {
+	return newValidator(next, rules, getOptimumParallelism())
+}
+
+// newValidator will return a new Validator with given amount of worker.
+func newValidator(next chan<- *EODRowData, rules *ValidationRules, parallelism int) *Validator {
+	validator := &Validator{
+		rules: rules,
+	}
+	validator.StageRunner = NewStageRunner(validator, next, parallelism)
+	return validator
+}
+
+// Name will return ValidatorStageName.
+func (v *Validator) Name() string {
+	return ValidatorStageName
+}
+
+// Plan will reject data violating error rule so later stages don't plan it.
+// Rows sharing the same id are not checked here since they are handled by the duplicate policy of the processor.
+func (v *Validator) Plan(data *EODRowData) error {
+	if violations := errorViolations(v.validate(data)); len(violations) > 0 {
+		data.Error = &ValidationError{Violations: violations}
+		data.ErrorStage = ValidatorStageName
+	}
+	return nil
+}
+
+// Execute will process current data in the pipeline stage.
+// In this case will validate the data and reject it if any error rule is violated.
+func (v *Validator) Execute(ctx context.Context, data *EODRowData) error {
+	violations := v.validate(data)
+	for _, violation := range violations {
+		if violation.Severity == SeverityWarning {
+			data.Warnings = append(data.Warnings, violation)
+		}
+	}
+	if errors := errorViolations(violations); len(errors) > 0 {
+		return &ValidationError{Violations: errors}
+	}
+	return nil
+}
+
+// validate will return every violation of given data in the rules order.
+func (v *Validator) validate(data *EODRowData) []Violation {
+	violations := []Violation{}
+	for idx := range v.rules.Rules {
+		if violation, ok := v.rules.Rules[idx].violation(data); ok {
+			violations = append(violations, violation)
+		}
+	}
+	return violations
+}
+
+// errorViolations will return only the violations with error severity.
+func errorViolations(violations []Violation) []Violation {
+	errors := []Violation{}
+	for _, violation := range violations {
+		if violation.Severity == SeverityError {
+			errors = append(errors, violation)
+		}
+	}
+	return errors
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+)
+
+func TestValidator_Handle(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         *EODRowData
+		wantWarnings []string
+		wantErrors   []string
+	}{
+		{
+			"Given valid row then it must pass",
+			&EODRowData{ID: "1", Name: "Test 1", Age: 24, Balanced: money.FromInt(100)},
+			[]string{},
+			nil,
+		},
+		{
+			"Given empty name then it must pass with warning",
+			&EODRowData{ID: "1", Age: 24, AverageBalanced: money.FromInt(-1)},
+			[]string{CheckNameRequired, CheckAverageBalancedRange},
+			nil,
+		},
+		{
+			"Given invalid row then it must be rejected with every violation",
+			&EODRowData{ID: " ", Name: "Test 1", Age: 200, Balanced: money.FromInt(-5), FreeTransfer: -1},
+			[]string{},
+			[]string{CheckIDRequired, CheckAgeRange, CheckBalancedRange, CheckFreeTransferRange},
+		},
+		{
+			"Given invalid age then it must be rejected",
+			&EODRowData{ID: "1", Name: "Test 1", AgeError: ErrInvalidAge},
+			[]string{},
+			[]string{CheckAgeRange},
+		},
+		{
+			"Given cancelled run then it must be aborted",
+			&EODRowData{ID: "1", Name: "Test 1", Run: newCanceledRun()},
+			nil,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := make(chan *EODRowData, 1)
+			finish := make(chan *EODRowData, 1)
+			validator := NewValidator(next)
+			defer validator.Close()
+			tt.data.FinishChannel = finish
+			validator.Handle(1, tt.data)
+			var got *EODRowData
+			select {
+			case got = <-next:
+				if tt.wantErrors != nil || tt.data.Run != nil {
+					t.Errorf("Validator.Handle() forwarded rejected data")
+					return
+				}
+			case got = <-finish:
+				if tt.data.Run != nil {
+					return
+				}
+				if tt.wantErrors == nil {
+					t.Errorf("Validator.Handle() rejected valid data, error = %v", got.Error)
+					return
+				}
+				if got.ErrorStage != ValidatorStageName {
+					t.Errorf("Validator.Handle() error stage = %v, want %v", got.ErrorStage, ValidatorStageName)
+				}
+				gotErrors := []string{}
+				for _, violation := range got.Error.(*ValidationError).Violations {
+					gotErrors = append(gotErrors, violation.Check)
+				}
+				if !reflect.DeepEqual(gotErrors, tt.wantErrors) {
+					t.Errorf("Validator.Handle() errors = %v, want %v", gotErrors, tt.wantErrors)
+				}
+			}
+			gotWarnings := []string{}
+			for _, violation := range got.Warnings {
+				gotWarnings = append(gotWarnings, violation.Check)
+			}
+			if !reflect.DeepEqual(gotWarnings, tt.wantWarnings) {
+				t.Errorf("Validator.Handle() warnings = %v, want %v", gotWarnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestValidator_Plan(t *testing.T) {
+	validator := NewValidator(nil)
+	defer validator.Close()
+	run := NewRun(context.Background(), nil)
+	rows := []*EODRowData{
+		{Index: 0, ID: "1", Name: "Test 1"},
+		{Index: 1, ID: " ", Name: "Test 2"},
+		{Index: 2, ID: "1", Name: "Test 1 again"},
+	}
+	// Only the row violating error rule is rejected, the same id is left to the duplicate policy.
+	want := []bool{false, true, false}
+	for idx, data := range rows {
+		data.Run = run
+		if err := validator.Plan(data); err != nil {
+			t.Fatal(err)
+		}
+		if got := data.Error != nil; got != want[idx] {
+			t.Errorf("Validator.Plan() rejected = %v, want %v, idx %v", got, want[idx], idx)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package pipeline
 
+import "sync"
+
 // WorkerPoolHandleFunc represent a worker pool implementation of goroutine.
 type WorkerPoolHandleFunc func(workerID int, data *EODRowData)
 
@@ -7,6 +9,8 @@ type WorkerPoolHandleFunc func(workerID int, data *EODRowData)
 type WorkerPool struct {
 	channel    chan *EODRowData
 	handleFunc WorkerPoolHandleFunc
+	waitGroup  sync.WaitGroup
+	closeOnce  sync.Once
 }
 
 // NewWorkerPool return an implementation of worker pool given its parameters.
@@ -17,6 +21,7 @@ func NewWorkerPool(parallelism int, handleFunc WorkerPoolHandleFunc) *WorkerPool
 		handleFunc: handleFunc,
 	}
 
+	pool.waitGroup.Add(parallelism)
 	for i := 0; i < parallelism; i++ {
 		go pool.routine(i + 1)
 	}
@@ -29,9 +34,25 @@ func (w *WorkerPool) Channel() chan<- *EODRowData {
 	return w.channel
 }
 
+// Close will stop the worker pool from accepting new job.
+// Job that is already pushed will still be processed before the workers exit.
+// Pushing new job after Close will panic. Calling Close more than once is safe.
+func (w *WorkerPool) Close() {
+	w.closeOnce.Do(func() {
+		close(w.channel)
+	})
+}
+
+// Wait will block until every worker has exited.
+// Workers only exit after Close is called, so calling Wait before Close will block forever.
+func (w *WorkerPool) Wait() {
+	w.waitGroup.Wait()
+}
+
 // routine represent internal routine to wait and execute
 // new work given the set handler.
 func (w *WorkerPool) routine(id int) {
+	defer w.waitGroup.Done()
 	for work := range w.channel {
 		if work == nil {
 			return
@@ -0,0 +1,23 @@
+package pipeline
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestWorkerPool_Close(t *testing.T) {
+	var processed int32
+	pool := NewWorkerPool(4, func(workerID int, data *EODRowData) {
+		atomic.AddInt32(&processed, 1)
+	})
+	for i := 0; i < 100; i++ {
+		pool.Channel() <- &EODRowData{Index: i}
+	}
+	pool.Close()
+	// Closing twice must be safe.
+	pool.Close()
+	pool.Wait()
+	if got := atomic.LoadInt32(&processed); got != 100 {
+		t.Errorf("WorkerPool.Close() processed = %v, want %v", got, 100)
+	}
+}
@@ -2,12 +2,14 @@ package bankeodprocessor
 
 import (
 	"context"
-	"encoding/csv"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"sync"
+	"time"
 
+	"github.com/firmanmm/bank-eod-processor/format"
 	"github.com/firmanmm/bank-eod-processor/pipeline"
 )
 
@@ -40,6 +42,12 @@ const (
 	afterEodHeaderIdxNo2AThread
 )
 
+const (
+	// streamMaxInFlight is the maximum amount of rows that can be inside
+	// the pipeline at the same time when processing in streaming mode.
+	streamMaxInFlight = 1024
+)
+
 var (
 	beforeEodCSVHeader = []string{
 		"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer",
@@ -50,57 +58,287 @@ var (
 
 	ErrInvalidInputRows  = errors.New("invalid input rows provided")
 	ErrInvalidOutputRows = errors.New("invalid output rows provided")
-	ErrInvalidHeader     = errors.New("invalid header provided")
+	ErrInputNotSeekable  = errors.New("input must be seekable to be read more than once")
+
+	ErrOutputFormatNotStreamable = errors.New("output format can't be used in streaming mode")
 )
 
 // EODProcessor represent struct can process EOD operation.
+// EODProcessor own the given pipeline and will tear it down on Close.
 type EODProcessor struct {
-	pipeline pipeline.IPipeline
+	pipeline               pipeline.IPipeline
+	errorPolicy            ErrorPolicy
+	rejectFileName         string
+	reconciliationFileName string
+	ledger                 *Ledger
+	auditLog               *AuditLog
+	businessDate           time.Time
+	force                  bool
+	backup                 bool
+	inputFormat            format.Format
+	outputFormat           format.Format
+	columnAliases          ColumnAliases
+	inputSchema            *Schema
+	outputSchema           *Schema
+	duplicatePolicy        DuplicatePolicy
+	outputOrder            OutputOrder
+	shards                 int
+	pipelineFactory        PipelineFactory
+	trackIDs               bool
+	currency               string
+	// ledgerPerInput match the business date of the ledger per input file, used by batch.
+	ledgerPerInput bool
+}
+
+// Option represent optional configuration of EODProcessor.
+type Option func(e *EODProcessor)
+
+// WithErrorPolicy will set the policy to decide whether a run with failed rows should fail.
+// Default to ErrorPolicyContinue.
+func WithErrorPolicy(policy ErrorPolicy) Option {
+	return func(e *EODProcessor) {
+		e.errorPolicy = policy
+	}
+}
+
+// WithRejectFile will make file based processing write every failed row into given file name.
+// Failed rows are kept out of the output file.
+func WithRejectFile(fileName string) Option {
+	return func(e *EODProcessor) {
+		e.rejectFileName = fileName
+	}
+}
+
+// WithReconciliationFile will make file based processing write the reconciliation of the run into given file name.
+// The file is written as JSON if its extension is .json, otherwise as text.
+func WithReconciliationFile(fileName string) Option {
+	return func(e *EODProcessor) {
+		e.reconciliationFileName = fileName
+	}
+}
+
+// WithLedger will make file based processing refuse to process a business date or an input
+// that is already recorded in given ledger, successful runs are recorded into the ledger.
+func WithLedger(ledger *Ledger) Option {
+	return func(e *EODProcessor) {
+		e.ledger = ledger
+	}
+}
+
+// WithAuditLog will make file based processing record every change applied by the stages of a successful run
+// into given audit log.
+func WithAuditLog(auditLog *AuditLog) Option {
+	return func(e *EODProcessor) {
+		e.auditLog = auditLog
+	}
+}
+
+// WithBusinessDate will set the business date of the processed runs.
+// Default to the current date.
+func WithBusinessDate(businessDate time.Time) Option {
+	return func(e *EODProcessor) {
+		e.businessDate = businessDate
+	}
+}
+
+// WithForce will make file based processing ignore the ledger when checking for a duplicate run.
+// Forced runs are still recorded into the ledger.
+func WithForce(force bool) Option {
+	return func(e *EODProcessor) {
+		e.force = force
+	}
+}
+
+// WithBackup will make file based processing keep the previous output file
+// suffixed with .bak before replacing it.
+func WithBackup(backup bool) Option {
+	return func(e *EODProcessor) {
+		e.backup = backup
+	}
+}
+
+// WithInputFormat will set the format used to read the input.
+// Default to semicolon delimited CSV.
+func WithInputFormat(inputFormat format.Format) Option {
+	return func(e *EODProcessor) {
+		e.inputFormat = inputFormat
+	}
+}
+
+// WithOutputFormat will set the format used to write the output and to read the output template.
+// Default to semicolon delimited CSV.
+func WithOutputFormat(outputFormat format.Format) Option {
+	return func(e *EODProcessor) {
+		e.outputFormat = outputFormat
+	}
+}
+
+// WithColumnAliases will set the accepted alternative names of the known columns.
+// Default to DefaultColumnAliases.
+func WithColumnAliases(aliases ColumnAliases) Option {
+	return func(e *EODProcessor) {
+		e.columnAliases = aliases
+	}
+}
+
+// WithInputSchema will set the schema of the input instead of detecting it from the input header.
+func WithInputSchema(schema *Schema) Option {
+	return func(e *EODProcessor) {
+		e.inputSchema = schema
+	}
+}
+
+// WithOutputSchema will set the schema of the output.
+// By default the output keep the schema of the output template, or follow the input version
+// if the template has no row. Template of another version is migrated into the output schema.
+func WithOutputSchema(schema *Schema) Option {
+	return func(e *EODProcessor) {
+		e.outputSchema = schema
+	}
+}
+
+// WithDuplicatePolicy will set how rows sharing the same account id in the input or the output template are handled.
+// Default to DuplicatePolicyRejectRun.
+func WithDuplicatePolicy(policy DuplicatePolicy) Option {
+	return func(e *EODProcessor) {
+		e.duplicatePolicy = policy
+	}
+}
+
+// WithOutputOrder will set the order of the rows written into the output.
+// Default to OutputOrderInput.
+func WithOutputOrder(order OutputOrder) Option {
+	return func(e *EODProcessor) {
+		e.outputOrder = order
+	}
+}
+
+// WithIDTracking will make streamed and sharded run keep every account id of the input in memory, which is needed
+// to detect duplicated account id and the missing ids of the reconciliation. Without it only DuplicatePolicyIgnore
+// can be streamed and missing ids are not checked, so the memory doesn't grow with the input size.
+func WithIDTracking(trackIDs bool) Option {
+	return func(e *EODProcessor) {
+		e.trackIDs = trackIDs
+	}
+}
+
+// WithCurrency will set the currency of the run. Input rows with a currency column holding another currency
+// are rejected and output rows migrated from a version without currency default to it.
+// Default to empty which accept any currency, migrated output rows then default to DefaultCurrency.
+func WithCurrency(currency string) Option {
+	return func(e *EODProcessor) {
+		e.currency = currency
+	}
+}
+
+// idTracker record the account ids of a streamed or sharded run, it is nil and record nothing
+// when id tracking is disabled.
+type idTracker map[string]bool
+
+// newIDTracker will return a new idTracker, or nil if id tracking is disabled.
+func (e *EODProcessor) newIDTracker() idTracker {
+	if !e.trackIDs {
+		return nil
+	}
+	return make(idTracker)
+}
+
+// add will record given id if the tracker is not nil.
+func (t idTracker) add(id string) {
+	if t != nil {
+		t[id] = true
+	}
 }
 
 // NewEODProcessor will return a new EODProcessor to process data given it's pipeline executor.
-func NewEODProcessor(pipeline pipeline.IPipeline) *EODProcessor {
-	return &EODProcessor{
-		pipeline: pipeline,
+func NewEODProcessor(pipeline pipeline.IPipeline, options ...Option) *EODProcessor {
+	processor := &EODProcessor{
+		pipeline:        pipeline,
+		errorPolicy:     ErrorPolicyContinue,
+		inputFormat:     format.Semicolon,
+		outputFormat:    format.Semicolon,
+		columnAliases:   DefaultColumnAliases(),
+		duplicatePolicy: DuplicatePolicyRejectRun,
+		outputOrder:     OutputOrderInput,
+	}
+	for _, option := range options {
+		option(processor)
 	}
+	return processor
+}
+
+// Close will tear down the pipeline owned by the processor.
+// Will block until every stage has stopped, the processor must not be used after Close.
+func (e *EODProcessor) Close() {
+	e.pipeline.Close()
+	e.pipeline.Wait()
 }
 
 // Process will process from given input and output file name.
 // Will also write the result on the output file.
-func (e *EODProcessor) Process(ctx context.Context, inputFileName, outputFileName string) error {
-	result, err := e.ProcessFile(ctx, inputFileName, outputFileName)
+// Will also write failed rows on the reject file and the reconciliation on the reconciliation file if configured.
+// Will return the report of the run, the report is also returned when the run fail because of its error policy.
+// Will return ErrAlreadyProcessed if the ledger is configured and the run has already been processed.
+// The run is recorded as pending in the ledger before the output is replaced and as done after,
+// so a crash in between still refuse the next run unless forced.
+func (e *EODProcessor) Process(ctx context.Context, inputFileName, outputFileName string) (*RunReport, error) {
+	entry, err := e.checkLedger(inputFileName, outputFileName)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	result, report, err := e.ProcessFile(ctx, inputFileName, outputFileName)
+	if err := e.writeReportFiles(report); err != nil {
+		return report, err
+	}
+	if err != nil {
+		return report, err
+	}
+	// Don't replace the output once cancelled even if the processing has completed.
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	fileHandle, err := os.Create(outputFileName)
+	err = publishFileAtomic(outputFileName, e.backupFileName(outputFileName), func(w io.Writer) error {
+		return format.WriteAll(e.outputFormat.NewWriter(w), result)
+	}, func() error {
+		return e.beginLedger(entry, report)
+	})
 	if err != nil {
-		return fmt.Errorf(`failed to write to provided output file %w`, err)
+		return report, e.abortLedger(entry, fmt.Errorf(`failed to write to provided output file %w`, err))
 	}
-	writer := csv.NewWriter(fileHandle)
-	writer.Comma = ';'
-	return writer.WriteAll(result)
+	return report, e.finishLedger(entry, report)
 }
 
 // ProcessFile will read from given input file name and output template file name.
-// Will read the input and output as CSV file.
-// If output file is not found then it will assume that the template is empty will treat it as empty slice.
-// Will return slice resulted from the operation that can be treated as CSV.
+// Will read the input in the input format and the output in the output format.
+// If output file is not found or empty then it will assume that the template is empty will treat it as empty slice.
+// Will return slice resulted from the operation that can be treated as CSV and the report of the run.
 // Will return nil slice and an error on fail.
-func (e *EODProcessor) ProcessFile(ctx context.Context, inputFileName, outputTemplateFileName string) ([][]string, error) {
+func (e *EODProcessor) ProcessFile(ctx context.Context, inputFileName, outputTemplateFileName string) ([][]string, *RunReport, error) {
 	inputHandle, err := os.Open(inputFileName)
 	if err != nil {
-		return nil, fmt.Errorf(`failed to process provided input file %w`, err)
+		return nil, nil, fmt.Errorf(`failed to process provided input file %w`, err)
 	}
 	defer inputHandle.Close()
 	// Make sure input is valid so we won't waste unnecessary read on output file.
-	reader := csv.NewReader(inputHandle)
-	reader.Comma = ';'
-	inputRows, err := reader.ReadAll()
+	inputRows, err := format.ReadAll(e.inputFormat.NewReader(inputHandle))
 	if err != nil {
-		return nil, fmt.Errorf(`failed to process provided input file %w`, err)
+		return nil, nil, fmt.Errorf(`failed to process provided input file %w`, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	outputRows, err := e.readTemplate(outputTemplateFileName, len(inputRows))
+	if err != nil {
+		return nil, nil, err
 	}
+	return e.ProcessSlice(ctx, inputRows, outputRows)
+}
+
+// readTemplate will read the output template rows from given file name in the output format.
+// If the file is not found or empty then it will return only the header.
+// Given capacity hint is the expected amount of rows when the file is not found.
+func (e *EODProcessor) readTemplate(outputTemplateFileName string, capacityHint int) ([][]string, error) {
 	outputHandle, err := os.Open(outputTemplateFileName)
-	var outputRows [][]string
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
 			return nil, err
@@ -108,69 +346,713 @@ func (e *EODProcessor) ProcessFile(ctx context.Context, inputFileName, outputTem
 		// Handle case where the output file is not found.
 		// In this case we treat it as empty rows.
 		// +1 capacity since the first row is always header to avoid resize.
-		outputRows = make([][]string, 1, len(inputRows)+1)
+		outputRows := make([][]string, 1, capacityHint+1)
 		outputRows[0] = afterEodCSVHeader
-	} else {
-		// Handle case where the output file is provided.
-		// It will instead use that file as read source template to maintain ordering.
-		defer outputHandle.Close()
-		reader = csv.NewReader(outputHandle)
-		reader.Comma = ';'
-		outputRows, err = reader.ReadAll()
+		return outputRows, nil
+	}
+	// Handle case where the output file is provided.
+	// It will instead use that file as read source template to maintain ordering.
+	defer outputHandle.Close()
+	outputRows, err := format.ReadAll(e.outputFormat.NewReader(outputHandle))
+	if err != nil {
+		return nil, fmt.Errorf(`failed to process provided output file %w`, err)
+	}
+	// Formats such as JSON Lines can't keep the header without any row.
+	if len(outputRows) == 0 {
+		outputRows = append(outputRows, afterEodCSVHeader)
+	}
+	return outputRows, nil
+}
+
+// ProcessStreamFile will process given input file name into output file name in streaming mode.
+// Unlike Process, the output file is not used as template and will always be replaced.
+// The output file is only replaced once the whole input has been processed successfully.
+// Will also write failed rows on the reject file and the reconciliation on the reconciliation file if configured.
+// Will return ErrAlreadyProcessed if the ledger is configured and the run has already been processed.
+func (e *EODProcessor) ProcessStreamFile(ctx context.Context, inputFileName, outputFileName string) (*RunReport, error) {
+	return e.processFile(inputFileName, outputFileName, func(input *os.File, output io.Writer) (*RunReport, error) {
+		return e.ProcessStream(ctx, input, output)
+	})
+}
+
+// processFile will check the ledger then process given input file name into output file name using given process function.
+// The output file is only replaced once the process function succeed and the run is recorded as pending in the ledger.
+// Will also write the report files, record the audit log and record the run as done in the ledger.
+func (e *EODProcessor) processFile(inputFileName, outputFileName string, process func(input *os.File, output io.Writer) (*RunReport, error)) (*RunReport, error) {
+	entry, err := e.checkLedger(inputFileName, outputFileName)
+	if err != nil {
+		return nil, err
+	}
+	inputHandle, err := os.Open(inputFileName)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to process provided input file %w`, err)
+	}
+	defer inputHandle.Close()
+	var report *RunReport
+	var processErr error
+	err = publishFileAtomic(outputFileName, e.backupFileName(outputFileName), func(w io.Writer) error {
+		report, processErr = process(inputHandle, w)
+		return processErr
+	}, func() error {
+		return e.beginLedger(entry, report)
+	})
+	reportErr := e.writeReportFiles(report)
+	if processErr != nil {
+		if reportErr != nil {
+			return report, reportErr
+		}
+		return report, processErr
+	}
+	if err != nil {
+		return report, e.abortLedger(entry, fmt.Errorf(`failed to write to provided output file %w`, err))
+	}
+	// The output is published, so the run is recorded even if its report files fail.
+	if err := e.finishLedger(entry, report); err != nil {
+		return report, err
+	}
+	return report, reportErr
+}
+
+// writeReportFiles will write the reject file and the reconciliation file of given report if configured.
+// Does nothing if the report is nil.
+func (e *EODProcessor) writeReportFiles(report *RunReport) error {
+	if report == nil {
+		return nil
+	}
+	if len(e.rejectFileName) > 0 {
+		if err := writeRejectFile(e.rejectFileName, report); err != nil {
+			return err
+		}
+	}
+	if len(e.reconciliationFileName) > 0 {
+		if err := writeReconciliationFile(e.reconciliationFileName, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupFileName will return the backup file name of given output file name.
+// Will return empty string if backup is not enabled.
+func (e *EODProcessor) backupFileName(outputFileName string) string {
+	if !e.backup {
+		return ""
+	}
+	return outputFileName + backupFileSuffix
+}
+
+// checkLedger will check whether the run of given input file has already been recorded in the ledger.
+// Will return the entry to be recorded once the run succeed, or nil entry if the ledger is not configured.
+// Will return ErrAlreadyProcessed if the run has already been processed and is not forced.
+func (e *EODProcessor) checkLedger(inputFileName, outputFileName string) (*LedgerEntry, error) {
+	if e.ledger == nil {
+		return nil, nil
+	}
+	checksum, err := fileChecksum(inputFileName)
+	if err != nil {
+		return nil, err
+	}
+	entry := &LedgerEntry{
+		BusinessDate: e.runBusinessDate().Format(BusinessDateLayout),
+		Checksum:     checksum,
+		InputFile:    inputFileName,
+		OutputFile:   outputFileName,
+		Forced:       e.force,
+	}
+	if err := e.findProcessed(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// findProcessed will return ErrAlreadyProcessed if the run of given entry is already recorded in the ledger.
+// Does nothing if the run is forced.
+func (e *EODProcessor) findProcessed(entry *LedgerEntry) error {
+	if e.force {
+		return nil
+	}
+	find := e.ledger.Find
+	if e.ledgerPerInput {
+		find = func(businessDate, checksum string) (*LedgerEntry, error) {
+			return e.ledger.FindInput(businessDate, entry.InputFile, checksum)
+		}
+	}
+	previous, err := find(entry.BusinessDate, entry.Checksum)
+	if err != nil {
+		return err
+	}
+	if previous == nil {
+		return nil
+	}
+	state := "processed"
+	if previous.Status == LedgerStatusPending {
+		state = "left pending"
+	}
+	return fmt.Errorf(`%w, %s by run "%s" of business date "%s" with input "%s" %s at %s, use force to process it again`,
+		ErrAlreadyProcessed, ledgerMatch(entry, previous), previous.RunID, previous.BusinessDate, previous.InputFile,
+		state, previous.ProcessedAt.Format(time.RFC3339))
+}
+
+// ledgerMatch will describe which key of given entry matched given previous entry of the ledger.
+func ledgerMatch(entry, previous *LedgerEntry) string {
+	sameDate := previous.BusinessDate == entry.BusinessDate
+	sameChecksum := previous.Checksum == entry.Checksum
+	switch {
+	case sameDate && sameChecksum:
+		return fmt.Sprintf(`business date "%s" and input checksum "%s" matched`, entry.BusinessDate, entry.Checksum)
+	case sameChecksum:
+		return fmt.Sprintf(`input checksum "%s" matched`, entry.Checksum)
+	default:
+		return fmt.Sprintf(`business date "%s" matched`, entry.BusinessDate)
+	}
+}
+
+// runBusinessDate will return the business date of the run, default to the current date.
+func (e *EODProcessor) runBusinessDate() time.Time {
+	if e.businessDate.IsZero() {
+		return time.Now()
+	}
+	return e.businessDate
+}
+
+// beginLedger will record given entry of the run of given report as pending into the ledger before its output is published.
+// The ledger is checked again under the ledger lock since another process may have recorded the run after checkLedger.
+// Does nothing if the ledger is not configured.
+func (e *EODProcessor) beginLedger(entry *LedgerEntry, report *RunReport) error {
+	if entry == nil {
+		return nil
+	}
+	entry.RunID = report.RunID
+	entry.ProcessedRows = report.ProcessedRows
+	entry.FailedRows = len(report.FailedRows)
+	return e.ledger.Begin(entry, func() error {
+		return e.findProcessed(entry)
+	})
+}
+
+// finishLedger will record the audit log of the run of given report then record given pending entry as done,
+// the entry is recorded as done even if the audit log fail since the output is already published.
+// Does nothing with the ledger if it is not configured.
+func (e *EODProcessor) finishLedger(entry *LedgerEntry, report *RunReport) error {
+	auditErr := e.recordAudit(entry, report)
+	if entry != nil {
+		if err := e.ledger.Finish(entry); err != nil {
+			return err
+		}
+	}
+	return auditErr
+}
+
+// abortLedger will record given entry as aborted if it is pending, then return given error of the run.
+// Does nothing with the ledger if it is not configured or the entry has not been recorded.
+func (e *EODProcessor) abortLedger(entry *LedgerEntry, runErr error) error {
+	if entry == nil || entry.Status != LedgerStatusPending {
+		return runErr
+	}
+	if err := e.ledger.Abort(entry); err != nil {
+		return fmt.Errorf("%w, %v", runErr, err)
+	}
+	return runErr
+}
+
+// ProcessStream will read the input in the input format row by row, push each row into the pipeline
+// as soon as it is read and write each finished row into the output as soon as it complete.
+// At most streamMaxInFlight rows are kept in memory at any time, so memory usage doesn't grow with the input size,
+// except for the failed rows kept on the report, the changed rows kept until the run is recorded when an audit log
+// is configured, and the account ids kept when id tracking is enabled.
+// Without id tracking the missing ids of the reconciliation are not checked.
+// Rows are written in input order through a reorder buffer, a finished row is held until every row before it
+// has been written, failed rows are not written. Held rows count toward streamMaxInFlight.
+// Will stop reading once the context is done and return its error after the in-flight rows are drained.
+// Will return the report of the run, the report is also returned when the run fail because of its error policy.
+// Since rows are written as soon as they complete, the output must be discarded when an error is returned.
+// Only the first row of a duplicated account id is processed, the run fail once the input is read if
+// the duplicate policy is DuplicatePolicyRejectRun. DuplicatePolicyMerge is not supported, and detecting duplicates
+// keep every account id in memory so only DuplicatePolicyIgnore is supported unless id tracking is enabled.
+// Rows are planned one by one just before they are pushed, unless the pipeline need planning ahead,
+// such as a bonus not ordered by index. If the pipeline need planning ahead or the duplicate policy is
+// DuplicatePolicyKeepLast, the input is read more than once and must implement io.Seeker. OutputOrderID and
+// output format buffering every row such as format.Columnar are not supported.
+func (e *EODProcessor) ProcessStream(ctx context.Context, input io.Reader, output io.Writer) (*RunReport, error) {
+	if err := e.checkStreamDuplicatePolicy(); err != nil {
+		return nil, err
+	}
+	if err := e.checkStreamOutput(); err != nil {
+		return nil, err
+	}
+	var lastIndexes map[string]int
+	if e.duplicatePolicy == DuplicatePolicyKeepLast {
+		var err error
+		if lastIndexes, err = e.scanLastIndexes(ctx, input); err != nil {
+			return nil, err
+		}
+	}
+	var run *pipeline.Run
+	planner, planInline := e.pipeline.(pipeline.Planner)
+	if planInline && pipeline.NeedPlanAhead(planner) {
+		var err error
+		filter := newDuplicateFilter(e.duplicatePolicy, DuplicateSourceInput, lastIndexes)
+		if run, err = e.planStream(ctx, planner, filter, input); err != nil {
+			return nil, err
+		}
+		planInline = false
+	}
+	reader := e.inputFormat.NewReader(input)
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrInvalidInputRows
+		}
+		return nil, fmt.Errorf(`failed to process provided input stream %w`, err)
+	}
+	inputSchema, mapping, err := detectSchema(inputSchemas, e.inputSchema, header, e.columnAliases)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate input header, %w", err)
+	}
+	if run == nil {
+		run = e.newRun(ctx, mapping.header)
+	}
+	outputSchema := e.outputSchemaOf(inputSchema, nil)
+	layout := newOutputLayout(mapping.header, len(inputSchema.Columns), outputSchema, nil)
+	writer := e.outputFormat.NewWriter(output)
+	if err := writer.Write(layout.header); err != nil {
+		return nil, fmt.Errorf(`failed to write to provided output stream %w`, err)
+	}
+
+	// inFlight act as semaphore to bound the amount of rows inside the pipeline.
+	inFlight := make(chan struct{}, streamMaxInFlight)
+	finishChannel := make(chan *pipeline.EODRowData, streamMaxInFlight)
+	writeResult := make(chan error, 1)
+	report := &RunReport{
+		RunID:       newRunID(),
+		inputHeader: mapping.header,
+		audited:     e.auditLog != nil,
+	}
+	outputIDs := e.newIDTracker()
+	outputRows := 0
+	reorder := newReorderBuffer(0)
+	go func() {
+		var writeErr error
+		for finished := range finishChannel {
+			for _, data := range reorder.add(finished) {
+				report.add(data)
+				formatOutputRow(data)
+				// Keep draining on failure so no stage is blocked on the finish channel.
+				if writeErr == nil && data.Error == nil {
+					writeErr = writer.Write(data.OutputRow)
+					outputIDs.add(data.OutputRow[afterEodHeaderIdxID])
+					outputRows++
+				}
+				<-inFlight
+			}
+		}
+		if err := writer.Close(); writeErr == nil {
+			writeErr = err
+		}
+		writeResult <- writeErr
+	}()
+
+	channel := e.pipeline.Channel()
+	filter := newDuplicateFilter(e.duplicatePolicy, DuplicateSourceInput, lastIndexes)
+	inputIDs := e.newIDTracker()
+	inputRows := 0
+	var readErr error
+feed:
+	for idx := 0; ; idx++ {
+		row, err := reader.Read()
 		if err != nil {
-			return nil, fmt.Errorf(`failed to process provided output file %w`, err)
+			if !errors.Is(err, io.EOF) {
+				readErr = fmt.Errorf(`failed to process provided input stream %w`, err)
+			}
+			break
+		}
+		row = mapping.apply(row)
+		inputRows++
+		inputIDs.add(row[beforeEodHeaderIdxID])
+		if filter.skip(idx, row[beforeEodHeaderIdxID]) {
+			reorder.skip(idx)
+			continue
+		}
+		select {
+		case inFlight <- struct{}{}:
+		case <-ctx.Done():
+			break feed
+		}
+		data := &pipeline.EODRowData{
+			Index:         idx,
+			InputRow:      row,
+			OutputRow:     layout.newRow(row),
+			Run:           run,
+			FinishChannel: finishChannel,
+		}
+		if planInline {
+			if err := planRow(planner, data); err != nil {
+				<-inFlight
+				readErr = err
+				break feed
+			}
+		}
+		select {
+		case channel <- data:
+		case <-ctx.Done():
+			<-inFlight
+			break feed
 		}
 	}
-	return e.ProcessSlice(ctx, inputRows, outputRows)
+	// Filling the semaphore means every row pushed has been written.
+	for i := 0; i < streamMaxInFlight; i++ {
+		inFlight <- struct{}{}
+	}
+	close(finishChannel)
+	writeErr := <-writeResult
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	if writeErr != nil {
+		return nil, fmt.Errorf(`failed to write to provided output stream %w`, writeErr)
+	}
+	report.Duplicates = filter.result()
+	if e.duplicatePolicy == DuplicatePolicyRejectRun && len(report.Duplicates) > 0 {
+		return nil, duplicateError(report.Duplicates)
+	}
+	report.finish(inputRows, outputRows, inputIDs, outputIDs)
+	return report, e.errorPolicy.Check(report)
 }
 
 // ProcessSlice will process given slices that can be treated as CSV given it's input and output rows.
 // Will return updated output rows with any addition if necessary.
+// Rows are ordered following the output order, with OutputOrderInput the rows missing from the template
+// are added in input order since their output row is allocated in input order before any row is processed.
+// Failed rows that are not part of the output rows template are kept out of the result.
+// Rows sharing the same account id are handled following the duplicate policy before any row is processed.
+// Will stop pushing rows once the context is done and return its error after the in-flight rows are drained.
+// Will also return the report of the run, the report is also returned when the run fail because of its error policy.
 // Will return nil slice and an error on fail.
-func (e *EODProcessor) ProcessSlice(ctx context.Context, inputRows, outputRows [][]string) ([][]string, error) {
-	outputIDMap, outputRows, err := e.preProcessRows(ctx, inputRows, outputRows)
+func (e *EODProcessor) ProcessSlice(ctx context.Context, inputRows, outputRows [][]string) ([][]string, *RunReport, error) {
+	inputRows, outputRows, layout, err := e.mapRows(ctx, inputRows, outputRows)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	skipped, outputRows, duplicates, mergeRejected, err := e.resolveDuplicates(inputRows, outputRows)
+	if err != nil {
+		return nil, nil, err
+	}
+	outputIDMap, outputRows := e.preProcessRows(inputRows, outputRows, layout, skipped)
 	waitGroup := &sync.WaitGroup{}
-	// Adjustment for headers
 	writer := NewWriter(waitGroup)
-	waitGroup.Add(len(inputRows) - 1)
-	channel := e.pipeline.Channel()
+	defer func() {
+		writer.Close()
+		writer.Wait()
+	}()
+	run := e.newRun(ctx, inputRows[0])
+	rows := make([]*pipeline.EODRowData, 0, len(inputRows)-1)
+	// Skip header
 	for idx, row := range inputRows[1:] {
-		channel <- &pipeline.EODRowData{
+		// Skipped duplicates keep every processed row owning its output row.
+		if skipped[idx] {
+			continue
+		}
+		rows = append(rows, &pipeline.EODRowData{
 			Index:         idx,
 			InputRow:      row,
 			OutputRow:     outputRows[outputIDMap[row[0]]],
+			Run:           run,
 			FinishChannel: writer.Channel(),
+		})
+	}
+	if planner, ok := e.pipeline.(pipeline.Planner); ok {
+		if err := e.plan(run, planner, rows); err != nil {
+			return nil, nil, err
 		}
 	}
+	channel := e.pipeline.Channel()
+	pushed := make([]*pipeline.EODRowData, 0, len(rows))
+feed:
+	for _, data := range rows {
+		waitGroup.Add(1)
+		select {
+		case channel <- data:
+			pushed = append(pushed, data)
+		case <-ctx.Done():
+			waitGroup.Done()
+			break feed
+		}
+	}
+	// Wait for every pushed row to be drained even when cancelled,
+	// so no stage is left blocked on its channel.
 	waitGroup.Wait()
-	return outputRows, nil
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	report := &RunReport{
+		RunID:       newRunID(),
+		Duplicates:  duplicates,
+		inputHeader: inputRows[0],
+		audited:     e.auditLog != nil,
+	}
+	rejectedRows := make(map[int]bool)
+	for _, rowError := range mergeRejected {
+		report.reject(rowError)
+		// Only the template row of the id is in the output since its input rows are skipped.
+		if idx, exist := outputIDMap[rowError.ID]; exist {
+			rejectedRows[idx] = true
+		}
+	}
+	for _, data := range pushed {
+		report.add(data)
+		if data.Error != nil {
+			rejectedRows[outputIDMap[data.InputRow[beforeEodHeaderIdxID]]] = true
+			continue
+		}
+		// Rows from the template still hold the carried columns of the previous input.
+		layout.copyCarried(data.InputRow, data.OutputRow)
+	}
+	outputRows = removeRejectedRows(outputRows, rejectedRows)
+	sortRows(outputRows, e.outputOrder)
+	report.finish(len(inputRows)-1, len(outputRows)-1, rowIDs(inputRows, int(beforeEodHeaderIdxID)), rowIDs(outputRows, int(afterEodHeaderIdxID)))
+	if err := e.errorPolicy.Check(report); err != nil {
+		return nil, report, err
+	}
+	return outputRows, report, nil
 }
 
-// preProcessRows will perform rows preprocessing to fill missing output before being processed.
-// Will also peform validation to prevent executing on bad data.
-// Will return map indicating the id to row index and updated output rows on fixed data.
-// Will return nil map and slice and an error on fail.
-func (e *EODProcessor) preProcessRows(ctx context.Context, inputRows, outputRows [][]string) (map[string]int, [][]string, error) {
+// rowIDs will return the set of ids of given rows skipping the header.
+func rowIDs(rows [][]string, idColumn int) map[string]bool {
+	ids := make(map[string]bool, len(rows))
+	for _, row := range rows[1:] {
+		ids[row[idColumn]] = true
+	}
+	return ids
+}
+
+// removeRejectedRows will remove rejected rows from the output, including rows of the output template
+// so a rejected account doesn't carry the result of the previous run into the output.
+func removeRejectedRows(outputRows [][]string, rejectedRows map[int]bool) [][]string {
+	if len(rejectedRows) == 0 {
+		return outputRows
+	}
+	result := outputRows[:1]
+	for idx := 1; idx < len(outputRows); idx++ {
+		if !rejectedRows[idx] {
+			result = append(result, outputRows[idx])
+		}
+	}
+	return result
+}
+
+// plan will plan given rows of a run in input order on given planner.
+// Rows that can't be parsed are skipped since they will be failed by the parser.
+func (e *EODProcessor) plan(run *pipeline.Run, planner pipeline.Planner, rows []*pipeline.EODRowData) error {
+	for _, data := range rows {
+		if err := run.Context.Err(); err != nil {
+			return err
+		}
+		if err := planRow(planner, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkStreamOutput will return an error if the output order or the output format need every row of the output
+// before writing it, which can't be streamed with bounded memory.
+// Will return ErrOutputOrderNotStreamable or ErrOutputFormatNotStreamable.
+func (e *EODProcessor) checkStreamOutput() error {
+	if e.outputOrder == OutputOrderID {
+		return fmt.Errorf(`%w, "%s" need every row of the output`, ErrOutputOrderNotStreamable, e.outputOrder)
+	}
+	if _, ok := e.outputFormat.(format.BufferedFormat); ok {
+		return fmt.Errorf(`%w, "%s" buffer every row of the output`, ErrOutputFormatNotStreamable, e.outputFormat.Name())
+	}
+	return nil
+}
+
+// planRow will plan given data on given planner.
+// The data is planned on a copy so parsed value doesn't leak into the pipeline,
+// data that can't be parsed is skipped since it will be failed by the parser.
+func planRow(planner pipeline.Planner, data *pipeline.EODRowData) error {
+	planned := *data
+	if err := parseInputRow(&planned); err != nil {
+		return nil
+	}
+	if err := planner.Plan(&planned); err != nil {
+		return fmt.Errorf("failed to plan the run, %w", err)
+	}
+	return nil
+}
+
+// planStream will create a run for given input and plan every row of the input kept by given filter on given planner,
+// then rewind the input so it can be processed.
+// Will return nil run if the input header is invalid since it is reported when the input is processed.
+func (e *EODProcessor) planStream(ctx context.Context, planner pipeline.Planner, filter *duplicateFilter, input io.Reader) (*pipeline.Run, error) {
+	var run *pipeline.Run
+	err := rewind(input, func() error {
+		var err error
+		run, err = e.planReader(ctx, planner, filter, input)
+		return err
+	})
+	return run, err
+}
+
+// planReader will create a run for given input and plan every row read from the input kept by given filter on given planner.
+// Malformed row only stop the planning since it is reported when the input is processed.
+func (e *EODProcessor) planReader(ctx context.Context, planner pipeline.Planner, filter *duplicateFilter, input io.Reader) (*pipeline.Run, error) {
+	reader := e.inputFormat.NewReader(input)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil
+	}
+	_, mapping, err := detectSchema(inputSchemas, e.inputSchema, header, e.columnAliases)
+	if err != nil {
+		return nil, nil
+	}
+	run := e.newRun(ctx, mapping.header)
+	for idx := 0; ; idx++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		row, err := reader.Read()
+		if err != nil {
+			return run, nil
+		}
+		row = mapping.apply(row)
+		if filter.skip(idx, row[beforeEodHeaderIdxID]) {
+			continue
+		}
+		data := &pipeline.EODRowData{
+			Index:    idx,
+			InputRow: row,
+			Run:      run,
+		}
+		if err := planRow(planner, data); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// scanLastIndexes will read given input and return the index of the last row of every id,
+// then rewind the input so it can be processed.
+// Will return nil map if the input header is invalid since it is reported when the input is processed.
+func (e *EODProcessor) scanLastIndexes(ctx context.Context, input io.Reader) (map[string]int, error) {
+	var indexes map[string]int
+	err := rewind(input, func() error {
+		reader := e.inputFormat.NewReader(input)
+		header, err := reader.Read()
+		if err != nil {
+			return nil
+		}
+		_, mapping, err := detectSchema(inputSchemas, e.inputSchema, header, e.columnAliases)
+		if err != nil {
+			return nil
+		}
+		indexes = make(map[string]int)
+		for idx := 0; ; idx++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			row, err := reader.Read()
+			if err != nil {
+				return nil
+			}
+			indexes[mapping.apply(row)[beforeEodHeaderIdxID]] = idx
+		}
+	})
+	return indexes, err
+}
+
+// rewind will call given function then seek given input back to where it was before the call.
+// Will return ErrInputNotSeekable if the input doesn't implement io.Seeker.
+func rewind(input io.Reader, read func() error) error {
+	seeker, ok := input.(io.Seeker)
+	if !ok {
+		return ErrInputNotSeekable
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf(`failed to rewind provided input stream %w`, err)
+	}
+	if err := read(); err != nil {
+		return err
+	}
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return fmt.Errorf(`failed to rewind provided input stream %w`, err)
+	}
+	return nil
+}
+
+// mapRows will validate given rows and map their columns by header name into the known columns order of their schema.
+// Output template rows are migrated into the output layout that also hold the extra columns of the input.
+// Will return the mapped input and output rows with the output layout.
+// Will return nil slices and an error on fail.
+func (e *EODProcessor) mapRows(ctx context.Context, inputRows, outputRows [][]string) ([][]string, [][]string, *outputLayout, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
 	// Validate rows length
 	if len(inputRows) == 0 {
-		return nil, nil, ErrInvalidInputRows
+		return nil, nil, nil, ErrInvalidInputRows
 	}
 	if len(outputRows) == 0 {
-		return nil, nil, ErrInvalidOutputRows
+		return nil, nil, nil, ErrInvalidOutputRows
 	}
 
 	// Validate headers
-	if err := e.validateHeaders(beforeEodCSVHeader, inputRows[0]); err != nil {
-		return nil, nil, fmt.Errorf("failed to validate input header, %w", err)
+	inputSchema, inputMapping, err := detectSchema(inputSchemas, e.inputSchema, inputRows[0], e.columnAliases)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to validate input header, %w", err)
+	}
+	templateSchema, templateMapping, err := detectSchema(outputSchemas, nil, outputRows[0], e.columnAliases)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to validate output header, %w", err)
+	}
+	templateExtras := templateMapping.header[len(templateSchema.Columns):]
+	// Template without any row doesn't decide the output schema.
+	if len(outputRows) == 1 {
+		templateSchema = nil
 	}
+	outputSchema := e.outputSchemaOf(inputSchema, templateSchema)
 
-	if err := e.validateHeaders(afterEodCSVHeader, outputRows[0]); err != nil {
-		return nil, nil, fmt.Errorf("failed to validate output header, %w", err)
+	inputRows = inputMapping.applyAll(inputRows)
+	outputRows = templateMapping.applyAll(outputRows)
+	layout := newOutputLayout(inputRows[0], len(inputSchema.Columns), outputSchema, templateExtras)
+	// Migrate the template into the output schema.
+	outputRows = newRowMigration(outputRows[0], layout.header, outputSchema.Defaults).applyAll(outputRows, layout.header)
+	return inputRows, outputRows, layout, nil
+}
+
+// newRun will return a new run of the processor currency given its context and its mapped input header.
+func (e *EODProcessor) newRun(ctx context.Context, inputHeader []string) *pipeline.Run {
+	run := pipeline.NewRun(ctx, inputHeader)
+	run.Currency = e.currency
+	return run
+}
+
+// outputSchemaOf will return the schema of the output given the schema of the input and the output template.
+// Template schema is nil if the template has no row. The currency of the output default to the run currency.
+func (e *EODProcessor) outputSchemaOf(inputSchema, templateSchema *Schema) *Schema {
+	outputSchema := e.outputSchema
+	if outputSchema == nil {
+		outputSchema = templateSchema
 	}
+	if outputSchema == nil {
+		var err error
+		if outputSchema, err = OutputSchema(inputSchema.Version); err != nil {
+			outputSchema = outputSchemas[len(outputSchemas)-1]
+		}
+	}
+	if len(e.currency) == 0 {
+		return outputSchema
+	}
+	return outputSchema.withCurrency(e.currency)
+}
 
+// preProcessRows will perform rows preprocessing to fill missing output before being processed.
+// Given rows must already be mapped, input rows of given skipped index are not added into the output.
+// Will return map indicating the id to row index and updated output rows on fixed data.
+func (e *EODProcessor) preProcessRows(inputRows, outputRows [][]string, layout *outputLayout, skipped map[int]bool) (map[string]int, [][]string) {
 	maxCapacity := len(inputRows)
 	outputLen := len(outputRows)
 	if outputLen > maxCapacity {
@@ -188,35 +1070,17 @@ func (e *EODProcessor) preProcessRows(ctx context.Context, inputRows, outputRows
 	// This is performed early instead of dynamic append
 	// on finish so it can be lock free operation.
 	outputRowLastIndex := len(outputRows)
-	for _, row := range inputRows[1:] {
+	for idx, row := range inputRows[1:] {
+		if skipped[idx] {
+			continue
+		}
 		rowID := row[beforeEodHeaderIdxID]
 		if _, exist := outputIDRowMap[rowID]; !exist {
 			// Fill missing data on output row
-			outputRows = append(outputRows, []string{
-				row[beforeEodHeaderIdxID],
-				row[beforeEodHeaderIdxNama],
-				row[beforeEodHeaderIdxAge],
-				row[beforeEodHeaderIdxBalanced], "", "",
-				row[beforeEodHeaderIdxPreviousBalanced], "", "",
-				row[beforeEodHeaderIdxFreeTransfer], "",
-			})
+			outputRows = append(outputRows, layout.newRow(row))
 			outputIDRowMap[rowID] = outputRowLastIndex
 			outputRowLastIndex++
 		}
 	}
-	return outputIDRowMap, outputRows, nil
-}
-
-// validateHeaders will perform header validation against given columns.
-// Will return error when it doesn't match required headers.
-func (e *EODProcessor) validateHeaders(headers []string, columns []string) error {
-	if len(columns) < len(headers) {
-		return ErrInvalidHeader
-	}
-	for i, header := range headers {
-		if columns[i] != header {
-			return fmt.Errorf(`invalid row provided for header "%s" at index "%d"`, header, i)
-		}
-	}
-	return nil
+	return outputIDRowMap, outputRows
 }
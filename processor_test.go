@@ -4,11 +4,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/csv"
+	"errors"
+	"io"
 	"io/ioutil"
 	"os"
 	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/firmanmm/bank-eod-processor/format"
+	"github.com/firmanmm/bank-eod-processor/money"
 	"github.com/firmanmm/bank-eod-processor/pipeline"
 )
 
@@ -60,11 +69,11 @@ func TestEODProcessor_ProcessSlice(t *testing.T) {
 			},
 			[][]string{
 				{"id", "Nama", "Age", "Balanced", "No 2b Thread-No", "No 3 Thread-No", "Previous Balanced", "Average Balanced", "No 1 Thread-No", "Free Transfer", "No 2a Thread-No"},
-				{"1", "Test 1", "24", "186", "1", "1", "100", "125", "1", "3", "0"},
+				{"1", "Test 1", "24", "186", "1", "1", "100", "125.50", "1", "3", "0"},
 				{"2", "Test 2", "25", "160", "0", "1", "150", "150", "1", "5", "1"},
 				{"3", "Test 3", "25", "110", "0", "1", "150", "125", "1", "5", "1"},
 				{"4", "Test 4", "25", "110", "0", "1", "100", "100", "1", "5", "1"},
-				{"5", "Test 5", "26", "109", "0", "1", "200", "149", "1", "2", "0"},
+				{"5", "Test 5", "26", "109", "0", "1", "200", "149.50", "1", "2", "0"},
 			},
 			false,
 		},
@@ -92,13 +101,13 @@ func TestEODProcessor_ProcessSlice(t *testing.T) {
 			},
 			[][]string{
 				{"id", "Nama", "Age", "Balanced", "No 2b Thread-No", "No 3 Thread-No", "Previous Balanced", "Average Balanced", "No 1 Thread-No", "Free Transfer", "No 2a Thread-No"},
-				{"1", "Test 1", "24", "186", "1", "1", "100", "125", "1", "3", "0"},
+				{"1", "Test 1", "24", "186", "1", "1", "100", "125.50", "1", "3", "0"},
 				{"2", "Test 2", "25", "160", "0", "1", "150", "150", "1", "5", "1"},
 				{"44", "Test 4", "25", "176", "", "", "100", "125", "", "3", ""},
 				{"54", "Test 5", "26", "176", "", "", "100", "125", "", "3", ""},
 				{"3", "Test 3", "25", "110", "0", "1", "150", "125", "1", "5", "1"},
 				{"4", "Test 4", "25", "110", "0", "1", "100", "100", "1", "5", "1"},
-				{"5", "Test 5", "26", "109", "0", "1", "200", "149", "1", "2", "0"},
+				{"5", "Test 5", "26", "109", "0", "1", "200", "149.50", "1", "2", "0"},
 			},
 			false,
 		},
@@ -121,11 +130,11 @@ func TestEODProcessor_ProcessSlice(t *testing.T) {
 			},
 			[][]string{
 				{"id", "Nama", "Age", "Balanced", "No 2b Thread-No", "No 3 Thread-No", "Previous Balanced", "Average Balanced", "No 1 Thread-No", "Free Transfer", "No 2a Thread-No"},
-				{"1", "Test 1", "24", "186", "1", "1", "100", "125", "1", "3", "0"},
+				{"1", "Test 1", "24", "186", "1", "1", "100", "125.50", "1", "3", "0"},
 				{"2", "Test 2", "25", "160", "0", "1", "150", "150", "1", "5", "1"},
 				{"3", "Test 3", "25", "110", "0", "1", "150", "125", "1", "5", "1"},
 				{"4", "Test 4", "25", "110", "0", "1", "100", "100", "1", "5", "1"},
-				{"5", "Test 5", "26", "109", "0", "1", "200", "149", "1", "2", "0"},
+				{"5", "Test 5", "26", "109", "0", "1", "200", "149.50", "1", "2", "0"},
 			},
 			false,
 		},
@@ -149,8 +158,8 @@ func TestEODProcessor_ProcessSlice(t *testing.T) {
 			[][]string{
 				{"id", "Nama", "Age", "Balanced", "No 2b Thread-No", "No 3 Thread-No", "Previous Balanced", "Average Balanced", "No 1 Thread-No", "Free Transfer", "No 2a Thread-No"},
 				{"4", "Test 4", "25", "110", "0", "1", "100", "100", "1", "5", "1"},
-				{"5", "Test 5", "26", "109", "0", "1", "200", "149", "1", "2", "0"},
-				{"1", "Test 1", "24", "186", "1", "1", "100", "125", "1", "3", "0"},
+				{"5", "Test 5", "26", "109", "0", "1", "200", "149.50", "1", "2", "0"},
+				{"1", "Test 1", "24", "186", "1", "1", "100", "125.50", "1", "3", "0"},
 				{"2", "Test 2", "25", "160", "0", "1", "150", "150", "1", "5", "1"},
 				{"3", "Test 3", "25", "110", "0", "1", "150", "125", "1", "5", "1"},
 			},
@@ -173,16 +182,16 @@ func TestEODProcessor_ProcessSlice(t *testing.T) {
 			},
 			[][]string{
 				{"id", "Nama", "Age", "Balanced", "No 2b Thread-No", "No 3 Thread-No", "Previous Balanced", "Average Balanced", "No 1 Thread-No", "Free Transfer", "No 2a Thread-No"},
-				{"1", "Test 1", "24", "186", "1", "1", "100", "125", "1", "3", "0"},
+				{"1", "Test 1", "24", "186", "1", "1", "100", "125.50", "1", "3", "0"},
 				{"2", "Test 2", "25", "160", "0", "1", "150", "150", "1", "5", "1"},
 				{"3", "Test 3", "25", "110", "0", "1", "150", "125", "1", "5", "1"},
 				{"4", "Test 4", "25", "110", "0", "1", "100", "100", "1", "5", "1"},
-				{"5", "Test 5", "26", "109", "0", "1", "200", "149", "1", "2", "0"},
+				{"5", "Test 5", "26", "109", "0", "1", "200", "149.50", "1", "2", "0"},
 			},
 			false,
 		},
 		{
-			"Given bad column error then it must still succeed",
+			"Given bad column error then it must exclude the row from the output template",
 			args{
 				inputRows: [][]string{
 					{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer"},
@@ -203,11 +212,34 @@ func TestEODProcessor_ProcessSlice(t *testing.T) {
 			},
 			[][]string{
 				{"id", "Nama", "Age", "Balanced", "No 2b Thread-No", "No 3 Thread-No", "Previous Balanced", "Average Balanced", "No 1 Thread-No", "Free Transfer", "No 2a Thread-No"},
-				{"1", "Test 1", "24", "186", "1", "1", "100", "125", "1", "3", "0"},
+				{"1", "Test 1", "24", "186", "1", "1", "100", "125.50", "1", "3", "0"},
 				{"2", "Test 2", "25", "160", "0", "1", "150", "150", "1", "5", "1"},
-				{"3", "Test 3", "25", "176", "", "1", "150", "125", "1", "3", "1"},
 				{"4", "Test 4", "25", "110", "0", "1", "100", "100", "1", "5", "1"},
-				{"5", "Test 5", "26", "109", "0", "1", "200", "149", "1", "2", "0"},
+				{"5", "Test 5", "26", "109", "0", "1", "200", "149.50", "1", "2", "0"},
+			},
+			false,
+		},
+		{
+			"Given bad column error and no output then it must exclude the row",
+			args{
+				inputRows: [][]string{
+					{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer"},
+					{"1", "Test 1", "24", "151", "100", "100", "3"},
+					{"2", "Test 2", "25", "150", "150", "100", "2"},
+					{"3", "Test 3", "25", "BAD", "150", "100", "2"},
+					{"4", "Test 4", "25", "100", "100", "100", "2"},
+					{"5", "Test 5", "26", "99", "200", "120", "2"},
+				},
+				outputRows: [][]string{
+					{"id", "Nama", "Age", "Balanced", "No 2b Thread-No", "No 3 Thread-No", "Previous Balanced", "Average Balanced", "No 1 Thread-No", "Free Transfer", "No 2a Thread-No"},
+				},
+			},
+			[][]string{
+				{"id", "Nama", "Age", "Balanced", "No 2b Thread-No", "No 3 Thread-No", "Previous Balanced", "Average Balanced", "No 1 Thread-No", "Free Transfer", "No 2a Thread-No"},
+				{"1", "Test 1", "24", "186", "1", "1", "100", "125.50", "1", "3", "0"},
+				{"2", "Test 2", "25", "160", "0", "1", "150", "150", "1", "5", "1"},
+				{"4", "Test 4", "25", "110", "0", "1", "100", "100", "1", "5", "1"},
+				{"5", "Test 5", "26", "109", "0", "1", "200", "149.50", "1", "2", "0"},
 			},
 			false,
 		},
@@ -343,7 +375,7 @@ func TestEODProcessor_ProcessSlice(t *testing.T) {
 			averageCalculator := pipeline.NewAverageCalculator(benefitCalculator.Channel())
 			parser := NewParser(averageCalculator.Channel())
 			eodCalculator := NewEODProcessor(parser)
-			got, err := eodCalculator.ProcessSlice(context.Background(), tt.args.inputRows, tt.args.outputRows)
+			got, _, err := eodCalculator.ProcessSlice(context.Background(), tt.args.inputRows, tt.args.outputRows)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("EODProcessor.ProcessSlice() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -425,11 +457,11 @@ func TestEODProcessor_Process(t *testing.T) {
 	eodCalculator.Process(context.Background(), inputPath, outputPath)
 	want := [][]string{
 		{"id", "Nama", "Age", "Balanced", "No 2b Thread-No", "No 3 Thread-No", "Previous Balanced", "Average Balanced", "No 1 Thread-No", "Free Transfer", "No 2a Thread-No"},
-		{"1", "Test 1", "36", "186", "1", "1", "100", "125", "1", "3", "0"},
+		{"1", "Test 1", "36", "186", "1", "1", "100", "125.50", "1", "3", "0"},
 		{"2", "Test 2", "39", "160", "0", "1", "150", "150", "1", "5", "1"},
 		{"3", "Test 3", "51", "110", "0", "1", "150", "125", "1", "5", "1"},
 		{"4", "Test 4", "39", "110", "0", "1", "100", "100", "1", "5", "1"},
-		{"5", "Test 5", "34", "109", "0", "1", "200", "149", "1", "2", "0"},
+		{"5", "Test 5", "34", "109", "0", "1", "200", "149.50", "1", "2", "0"},
 	}
 
 	targetPayload, err := ioutil.ReadFile(outputPath)
@@ -471,3 +503,484 @@ func TestEODProcessor_Process(t *testing.T) {
 		}
 	}
 }
+
+func TestEODProcessor_ProcessStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			"Given no error then it must succeed",
+			`id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer
+1;Test 1;24;151;100;100;3
+2;Test 2;25;150;150;100;2
+3;Test 3;25;100;150;100;2
+4;Test 4;25;100;100;100;2
+5;Test 5;26;99;200;120;2`,
+			map[string][]string{
+				"1": {"1", "Test 1", "24", "186", "1", "1", "100", "125.50", "1", "3", "0"},
+				"2": {"2", "Test 2", "25", "160", "0", "1", "150", "150", "1", "5", "1"},
+				"3": {"3", "Test 3", "25", "110", "0", "1", "150", "125", "1", "5", "1"},
+				"4": {"4", "Test 4", "25", "110", "0", "1", "100", "100", "1", "5", "1"},
+				"5": {"5", "Test 5", "26", "109", "0", "1", "200", "149.50", "1", "2", "0"},
+			},
+			false,
+		},
+		{
+			"Given bad row then it must exclude the row",
+			`id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer
+1;Test 1;24;151;100;100;3
+2;Test 2;25;BAD;150;100;2`,
+			map[string][]string{
+				"1": {"1", "Test 1", "24", "186", "1", "1", "100", "125.50", "1", "3", "0"},
+			},
+			false,
+		},
+		{
+			"Given only header then it must succeed",
+			`id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer`,
+			map[string][]string{},
+			false,
+		},
+		{
+			"Given wrong input header then it must fail",
+			`id;Nama;Age;Balanced;Previous Balanced;Average Balanced;FreeEEEEE Transfer
+1;Test 1;24;151;100;100;3`,
+			nil,
+			true,
+		},
+		{
+			"Given no input then it must fail",
+			``,
+			nil,
+			true,
+		},
+		{
+			"Given malformed input row then it must fail",
+			`id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer
+1;Test 1;24;151;100;100;3
+2;Test 2;25;150;150;100`,
+			nil,
+			true,
+		},
+	}
+	exactMatchIdx := []int{
+		int(afterEodHeaderIdxID),
+		int(afterEodHeaderIdxNama),
+		int(afterEodHeaderIdxAge),
+		int(afterEodHeaderIdxBalanced),
+		int(afterEodHeaderIdxAverageBalanced),
+		int(afterEodHeaderIdxFreeTransfer),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bonusDistributor := pipeline.NewBonusDistributor(nil)
+			benefitCalculator := pipeline.NewBenefitCalculator(bonusDistributor.Channel())
+			averageCalculator := pipeline.NewAverageCalculator(benefitCalculator.Channel())
+			parser := NewParser(averageCalculator.Channel())
+			eodCalculator := NewEODProcessor(parser, WithDuplicatePolicy(DuplicatePolicyIgnore))
+			output := &bytes.Buffer{}
+			_, err := eodCalculator.ProcessStream(context.Background(), strings.NewReader(tt.input), output)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EODProcessor.ProcessStream() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			reader := csv.NewReader(output)
+			reader.Comma = ';'
+			got, err := reader.ReadAll()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !reflect.DeepEqual(got[0], afterEodCSVHeader) {
+				t.Errorf("EODProcessor.ProcessStream() header = %v, want %v", got[0], afterEodCSVHeader)
+			}
+			if len(got)-1 != len(tt.want) {
+				t.Errorf("EODProcessor.ProcessStream() rows = %v, want %v", len(got)-1, len(tt.want))
+			}
+			for _, gotRow := range got[1:] {
+				wantRow, ok := tt.want[gotRow[afterEodHeaderIdxID]]
+				if !ok {
+					t.Errorf("EODProcessor.ProcessStream() unexpected row %v", gotRow)
+					continue
+				}
+				for _, val := range exactMatchIdx {
+					if gotRow[val] != wantRow[val] {
+						t.Errorf("EODProcessor.ProcessStream() exact match index = %v, want %v, id %v, valIdx %v", gotRow[val], wantRow[val], gotRow[afterEodHeaderIdxID], val)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEODProcessor_Cancelled(t *testing.T) {
+	input := [][]string{
+		{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer"},
+	}
+	for i := 0; i < 1000; i++ {
+		input = append(input, []string{strconv.Itoa(i), "Test", "24", "151", "100", "100", "3"})
+	}
+	output := [][]string{afterEodCSVHeader}
+	var inputCSV strings.Builder
+	for _, row := range input {
+		inputCSV.WriteString(strings.Join(row, ";") + "\n")
+	}
+
+	bonusDistributor := pipeline.NewBonusDistributor(nil)
+	benefitCalculator := pipeline.NewBenefitCalculator(bonusDistributor.Channel())
+	averageCalculator := pipeline.NewAverageCalculator(benefitCalculator.Channel())
+	parser := NewParser(averageCalculator.Channel())
+	eodCalculator := NewEODProcessor(parser, WithIDTracking(true))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := eodCalculator.ProcessSlice(ctx, input, output); err != context.Canceled {
+		t.Errorf("EODProcessor.ProcessSlice() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := eodCalculator.ProcessStream(ctx, strings.NewReader(inputCSV.String()), &bytes.Buffer{}); err != context.Canceled {
+		t.Errorf("EODProcessor.ProcessStream() error = %v, want %v", err, context.Canceled)
+	}
+
+	// Cancel in the middle of the processing.
+	ctx, cancel = context.WithCancel(context.Background())
+	go cancel()
+	if _, _, err := eodCalculator.ProcessSlice(ctx, input, [][]string{afterEodCSVHeader}); err != nil && err != context.Canceled {
+		t.Errorf("EODProcessor.ProcessSlice() error = %v, want %v", err, context.Canceled)
+	}
+	// The pipeline must still be usable after a cancelled run.
+	if _, _, err := eodCalculator.ProcessSlice(context.Background(), input, [][]string{afterEodCSVHeader}); err != nil {
+		t.Errorf("EODProcessor.ProcessSlice() error = %v, want nil", err)
+	}
+}
+
+func TestEODProcessor_Close(t *testing.T) {
+	bonusDistributor := pipeline.NewBonusDistributor(nil)
+	benefitCalculator := pipeline.NewBenefitCalculator(bonusDistributor.Channel())
+	averageCalculator := pipeline.NewAverageCalculator(benefitCalculator.Channel())
+	parser := NewParser(averageCalculator.Channel())
+	chain := pipeline.NewChain(parser, averageCalculator, benefitCalculator, bonusDistributor)
+	eodCalculator := NewEODProcessor(chain)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		_, _, err := eodCalculator.ProcessSlice(context.Background(), [][]string{
+			{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer"},
+			{"1", "Test 1", "24", "151", "100", "100", "3"},
+		}, [][]string{afterEodCSVHeader})
+		if err != nil {
+			t.Errorf("EODProcessor.ProcessSlice() error = %v, want nil", err)
+		}
+	}
+	// Writer created for each run must not leak.
+	if after := waitGoroutines(before); after > before {
+		t.Errorf("EODProcessor.ProcessSlice() goroutines = %v, want at most %v", after, before)
+	}
+	eodCalculator.Close()
+	if after := waitGoroutines(before - 1); after >= before {
+		t.Errorf("EODProcessor.Close() goroutines = %v, want less than %v", after, before)
+	}
+}
+
+// waitGoroutines will wait for a while until the number of goroutines is at most the target
+// since exited goroutines may still be counted for a moment.
+// Will return the last number of goroutines.
+func waitGoroutines(target int) int {
+	count := runtime.NumGoroutine()
+	for i := 0; i < 100 && count > target; i++ {
+		time.Sleep(10 * time.Millisecond)
+		count = runtime.NumGoroutine()
+	}
+	return count
+}
+
+func TestEODProcessor_ProcessSlice_Report(t *testing.T) {
+	inputRows := [][]string{
+		{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer"},
+		{"1", "Test 1", "24", "151", "100", "100", "3"},
+		{"2", "Test 2", "25", "BAD", "150", "100", "2"},
+		{"3", "Test 3", "25", "100", "150", "100", "2"},
+		{"4", "Test 4", "25", "100", "BAD", "100", "2"},
+	}
+	tests := []struct {
+		name       string
+		policy     ErrorPolicy
+		wantFailed []RowError
+		wantErr    bool
+	}{
+		{
+			"Given continue policy then it must succeed",
+			ErrorPolicyContinue,
+			[]RowError{
+				{ID: "2", Line: 3, Stage: ParserStageName, InputRow: inputRows[2]},
+				{ID: "4", Line: 5, Stage: ParserStageName, InputRow: inputRows[4]},
+			},
+			false,
+		},
+		{
+			"Given threshold policy within limit then it must succeed",
+			ErrorPolicyThreshold(2),
+			[]RowError{
+				{ID: "2", Line: 3, Stage: ParserStageName, InputRow: inputRows[2]},
+				{ID: "4", Line: 5, Stage: ParserStageName, InputRow: inputRows[4]},
+			},
+			false,
+		},
+		{
+			"Given threshold policy over limit then it must fail",
+			ErrorPolicyThreshold(1),
+			[]RowError{
+				{ID: "2", Line: 3, Stage: ParserStageName, InputRow: inputRows[2]},
+				{ID: "4", Line: 5, Stage: ParserStageName, InputRow: inputRows[4]},
+			},
+			true,
+		},
+		{
+			"Given fail run policy then it must fail",
+			ErrorPolicyFailRun,
+			[]RowError{
+				{ID: "2", Line: 3, Stage: ParserStageName, InputRow: inputRows[2]},
+				{ID: "4", Line: 5, Stage: ParserStageName, InputRow: inputRows[4]},
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bonusDistributor := pipeline.NewBonusDistributor(nil)
+			benefitCalculator := pipeline.NewBenefitCalculator(bonusDistributor.Channel())
+			averageCalculator := pipeline.NewAverageCalculator(benefitCalculator.Channel())
+			parser := NewParser(averageCalculator.Channel())
+			eodCalculator := NewEODProcessor(parser, WithErrorPolicy(tt.policy))
+			got, report, err := eodCalculator.ProcessSlice(context.Background(), inputRows, [][]string{afterEodCSVHeader})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EODProcessor.ProcessSlice() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrTooManyFailedRows) {
+					t.Errorf("EODProcessor.ProcessSlice() error = %v, want %v", err, ErrTooManyFailedRows)
+				}
+				if got != nil {
+					t.Errorf("EODProcessor.ProcessSlice() = %v, want nil", got)
+				}
+			}
+			if report.ProcessedRows != len(inputRows)-1 {
+				t.Errorf("EODProcessor.ProcessSlice() processed rows = %v, want %v", report.ProcessedRows, len(inputRows)-1)
+			}
+			if len(report.FailedRows) != len(tt.wantFailed) {
+				t.Errorf("EODProcessor.ProcessSlice() failed rows = %v, want %v", report.FailedRows, tt.wantFailed)
+				return
+			}
+			for idx, wantFailed := range tt.wantFailed {
+				gotFailed := report.FailedRows[idx]
+				if gotFailed.Err == nil {
+					t.Errorf("EODProcessor.ProcessSlice() failed row error = nil, want not nil")
+				}
+				gotFailed.Err = nil
+				if !reflect.DeepEqual(gotFailed, wantFailed) {
+					t.Errorf("EODProcessor.ProcessSlice() failed row = %v, want %v", gotFailed, wantFailed)
+				}
+			}
+		})
+	}
+}
+
+func TestEODProcessor_Planned(t *testing.T) {
+	input := `id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer
+1;Test 1;20;99;100;100;3
+2;Test 2;30;99;150;100;2
+3;Test 3;BAD;99;150;100;2
+4;Test 4;25;99;100;100;2
+5;Test 5;40;99;200;120;2
+6;Test 6;50;99;200;120;2`
+	// Only the first 2 rows aged 30 or more are given bonus, row with invalid age is kept without a validator but never eligible.
+	want := map[string]string{"1": "99", "2": "109", "3": "99", "4": "99", "5": "109", "6": "99"}
+
+	minAge := 30
+	config := &pipeline.BonusConfig{
+		Quota:  2,
+		Amount: money.FromInt(10),
+		Eligibility: pipeline.BonusEligibility{
+			MinAge: &minAge,
+		},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	bonusDistributor := pipeline.NewBonusDistributorWithConfig(nil, config)
+	benefitCalculator := pipeline.NewBenefitCalculator(bonusDistributor.Channel())
+	averageCalculator := pipeline.NewAverageCalculator(benefitCalculator.Channel())
+	parser := NewParser(averageCalculator.Channel())
+	chain := pipeline.NewChain(parser, averageCalculator, benefitCalculator, bonusDistributor)
+	eodCalculator := NewEODProcessor(chain, WithIDTracking(true))
+	defer eodCalculator.Close()
+
+	reader := csv.NewReader(strings.NewReader(input))
+	reader.Comma = ';'
+	inputRows, _ := reader.ReadAll()
+	got, _, err := eodCalculator.ProcessSlice(context.Background(), inputRows, [][]string{afterEodCSVHeader})
+	if err != nil {
+		t.Errorf("EODProcessor.ProcessSlice() error = %v, want nil", err)
+		return
+	}
+	for _, row := range got[1:] {
+		if row[afterEodHeaderIdxBalanced] != want[row[afterEodHeaderIdxID]] {
+			t.Errorf("EODProcessor.ProcessSlice() balanced = %v, want %v, id %v", row[afterEodHeaderIdxBalanced], want[row[afterEodHeaderIdxID]], row[afterEodHeaderIdxID])
+		}
+	}
+
+	// Ordered by index, rows are planned as they are read so the input doesn't need to be seekable.
+	output := &bytes.Buffer{}
+	nonSeekable := struct{ io.Reader }{strings.NewReader(input)}
+	if _, err := eodCalculator.ProcessStream(context.Background(), nonSeekable, output); err != nil {
+		t.Errorf("EODProcessor.ProcessStream() error = %v, want nil", err)
+		return
+	}
+	reader = csv.NewReader(output)
+	reader.Comma = ';'
+	got, _ = reader.ReadAll()
+	for _, row := range got[1:] {
+		if row[afterEodHeaderIdxBalanced] != want[row[afterEodHeaderIdxID]] {
+			t.Errorf("EODProcessor.ProcessStream() balanced = %v, want %v, id %v", row[afterEodHeaderIdxBalanced], want[row[afterEodHeaderIdxID]], row[afterEodHeaderIdxID])
+		}
+	}
+}
+
+func TestEODProcessor_ProcessStream_BuiltPipeline(t *testing.T) {
+	input := `id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer
+1;Test 1;24;100;100;100;3
+2;Test 2;25;100;100;100;2
+3;Test 3;40;100;100;100;2`
+	tests := []struct {
+		name    string
+		bonus   string
+		want    map[string]string
+		wantErr error
+	}{
+		{"Given index order then non seekable input must be processed", `{"quota":2,"amount":"10"}`, map[string]string{"1": "110", "2": "110", "3": "100"}, nil},
+		{"Given id order then non seekable input must fail since it is planned ahead", `{"quota":2,"amount":"10","order_by":"id"}`, nil, ErrInputNotSeekable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultPipelineConfig()
+			config.SetDefaultParams(pipeline.BonusDistributorStageName, []byte(tt.bonus))
+			chain, err := pipeline.BuildPipeline(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			eodCalculator := NewEODProcessor(chain, WithDuplicatePolicy(DuplicatePolicyIgnore))
+			defer eodCalculator.Close()
+			output := &bytes.Buffer{}
+			nonSeekable := struct{ io.Reader }{strings.NewReader(input)}
+			if _, err := eodCalculator.ProcessStream(context.Background(), nonSeekable, output); !errors.Is(err, tt.wantErr) {
+				t.Errorf("EODProcessor.ProcessStream() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			reader := csv.NewReader(output)
+			reader.Comma = ';'
+			got, _ := reader.ReadAll()
+			if len(got) != len(tt.want)+1 {
+				t.Fatalf("EODProcessor.ProcessStream() rows = %v, want %v", len(got)-1, len(tt.want))
+			}
+			for _, row := range got[1:] {
+				if row[afterEodHeaderIdxBalanced] != tt.want[row[afterEodHeaderIdxID]] {
+					t.Errorf("EODProcessor.ProcessStream() balanced = %v, want %v, id %v", row[afterEodHeaderIdxBalanced], tt.want[row[afterEodHeaderIdxID]], row[afterEodHeaderIdxID])
+				}
+			}
+		})
+	}
+}
+
+func TestEODProcessor_PlannedOrder(t *testing.T) {
+	inputRows := [][]string{
+		{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer"},
+		{"5", "Test 5", "20", "99", "100", "100", "3"},
+		{"3", "Test 3", "30", "99", "150", "100", "2"},
+		{"1", "Test 1", "25", "99", "100", "100", "2"},
+		{"4", "Test 4", "40", "99", "200", "120", "2"},
+		{"2", "Test 2", "50", "99", "200", "120", "2"},
+	}
+	config := &pipeline.BonusConfig{
+		Quota:   2,
+		Amount:  money.FromInt(10),
+		OrderBy: pipeline.BonusOrderID,
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	bonusDistributor := pipeline.NewBonusDistributorWithConfig(nil, config)
+	benefitCalculator := pipeline.NewBenefitCalculator(bonusDistributor.Channel())
+	averageCalculator := pipeline.NewAverageCalculator(benefitCalculator.Channel())
+	parser := NewParser(averageCalculator.Channel())
+	chain := pipeline.NewChain(parser, averageCalculator, benefitCalculator, bonusDistributor)
+	eodCalculator := NewEODProcessor(chain)
+	defer eodCalculator.Close()
+
+	// The same accounts must be selected however the input is sorted.
+	for i := 0; i < 3; i++ {
+		got, _, err := eodCalculator.ProcessSlice(context.Background(), inputRows, [][]string{afterEodCSVHeader})
+		if err != nil {
+			t.Errorf("EODProcessor.ProcessSlice() error = %v, want nil", err)
+			return
+		}
+		for _, row := range got[1:] {
+			want := "99"
+			if row[afterEodHeaderIdxID] == "1" || row[afterEodHeaderIdxID] == "2" {
+				want = "109"
+			}
+			if row[afterEodHeaderIdxBalanced] != want {
+				t.Errorf("EODProcessor.ProcessSlice() balanced = %v, want %v, id %v", row[afterEodHeaderIdxBalanced], want, row[afterEodHeaderIdxID])
+			}
+		}
+		rows := inputRows[1:]
+		rows[0], rows[len(rows)-1-i] = rows[len(rows)-1-i], rows[0]
+	}
+}
+
+func TestEODProcessor_ProcessFormat(t *testing.T) {
+	averageCalculator := pipeline.NewAverageCalculator(nil)
+	parser := NewParser(averageCalculator.Channel())
+	eodCalculator := NewEODProcessor(
+		pipeline.NewChain(parser, averageCalculator),
+		WithInputFormat(format.JSONLines),
+		WithOutputFormat(format.Tab),
+		WithDuplicatePolicy(DuplicatePolicyIgnore),
+	)
+	defer eodCalculator.Close()
+	input := strings.NewReader(`{"id":"1","Nama":"Test 1","Age":24,"Balanced":151,"Previous Balanced":100,"Average Balanced":100,"Free Transfer":3}
+{"id":"2","Nama":"Test 2","Age":25,"Balanced":150,"Previous Balanced":150,"Average Balanced":100,"Free Transfer":2}
+`)
+	output := &bytes.Buffer{}
+	if _, err := eodCalculator.ProcessStream(context.Background(), input, output); err != nil {
+		t.Errorf("EODProcessor.ProcessStream() error = %v, want nil", err)
+		return
+	}
+	got, err := format.ReadAll(format.Tab.NewReader(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(got[1:], func(i, j int) bool {
+		return got[i+1][afterEodHeaderIdxID] < got[j+1][afterEodHeaderIdxID]
+	})
+	// Thread number depends on scheduling.
+	for _, row := range got[1:] {
+		row[afterEodHeaderIdxNo1Thread] = ""
+	}
+	want := [][]string{
+		afterEodCSVHeader,
+		{"1", "Test 1", "24", "151", "0", "0", "100", "125.50", "", "3", "0"},
+		{"2", "Test 2", "25", "150", "0", "0", "150", "150", "", "2", "0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EODProcessor.ProcessStream() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,191 @@
+package bankeodprocessor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+// Reconciliation represent the totals of a run used to reconcile the input with the output.
+type Reconciliation struct {
+	// InputRows is the amount of rows of the input.
+	InputRows int `json:"input_rows"`
+	// OutputRows is the amount of rows of the output, including the rows kept from the output template.
+	OutputRows int `json:"output_rows"`
+	// ProcessedRows is the amount of input rows processed successfully.
+	ProcessedRows int `json:"processed_rows"`
+	// RejectedRows is the amount of input rows that failed.
+	RejectedRows int `json:"rejected_rows"`
+	// SkippedRows is the amount of duplicate input rows that are skipped or merged into another row.
+	SkippedRows int `json:"skipped_rows"`
+	// BalanceBefore is the sum of the balanced of the processed rows before the run.
+	BalanceBefore money.Amount `json:"balance_before"`
+	// BalanceAfter is the sum of the balanced of the processed rows after the run.
+	BalanceAfter money.Amount `json:"balance_after"`
+	// TotalAdjustment is the sum of every balanced adjustment of the processed rows.
+	TotalAdjustment money.Amount `json:"total_adjustment"`
+	// Unexplained is the change of the balance that isn't explained by the adjustments, zero when reconciled.
+	Unexplained money.Amount `json:"unexplained"`
+	// Adjustments is the balanced adjustment of every rule ordered by rule name.
+	Adjustments []RuleAdjustment `json:"adjustments"`
+	// FreeTransferChanges is the amount of processed rows which free transfer changed.
+	FreeTransferChanges int `json:"free_transfer_changes"`
+	// MissingIDs is the input ids that are neither in the output nor rejected ordered by id.
+	// Streamed and sharded run only check it when id tracking is enabled.
+	MissingIDs []string `json:"missing_ids"`
+
+	// adjustments is the balanced adjustment of every rule by its name.
+	adjustments map[string]*RuleAdjustment
+}
+
+// RuleAdjustment represent the balanced adjustment given by a rule.
+type RuleAdjustment struct {
+	// Rule is the name of the rule.
+	Rule string `json:"rule"`
+	// Rows is the amount of rows adjusted by the rule.
+	Rows int `json:"rows"`
+	// Amount is the sum of the adjustment.
+	Amount money.Amount `json:"amount"`
+}
+
+// add will record the balances and the adjustments of given processed data.
+func (r *Reconciliation) add(data *pipeline.EODRowData) {
+	r.ProcessedRows++
+	// The input is already parsed successfully by the pipeline.
+	before, _ := money.Parse(data.InputRow[beforeEodHeaderIdxBalanced])
+	r.BalanceBefore = r.BalanceBefore.Add(before)
+	r.BalanceAfter = r.BalanceAfter.Add(data.Balanced)
+	var freeTransfer []pipeline.Adjustment
+	for _, adjustment := range data.Adjustments {
+		if adjustment.Field == pipeline.FieldFreeTransfer {
+			freeTransfer = append(freeTransfer, adjustment)
+			continue
+		}
+		if r.adjustments == nil {
+			r.adjustments = make(map[string]*RuleAdjustment)
+		}
+		ruleAdjustment, ok := r.adjustments[adjustment.Rule]
+		if !ok {
+			ruleAdjustment = &RuleAdjustment{Rule: adjustment.Rule}
+			r.adjustments[adjustment.Rule] = ruleAdjustment
+		}
+		ruleAdjustment.Rows++
+		ruleAdjustment.Amount = ruleAdjustment.Amount.Add(adjustment.Delta())
+		r.TotalAdjustment = r.TotalAdjustment.Add(adjustment.Delta())
+	}
+	if len(freeTransfer) > 0 && freeTransfer[0].Old.Cmp(freeTransfer[len(freeTransfer)-1].New) != 0 {
+		r.FreeTransferChanges++
+	}
+}
+
+// finish will finalize the reconciliation given the ids of the input rows, the output rows and the rejected rows.
+// Missing ids are not checked if given input ids is nil.
+func (r *Reconciliation) finish(inputRows, outputRows int, inputIDs, outputIDs map[string]bool, rejected []RowError) {
+	r.InputRows = inputRows
+	r.OutputRows = outputRows
+	r.RejectedRows = len(rejected)
+	r.SkippedRows = inputRows - r.ProcessedRows - r.RejectedRows
+	r.Unexplained = r.BalanceAfter.Sub(r.BalanceBefore).Sub(r.TotalAdjustment)
+	r.Adjustments = make([]RuleAdjustment, 0, len(r.adjustments))
+	for _, adjustment := range r.adjustments {
+		r.Adjustments = append(r.Adjustments, *adjustment)
+	}
+	sort.Slice(r.Adjustments, func(i, j int) bool {
+		return r.Adjustments[i].Rule < r.Adjustments[j].Rule
+	})
+	rejectedIDs := make(map[string]bool, len(rejected))
+	for _, rowError := range rejected {
+		rejectedIDs[rowError.ID] = true
+	}
+	r.MissingIDs = []string{}
+	for id := range inputIDs {
+		if !outputIDs[id] && !rejectedIDs[id] {
+			r.MissingIDs = append(r.MissingIDs, id)
+		}
+	}
+	sort.Strings(r.MissingIDs)
+}
+
+// merge will add the totals of given reconciliation of given input file name into the reconciliation.
+// Missing ids of the input are prefixed with the input file name.
+func (r *Reconciliation) merge(inputFileName string, other *Reconciliation) {
+	r.InputRows += other.InputRows
+	r.OutputRows += other.OutputRows
+	r.ProcessedRows += other.ProcessedRows
+	r.RejectedRows += other.RejectedRows
+	r.SkippedRows += other.SkippedRows
+	r.BalanceBefore = r.BalanceBefore.Add(other.BalanceBefore)
+	r.BalanceAfter = r.BalanceAfter.Add(other.BalanceAfter)
+	r.TotalAdjustment = r.TotalAdjustment.Add(other.TotalAdjustment)
+	r.Unexplained = r.Unexplained.Add(other.Unexplained)
+	r.FreeTransferChanges += other.FreeTransferChanges
+	if r.adjustments == nil {
+		r.adjustments = make(map[string]*RuleAdjustment)
+	}
+	for _, adjustment := range other.Adjustments {
+		ruleAdjustment, ok := r.adjustments[adjustment.Rule]
+		if !ok {
+			ruleAdjustment = &RuleAdjustment{Rule: adjustment.Rule}
+			r.adjustments[adjustment.Rule] = ruleAdjustment
+		}
+		ruleAdjustment.Rows += adjustment.Rows
+		ruleAdjustment.Amount = ruleAdjustment.Amount.Add(adjustment.Amount)
+	}
+	r.Adjustments = make([]RuleAdjustment, 0, len(r.adjustments))
+	for _, adjustment := range r.adjustments {
+		r.Adjustments = append(r.Adjustments, *adjustment)
+	}
+	sort.Slice(r.Adjustments, func(i, j int) bool {
+		return r.Adjustments[i].Rule < r.Adjustments[j].Rule
+	})
+	for _, id := range other.MissingIDs {
+		r.MissingIDs = append(r.MissingIDs, inputFileName+":"+id)
+	}
+}
+
+// WriteText will write the reconciliation as human readable text into given writer.
+func (r *Reconciliation) WriteText(output io.Writer) error {
+	lines := []string{
+		fmt.Sprintf("Rows: %d input, %d output, %d processed, %d rejected, %d skipped",
+			r.InputRows, r.OutputRows, r.ProcessedRows, r.RejectedRows, r.SkippedRows),
+		fmt.Sprintf("Balance: %s before, %s after, %s adjustment, %s unexplained",
+			r.BalanceBefore, r.BalanceAfter, r.TotalAdjustment, r.Unexplained),
+	}
+	for _, adjustment := range r.Adjustments {
+		lines = append(lines, fmt.Sprintf(`Adjustment "%s": %s on %d rows`, adjustment.Rule, adjustment.Amount, adjustment.Rows))
+	}
+	lines = append(lines, fmt.Sprintf("Free transfer changed on %d rows", r.FreeTransferChanges))
+	if len(r.MissingIDs) == 0 {
+		lines = append(lines, "Missing ids: none")
+	} else {
+		lines = append(lines, "Missing ids: "+strings.Join(r.MissingIDs, ", "))
+	}
+	_, err := io.WriteString(output, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// WriteJSON will write the reconciliation as indented JSON into given writer.
+func (r *Reconciliation) WriteJSON(output io.Writer) error {
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// writeReconciliationFile will write the reconciliation of given report into given file name.
+// The file is written as JSON if its extension is .json, otherwise as text.
+func writeReconciliationFile(fileName string, report *RunReport) error {
+	write := report.Reconciliation.WriteText
+	if strings.EqualFold(filepath.Ext(fileName), ".json") {
+		write = report.Reconciliation.WriteJSON
+	}
+	if err := writeFileAtomic(fileName, "", write); err != nil {
+		return fmt.Errorf(`failed to write to provided reconciliation file %w`, err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package bankeodprocessor
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/money"
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+func TestEODProcessor_ProcessSlice_Reconciliation(t *testing.T) {
+	bonusDistributor := pipeline.NewBonusDistributor(nil)
+	benefitCalculator := pipeline.NewBenefitCalculator(bonusDistributor.Channel())
+	parser := NewParser(benefitCalculator.Channel())
+	eodCalculator := NewEODProcessor(pipeline.NewChain(parser, benefitCalculator, bonusDistributor))
+	defer eodCalculator.Close()
+	inputRows := [][]string{
+		beforeEodCSVHeader,
+		{"1", "Test 1", "24", "200", "100", "100", "3"},
+		{"2", "Test 2", "30", "120", "100", "100", "1"},
+		{"3", "Test 3", "30", "BAD", "100", "100", "1"},
+	}
+	outputRows := [][]string{
+		afterEodCSVHeader,
+		{"9", "Test 9", "40", "10", "", "", "10", "10", "", "1", ""},
+	}
+	_, report, err := eodCalculator.ProcessSlice(context.Background(), inputRows, outputRows)
+	if err != nil {
+		t.Fatalf("EODProcessor.ProcessSlice() error = %v, want nil", err)
+	}
+	// Row 1 get the balanced bonus, both rows get the bonus and row 2 get the free transfer.
+	want := Reconciliation{
+		InputRows:       3,
+		OutputRows:      3,
+		ProcessedRows:   2,
+		RejectedRows:    1,
+		SkippedRows:     0,
+		BalanceBefore:   money.FromInt(320),
+		BalanceAfter:    money.FromInt(365),
+		TotalAdjustment: money.FromInt(45),
+		Unexplained:     money.FromInt(0),
+		Adjustments: []RuleAdjustment{
+			{Rule: "balanced-bonus", Rows: 1, Amount: money.FromInt(25)},
+			{Rule: pipeline.BonusRuleName, Rows: 2, Amount: money.FromInt(20)},
+		},
+		FreeTransferChanges: 1,
+		MissingIDs:          []string{},
+	}
+	got := report.Reconciliation
+	got.adjustments = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EODProcessor.ProcessSlice() reconciliation = %+v, want %+v", got, want)
+	}
+}
+
+func TestReconciliation_WriteText(t *testing.T) {
+	reconciliation := &Reconciliation{
+		InputRows:       2,
+		OutputRows:      2,
+		ProcessedRows:   2,
+		BalanceBefore:   money.FromInt(100),
+		BalanceAfter:    money.MustParse("110.50"),
+		TotalAdjustment: money.FromInt(10),
+		Unexplained:     money.MustParse("0.50"),
+		Adjustments: []RuleAdjustment{
+			{Rule: "bonus", Rows: 1, Amount: money.FromInt(10)},
+		},
+		MissingIDs: []string{"3"},
+	}
+	output := &bytes.Buffer{}
+	if err := reconciliation.WriteText(output); err != nil {
+		t.Fatal(err)
+	}
+	want := "Rows: 2 input, 2 output, 2 processed, 0 rejected, 0 skipped\n" +
+		"Balance: 100 before, 110.50 after, 10 adjustment, 0.50 unexplained\n" +
+		"Adjustment \"bonus\": 10 on 1 rows\n" +
+		"Free transfer changed on 0 rows\n" +
+		"Missing ids: 3\n"
+	if got := output.String(); got != want {
+		t.Errorf("Reconciliation.WriteText() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,201 @@
+package bankeodprocessor
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+var (
+	ErrTooManyFailedRows = errors.New("too many failed rows")
+
+	// ErrorPolicyContinue will never fail the run because of failed rows.
+	ErrorPolicyContinue = ErrorPolicy{MaxFailedRows: -1}
+	// ErrorPolicyFailRun will fail the run on the first failed row.
+	ErrorPolicyFailRun = ErrorPolicy{MaxFailedRows: 0}
+)
+
+// ErrorPolicy represent policy to decide whether a run with failed rows should fail.
+type ErrorPolicy struct {
+	// MaxFailedRows is the maximum amount of failed rows tolerated in a run.
+	// Negative value means any amount of failed rows is tolerated.
+	MaxFailedRows int
+}
+
+// ErrorPolicyThreshold will return policy that fail the run only if more than
+// given amount of rows failed.
+func ErrorPolicyThreshold(maxFailedRows int) ErrorPolicy {
+	return ErrorPolicy{
+		MaxFailedRows: maxFailedRows,
+	}
+}
+
+// Check will return error if given report violate the policy.
+func (p ErrorPolicy) Check(report *RunReport) error {
+	if p.MaxFailedRows < 0 || len(report.FailedRows) <= p.MaxFailedRows {
+		return nil
+	}
+	return fmt.Errorf("%w, %d rows failed while at most %d is allowed", ErrTooManyFailedRows, len(report.FailedRows), p.MaxFailedRows)
+}
+
+// RowError represent a row that failed to be processed.
+type RowError struct {
+	// ID is the account id of the row.
+	ID string
+	// Line is the line number of the row in the input, the header is line 1.
+	Line int
+	// Stage is the name of the pipeline stage that failed.
+	Stage string
+	// Err is the underlying error.
+	Err error
+	// InputRow is the original input row.
+	InputRow []string
+}
+
+// Error will return the error message of the row error.
+func (r RowError) Error() string {
+	return fmt.Sprintf(`row "%s" at line %d failed at stage "%s", %v`, r.ID, r.Line, r.Stage, r.Err)
+}
+
+// Unwrap will return the underlying error.
+func (r RowError) Unwrap() error {
+	return r.Err
+}
+
+// RowWarning represent a validation warning flagged on a row that was still processed.
+type RowWarning struct {
+	// ID is the account id of the row.
+	ID string
+	// Line is the line number of the row in the input, the header is line 1.
+	Line int
+	// Check is the validation check that was violated.
+	Check string
+	// Message describe the violation.
+	Message string
+}
+
+// String will return the message of the row warning.
+func (r RowWarning) String() string {
+	return fmt.Sprintf(`row "%s" at line %d violate "%s", %s`, r.ID, r.Line, r.Check, r.Message)
+}
+
+// RunReport represent the outcome of a single processing run.
+type RunReport struct {
+	// RunID is the random id of the run, also recorded in the ledger and the audit log.
+	RunID string
+	// ProcessedRows is the amount of input rows processed, including the failed rows.
+	ProcessedRows int
+	// FailedRows list every row that failed ordered by its line number.
+	FailedRows []RowError
+	// Warnings list every validation warning of the rows ordered by its line number.
+	Warnings []RowWarning
+	// Duplicates list every account id that appear more than once in the input, followed by the output template.
+	Duplicates []DuplicateID
+	// Reconciliation is the totals of the run used to reconcile the input with the output.
+	Reconciliation Reconciliation
+
+	// inputHeader is the header of the mapped input rows.
+	inputHeader []string
+	// audited is true if the changes of the run are recorded into an audit log.
+	audited bool
+	// adjusted is every processed row with at least one change, only collected if the run is audited.
+	adjusted []adjustedRow
+}
+
+// add will record given finished data into the report.
+func (r *RunReport) add(data *pipeline.EODRowData) {
+	r.ProcessedRows++
+	for _, warning := range data.Warnings {
+		r.Warnings = append(r.Warnings, RowWarning{
+			ID:      data.InputRow[beforeEodHeaderIdxID],
+			Line:    data.Index + 2,
+			Check:   warning.Check,
+			Message: warning.Message,
+		})
+	}
+	if data.Error == nil {
+		r.Reconciliation.add(data)
+		if r.audited && len(data.Adjustments) > 0 {
+			r.adjusted = append(r.adjusted, adjustedRow{
+				id:          data.InputRow[beforeEodHeaderIdxID],
+				line:        data.Index + 2,
+				adjustments: data.Adjustments,
+			})
+		}
+		return
+	}
+	r.FailedRows = append(r.FailedRows, RowError{
+		ID:       data.InputRow[beforeEodHeaderIdxID],
+		Line:     data.Index + 2,
+		Stage:    data.ErrorStage,
+		Err:      data.Error,
+		InputRow: data.InputRow,
+	})
+}
+
+// reject will record given row that failed before reaching the pipeline into the report.
+func (r *RunReport) reject(rowError RowError) {
+	r.ProcessedRows++
+	r.FailedRows = append(r.FailedRows, rowError)
+}
+
+// finish will finalize the report once every data has been added.
+// Given rows amount and ids of the input and the output are used to reconcile the run.
+func (r *RunReport) finish(inputRows, outputRows int, inputIDs, outputIDs map[string]bool) {
+	r.Reconciliation.finish(inputRows, outputRows, inputIDs, outputIDs, r.FailedRows)
+	sort.Slice(r.FailedRows, func(i, j int) bool {
+		return r.FailedRows[i].Line < r.FailedRows[j].Line
+	})
+	sort.SliceStable(r.Warnings, func(i, j int) bool {
+		return r.Warnings[i].Line < r.Warnings[j].Line
+	})
+}
+
+// WriteRejects will write every failed row as CSV into given writer.
+// Each row contain the input row followed by its line number, failed stage and error message.
+// The input row columns are in the known columns order followed by the extra columns of the input.
+func (r *RunReport) WriteRejects(output io.Writer) error {
+	writer := csv.NewWriter(output)
+	writer.Comma = ';'
+	inputHeader := r.inputHeader
+	if inputHeader == nil {
+		inputHeader = beforeEodCSVHeader
+	}
+	header := make([]string, 0, len(inputHeader)+3)
+	header = append(header, inputHeader...)
+	header = append(header, "Line", "Stage", "Error")
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, failedRow := range r.FailedRows {
+		row := make([]string, 0, len(failedRow.InputRow)+3)
+		row = append(row, failedRow.InputRow...)
+		row = append(row, strconv.Itoa(failedRow.Line), failedRow.Stage, failedRow.Err.Error())
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeRejectFile will write every failed row of given report into given file name.
+// No file is written if there is no failed row, a reject file left by a previous run is removed instead.
+func writeRejectFile(fileName string, report *RunReport) error {
+	if len(report.FailedRows) == 0 {
+		if err := os.Remove(fileName); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf(`failed to remove provided reject file %w`, err)
+		}
+		return nil
+	}
+	if err := writeFileAtomic(fileName, "", report.WriteRejects); err != nil {
+		return fmt.Errorf(`failed to write to provided reject file %w`, err)
+	}
+	return nil
+}
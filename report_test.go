@@ -0,0 +1,92 @@
+package bankeodprocessor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestErrorPolicy_Check(t *testing.T) {
+	twoFailed := &RunReport{
+		ProcessedRows: 5,
+		FailedRows: []RowError{
+			{ID: "1", Line: 2, Stage: ParserStageName, Err: errors.New("an error")},
+			{ID: "2", Line: 3, Stage: ParserStageName, Err: errors.New("an error")},
+		},
+	}
+	tests := []struct {
+		name    string
+		policy  ErrorPolicy
+		report  *RunReport
+		wantErr bool
+	}{
+		{
+			"Given continue policy and failed rows then it must succeed",
+			ErrorPolicyContinue,
+			twoFailed,
+			false,
+		},
+		{
+			"Given fail run policy and no failed rows then it must succeed",
+			ErrorPolicyFailRun,
+			&RunReport{ProcessedRows: 5},
+			false,
+		},
+		{
+			"Given fail run policy and failed rows then it must fail",
+			ErrorPolicyFailRun,
+			twoFailed,
+			true,
+		},
+		{
+			"Given threshold policy at the limit then it must succeed",
+			ErrorPolicyThreshold(2),
+			twoFailed,
+			false,
+		},
+		{
+			"Given threshold policy over the limit then it must fail",
+			ErrorPolicyThreshold(1),
+			twoFailed,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.report)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ErrorPolicy.Check() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && !errors.Is(err, ErrTooManyFailedRows) {
+				t.Errorf("ErrorPolicy.Check() error = %v, want %v", err, ErrTooManyFailedRows)
+			}
+		})
+	}
+}
+
+func TestRunReport_WriteRejects(t *testing.T) {
+	report := &RunReport{
+		ProcessedRows: 5,
+		FailedRows: []RowError{
+			{
+				ID:       "3",
+				Line:     4,
+				Stage:    ParserStageName,
+				Err:      errors.New("an error"),
+				InputRow: []string{"3", "Test 3", "25", "BAD", "150", "100", "2"},
+			},
+		},
+	}
+	want := `id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer;Line;Stage;Error
+3;Test 3;25;BAD;150;100;2;4;parser;an error
+`
+	got := &strings.Builder{}
+	if err := report.WriteRejects(got); err != nil {
+		t.Errorf("RunReport.WriteRejects() error = %v, want nil", err)
+		return
+	}
+	if got.String() != want {
+		t.Errorf("RunReport.WriteRejects() = %v, want %v", got.String(), want)
+	}
+}
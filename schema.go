@@ -0,0 +1,139 @@
+package bankeodprocessor
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// DefaultCurrency is the currency of output rows migrated from a version without currency
+	// when the run has no currency configured.
+	DefaultCurrency = "IDR"
+	// currencyColumn is the column holding the currency of the account since version 2.
+	currencyColumn = "Currency"
+)
+
+var (
+	ErrUnknownSchemaVersion = errors.New("unknown schema version")
+	ErrCurrencyMismatch     = errors.New("currency is not the run currency")
+
+	// InputSchemaV1 is the original input schema.
+	InputSchemaV1 = &Schema{
+		Version: 1,
+		Columns: beforeEodCSVHeader,
+	}
+	// InputSchemaV2 add the currency of the account to InputSchemaV1.
+	InputSchemaV2 = &Schema{
+		Version: 2,
+		Columns: append(append([]string{}, beforeEodCSVHeader...), currencyColumn),
+	}
+	// OutputSchemaV1 is the original output schema.
+	OutputSchemaV1 = &Schema{
+		Version: 1,
+		Columns: afterEodCSVHeader,
+	}
+	// OutputSchemaV2 add the currency of the account to OutputSchemaV1.
+	// Currency default to DefaultCurrency when migrated from a version without currency,
+	// the processor use its run currency instead.
+	OutputSchemaV2 = &Schema{
+		Version: 2,
+		Columns: append(append([]string{}, afterEodCSVHeader...), currencyColumn),
+		Defaults: map[string]string{
+			currencyColumn: DefaultCurrency,
+		},
+		Carried: []string{currencyColumn},
+	}
+
+	// inputSchemas and outputSchemas list every schema version from the oldest.
+	// Every version must start with the columns of the previous version
+	// so the column indexes of the oldest version stay valid.
+	inputSchemas  = []*Schema{InputSchemaV1, InputSchemaV2}
+	outputSchemas = []*Schema{OutputSchemaV1, OutputSchemaV2}
+)
+
+// Schema represent a version of the known columns of an input or output file.
+type Schema struct {
+	// Version is the version of the schema.
+	Version int
+	// Columns is the known columns in order.
+	Columns []string
+	// Defaults is the value of the columns that are missing when migrating from another version.
+	// Columns without default are left empty.
+	Defaults map[string]string
+	// Carried is the columns copied from the input into every processed output row,
+	// including rows from the output template.
+	Carried []string
+}
+
+// InputSchema will return the input schema given its version.
+func InputSchema(version int) (*Schema, error) {
+	return findSchema(inputSchemas, version)
+}
+
+// OutputSchema will return the output schema given its version.
+func OutputSchema(version int) (*Schema, error) {
+	return findSchema(outputSchemas, version)
+}
+
+// withCurrency will return a copy of the schema which currency column default to given currency.
+// Will return the schema itself if it has no currency default.
+func (s *Schema) withCurrency(currency string) *Schema {
+	if _, ok := s.Defaults[currencyColumn]; !ok {
+		return s
+	}
+	schema := *s
+	schema.Defaults = make(map[string]string, len(s.Defaults))
+	for column, value := range s.Defaults {
+		schema.Defaults[column] = value
+	}
+	schema.Defaults[currencyColumn] = currency
+	return &schema
+}
+
+// findSchema will return the schema of given version from given schemas.
+func findSchema(schemas []*Schema, version int) (*Schema, error) {
+	for _, schema := range schemas {
+		if schema.Version == version {
+			return schema, nil
+		}
+	}
+	return nil, fmt.Errorf(`%w "%d", must be between 1 and %d`, ErrUnknownSchemaVersion, version, len(schemas))
+}
+
+// detectSchema will return the newest schema from given schemas that has every column in given header.
+// If given schema is not nil, it is used instead of being detected.
+// Will return the error of the oldest schema if none of the schema match.
+func detectSchema(schemas []*Schema, schema *Schema, header []string, aliases ColumnAliases) (*Schema, *columnMapping, error) {
+	if schema != nil {
+		mapping, err := newColumnMapping(schema.Columns, header, aliases)
+		if err != nil {
+			return nil, nil, fmt.Errorf("schema version %d, %w", schema.Version, err)
+		}
+		return schema, mapping, nil
+	}
+	var err error
+	for idx := len(schemas) - 1; idx >= 0; idx-- {
+		var mapping *columnMapping
+		if mapping, err = newColumnMapping(schemas[idx].Columns, header, aliases); err == nil {
+			return schemas[idx], mapping, nil
+		}
+	}
+	return nil, nil, err
+}
+
+// knownColumns will return every column of every schema version.
+func knownColumns() []string {
+	columns := []string{}
+	seen := make(map[string]bool)
+	for _, schemas := range [][]*Schema{inputSchemas, outputSchemas} {
+		for _, schema := range schemas {
+			for _, column := range schema.Columns {
+				if !seen[column] {
+					seen[column] = true
+					columns = append(columns, column)
+				}
+			}
+		}
+	}
+	return columns
+}
@@ -0,0 +1,195 @@
+package bankeodprocessor
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+func TestDetectSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  *Schema
+		header  []string
+		want    int
+		wantErr bool
+	}{
+		{
+			"Given v1 header then it must detect v1",
+			nil,
+			[]string{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer"},
+			1,
+			false,
+		},
+		{
+			"Given v2 header then it must detect v2",
+			nil,
+			[]string{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer", "Currency"},
+			2,
+			false,
+		},
+		{
+			"Given v2 header with v1 schema then it must keep currency as extra column",
+			InputSchemaV1,
+			[]string{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer", "Currency"},
+			1,
+			false,
+		},
+		{
+			"Given v1 header with v2 schema then it must fail",
+			InputSchemaV2,
+			[]string{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer"},
+			0,
+			true,
+		},
+		{
+			"Given invalid header then it must fail",
+			nil,
+			[]string{"id", "Nama"},
+			0,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := detectSchema(inputSchemas, tt.schema, tt.header, DefaultColumnAliases())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("detectSchema() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.Version != tt.want {
+				t.Errorf("detectSchema() = %v, want %v", got.Version, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputSchema(t *testing.T) {
+	if got, err := OutputSchema(2); err != nil || got != OutputSchemaV2 {
+		t.Errorf("OutputSchema() = %v, %v, want %v", got, err, OutputSchemaV2)
+	}
+	if _, err := OutputSchema(3); !errors.Is(err, ErrUnknownSchemaVersion) {
+		t.Errorf("OutputSchema() error = %v, want %v", err, ErrUnknownSchemaVersion)
+	}
+}
+
+func TestEODProcessor_ProcessSlice_Schema(t *testing.T) {
+	v1Input := [][]string{
+		{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer"},
+		{"1", "Test 1", "24", "151", "100", "100", "3"},
+	}
+	v2Input := [][]string{
+		{"id", "Nama", "Age", "Balanced", "Previous Balanced", "Average Balanced", "Free Transfer", "Currency"},
+		{"1", "Test 1", "24", "151", "100", "100", "3", "USD"},
+	}
+	v1Header := afterEodCSVHeader
+	v2Header := OutputSchemaV2.Columns
+	tests := []struct {
+		name         string
+		currency     string
+		outputSchema *Schema
+		inputRows    [][]string
+		outputRows   [][]string
+		want         [][]string
+		wantFailed   int
+	}{
+		{
+			"Given v1 input without template then it must emit v1",
+			DefaultCurrency,
+			nil,
+			v1Input,
+			[][]string{v1Header},
+			[][]string{v1Header, {"1", "Test 1", "24", "151", "0", "0", "100", "125.50", "", "3", "0"}},
+			0,
+		},
+		{
+			"Given v1 input with v2 output without run currency then it must default currency",
+			"",
+			OutputSchemaV2,
+			v1Input,
+			[][]string{v1Header},
+			[][]string{v2Header, {"1", "Test 1", "24", "151", "0", "0", "100", "125.50", "", "3", "0", "IDR"}},
+			0,
+		},
+		{
+			"Given v1 input with v2 output and another currency then it must default to the run currency",
+			"USD",
+			OutputSchemaV2,
+			v1Input,
+			[][]string{v1Header},
+			[][]string{v2Header, {"1", "Test 1", "24", "151", "0", "0", "100", "125.50", "", "3", "0", "USD"}},
+			0,
+		},
+		{
+			"Given v2 input with v1 template then it must keep emitting v1",
+			"USD",
+			nil,
+			v2Input,
+			[][]string{v1Header, {"1", "Test 1", "36", "197", "", "", "164", "193", "", "2", ""}},
+			[][]string{v1Header, {"1", "Test 1", "36", "151", "0", "0", "164", "125.50", "", "3", "0"}},
+			0,
+		},
+		{
+			"Given v2 input with v1 template and v2 output then it must migrate the template",
+			"USD",
+			OutputSchemaV2,
+			v2Input,
+			[][]string{v1Header, {"1", "Test 1", "36", "197", "", "", "164", "193", "", "2", ""}, {"9", "Test 9", "40", "10", "", "", "10", "10", "", "1", ""}},
+			[][]string{
+				v2Header,
+				{"1", "Test 1", "36", "151", "0", "0", "164", "125.50", "", "3", "0", "USD"},
+				{"9", "Test 9", "40", "10", "", "", "10", "10", "", "1", "", "USD"},
+			},
+			0,
+		},
+		{
+			"Given v2 input of another currency then it must reject the row",
+			DefaultCurrency,
+			nil,
+			v2Input,
+			[][]string{v1Header},
+			[][]string{v2Header},
+			1,
+		},
+		{
+			"Given v2 input without run currency then it must accept any currency",
+			"",
+			nil,
+			v2Input,
+			[][]string{v1Header},
+			[][]string{v2Header, {"1", "Test 1", "24", "151", "0", "0", "100", "125.50", "", "3", "0", "USD"}},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			averageCalculator := pipeline.NewAverageCalculator(nil)
+			parser := NewParser(averageCalculator.Channel())
+			eodCalculator := NewEODProcessor(pipeline.NewChain(parser, averageCalculator), WithOutputSchema(tt.outputSchema), WithCurrency(tt.currency))
+			defer eodCalculator.Close()
+			got, report, err := eodCalculator.ProcessSlice(context.Background(), tt.inputRows, tt.outputRows)
+			if err != nil {
+				t.Errorf("EODProcessor.ProcessSlice() error = %v, want nil", err)
+				return
+			}
+			if len(report.FailedRows) != tt.wantFailed {
+				t.Errorf("EODProcessor.ProcessSlice() failed rows = %v, want %v", len(report.FailedRows), tt.wantFailed)
+			}
+			for _, failed := range report.FailedRows {
+				if !errors.Is(failed.Err, ErrCurrencyMismatch) {
+					t.Errorf("EODProcessor.ProcessSlice() failed row error = %v, want %v", failed.Err, ErrCurrencyMismatch)
+				}
+			}
+			// Thread number depends on scheduling.
+			if len(got) > 1 {
+				got[1][afterEodHeaderIdxNo1Thread] = ""
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EODProcessor.ProcessSlice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
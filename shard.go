@@ -0,0 +1,482 @@
+package bankeodprocessor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/firmanmm/bank-eod-processor/format"
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+const (
+	// shardScanSize is the size of each read when looking for a line boundary.
+	shardScanSize = 4096
+)
+
+var (
+	ErrFormatNotShardable    = errors.New("input format can't be sharded")
+	ErrShardingNotConfigured = errors.New("sharding is not configured")
+)
+
+// PipelineFactory represent function creating a new pipeline instance, used to give every shard its own pipeline.
+type PipelineFactory func() (pipeline.IPipeline, error)
+
+// WithShards will set the amount of shards the input is split into by ProcessShards and the factory
+// creating the pipeline of each shard. Amount lower than one is treated as one.
+func WithShards(shards int, factory PipelineFactory) Option {
+	return func(e *EODProcessor) {
+		e.shards = shards
+		e.pipelineFactory = factory
+	}
+}
+
+// shard represent a byte range of the input holding whole rows, the header line is not part of any shard.
+type shard struct {
+	start int64
+	end   int64
+	// firstIndex is the index of the first row of the shard in the whole input.
+	firstIndex int
+}
+
+// splitShards will split the rows of given input after the header line into at most given amount of byte ranges
+// of similar size, every range end on a line boundary. Ranges without any byte are dropped.
+// Will return the header line and the shards.
+func splitShards(input io.ReaderAt, size int64, count int) ([]byte, []shard, error) {
+	headerEnd, err := nextLineStart(input, 0, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := make([]byte, headerEnd)
+	if _, err := input.ReadAt(header, 0); err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf(`failed to process provided input stream %w`, err)
+	}
+	if count < 1 {
+		count = 1
+	}
+	shards := []shard{}
+	start := headerEnd
+	for idx := 1; idx <= count && start < size; idx++ {
+		end := size
+		if idx < count {
+			target := headerEnd + (size-headerEnd)*int64(idx)/int64(count)
+			if target <= start {
+				continue
+			}
+			if end, err = nextLineStart(input, target-1, size); err != nil {
+				return nil, nil, err
+			}
+		}
+		shards = append(shards, shard{
+			start: start,
+			end:   end,
+		})
+		start = end
+	}
+	return header, shards, nil
+}
+
+// nextLineStart will return the offset right after the first line break found from given offset,
+// or given size if there is no more line break.
+func nextLineStart(input io.ReaderAt, offset, size int64) (int64, error) {
+	buffer := make([]byte, shardScanSize)
+	for offset < size {
+		n, err := input.ReadAt(buffer, offset)
+		if idx := bytes.IndexByte(buffer[:n], '\n'); idx >= 0 {
+			return offset + int64(idx) + 1, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf(`failed to process provided input stream %w`, err)
+		}
+		if n == 0 {
+			break
+		}
+		offset += int64(n)
+	}
+	return size, nil
+}
+
+// shardReader will return a reader of the rows of given shard with the header already read.
+// Rows holding a line break are refused since shards are split on line boundaries.
+func (e *EODProcessor) shardReader(input io.ReaderAt, header []byte, s shard) (format.Reader, error) {
+	reader := e.inputFormat.NewReader(io.MultiReader(bytes.NewReader(header), io.NewSectionReader(input, s.start, s.end-s.start)))
+	if _, err := reader.Read(); err != nil {
+		return nil, fmt.Errorf(`failed to process provided input stream %w`, err)
+	}
+	return &shardRowReader{
+		Reader: reader,
+		index:  s.firstIndex,
+	}, nil
+}
+
+// shardRowReader represent a reader of the rows of a shard enforcing the format.LineFormat rule
+// that a row must not hold a line break, such as a quoted line break of a delimited row.
+// A row split by a line break can be cut in half by the shard boundaries, so it fail the run instead.
+// A shard ending inside a quoted line break already fail to be read as its quote is never closed,
+// its error is given the row it occurred after since the line of the error start from the shard.
+type shardRowReader struct {
+	format.Reader
+	// index is the index of the next row in the whole input.
+	index int
+}
+
+// Read will return the next row of the shard.
+// Will return ErrFormatNotShardable if any field of the row hold a line break.
+// Rows are counted from one in the whole input, the header excluded.
+func (r *shardRowReader) Read() ([]string, error) {
+	row, err := r.Reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return nil, fmt.Errorf(`%w after row %d, row holding a quoted line break can't be sharded`, err, r.index)
+	}
+	r.index++
+	for _, field := range row {
+		if strings.ContainsAny(field, "\r\n") {
+			return nil, fmt.Errorf(`%w, row %d hold a line break`, ErrFormatNotShardable, r.index)
+		}
+	}
+	return row, nil
+}
+
+// shardWorker represent the processing state of a single shard.
+type shardWorker struct {
+	shard    shard
+	pipeline pipeline.IPipeline
+	// inFlight act as semaphore to bound the amount of rows of the shard inside its pipeline.
+	inFlight chan struct{}
+	reorder  *reorderBuffer
+	// spill hold the output rows of the shard until every shard is done.
+	spill    *os.File
+	writer   format.Writer
+	writeErr error
+	readErr  error
+}
+
+// ProcessShardedFile will process given input file name into output file name by splitting it into shards.
+// Like ProcessStreamFile, the output file is not used as template and is only replaced once the whole input
+// has been processed successfully.
+// Will also write failed rows on the reject file and the reconciliation on the reconciliation file if configured.
+// Will return ErrAlreadyProcessed if the ledger is configured and the run has already been processed.
+func (e *EODProcessor) ProcessShardedFile(ctx context.Context, inputFileName, outputFileName string) (*RunReport, error) {
+	return e.processFile(inputFileName, outputFileName, func(input *os.File, output io.Writer) (*RunReport, error) {
+		info, err := input.Stat()
+		if err != nil {
+			return nil, fmt.Errorf(`failed to process provided input file %w`, err)
+		}
+		return e.ProcessShards(ctx, input, info.Size(), output)
+	})
+}
+
+// ProcessShards will split given input of given size into byte range shards on line boundaries and process
+// every shard concurrently on its own pipeline created by the factory given on WithShards.
+// The input format must be a format.LineFormat, rows are merged into the output in input order.
+// Row holding a line break, such as a quoted line break of a delimited row, fail the run since shards can cut it in half.
+// Every row is planned once before any row is processed and the plan is shared by the pipeline of every shard,
+// so planned stages such as the bonus distributor see the whole input and make the same decision as an unsharded run.
+// Output rows of each shard are held on a temporary file until every shard is done, and at most
+// streamMaxInFlight rows of each shard are kept in memory, the changed rows are also kept until the run is recorded
+// when an audit log is configured.
+// Duplicate ids are handled across the whole input like ProcessStream, which need id tracking unless the policy is
+// DuplicatePolicyIgnore. DuplicatePolicyMerge, OutputOrderID and buffered output format are not supported.
+// Will stop reading once the context is done and return its error after the in-flight rows are drained.
+// Will return the report of the run, the report is also returned when the run fail because of its error policy.
+func (e *EODProcessor) ProcessShards(ctx context.Context, input io.ReaderAt, size int64, output io.Writer) (*RunReport, error) {
+	if e.pipelineFactory == nil {
+		return nil, ErrShardingNotConfigured
+	}
+	if _, ok := e.inputFormat.(format.LineFormat); !ok {
+		return nil, fmt.Errorf(`%w "%s"`, ErrFormatNotShardable, e.inputFormat.Name())
+	}
+	if err := e.checkStreamDuplicatePolicy(); err != nil {
+		return nil, err
+	}
+	if err := e.checkStreamOutput(); err != nil {
+		return nil, err
+	}
+	headerLine, shards, err := splitShards(input, size, e.shards)
+	if err != nil {
+		return nil, err
+	}
+	header, err := e.inputFormat.NewReader(bytes.NewReader(headerLine)).Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrInvalidInputRows
+		}
+		return nil, fmt.Errorf(`failed to process provided input stream %w`, err)
+	}
+	inputSchema, mapping, err := detectSchema(inputSchemas, e.inputSchema, header, e.columnAliases)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate input header, %w", err)
+	}
+	var lastIndexes map[string]int
+	if e.duplicatePolicy == DuplicatePolicyKeepLast {
+		if lastIndexes, err = e.scanLastIndexes(ctx, io.NewSectionReader(input, 0, size)); err != nil {
+			return nil, err
+		}
+	}
+
+	workers := make([]*shardWorker, len(shards))
+	defer func() {
+		for _, worker := range workers {
+			if worker == nil {
+				continue
+			}
+			if worker.pipeline != nil {
+				worker.pipeline.Close()
+				worker.pipeline.Wait()
+			}
+			if worker.spill != nil {
+				worker.spill.Close()
+				os.Remove(worker.spill.Name())
+			}
+		}
+	}()
+	for idx, s := range shards {
+		worker := &shardWorker{
+			shard:    s,
+			inFlight: make(chan struct{}, streamMaxInFlight),
+		}
+		workers[idx] = worker
+		shardPipeline, err := e.pipelineFactory()
+		if err != nil {
+			return nil, fmt.Errorf(`failed to create the pipeline of shard %d, %w`, idx, err)
+		}
+		worker.pipeline = shardPipeline
+		if worker.spill, err = os.CreateTemp("", "eod-shard-*"); err != nil {
+			return nil, fmt.Errorf(`failed to create the temporary file of shard %d, %w`, idx, err)
+		}
+		worker.writer = format.CSV.NewWriter(worker.spill)
+	}
+
+	run := e.newRun(ctx, mapping.header)
+	filter := newDuplicateFilter(e.duplicatePolicy, DuplicateSourceInput, lastIndexes)
+	skipped, inputIDs, inputRows, err := e.planShards(run, input, headerLine, mapping, workers, filter)
+	if err != nil {
+		return nil, err
+	}
+	duplicates := filter.result()
+	if e.duplicatePolicy == DuplicatePolicyRejectRun && len(duplicates) > 0 {
+		return nil, duplicateError(duplicates)
+	}
+
+	outputSchema := e.outputSchemaOf(inputSchema, nil)
+	layout := newOutputLayout(mapping.header, len(inputSchema.Columns), outputSchema, nil)
+	finishChannel := make(chan *pipeline.EODRowData, streamMaxInFlight)
+	report := &RunReport{
+		RunID:       newRunID(),
+		inputHeader: mapping.header,
+		audited:     e.auditLog != nil,
+	}
+	outputIDs := e.newIDTracker()
+	outputRows := 0
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for finished := range finishChannel {
+			// Shards are ordered by their first index, the owner is the last shard starting at or before the index.
+			owner := workers[sort.Search(len(workers), func(i int) bool {
+				return workers[i].shard.firstIndex > finished.Index
+			})-1]
+			for _, data := range owner.reorder.add(finished) {
+				report.add(data)
+				formatOutputRow(data)
+				// Keep draining on failure so no stage is blocked on the finish channel.
+				if owner.writeErr == nil && data.Error == nil {
+					owner.writeErr = owner.writer.Write(data.OutputRow)
+					outputIDs.add(data.OutputRow[afterEodHeaderIdxID])
+					outputRows++
+				}
+				<-owner.inFlight
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for _, worker := range workers {
+		wg.Add(1)
+		go func(worker *shardWorker) {
+			defer wg.Done()
+			worker.readErr = e.feedShard(ctx, run, input, headerLine, mapping, layout, worker, skipped, finishChannel)
+		}(worker)
+	}
+	wg.Wait()
+	// Filling the semaphores means every row pushed has been written.
+	for _, worker := range workers {
+		for i := 0; i < streamMaxInFlight; i++ {
+			worker.inFlight <- struct{}{}
+		}
+	}
+	close(finishChannel)
+	<-collected
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, worker := range workers {
+		if worker.readErr != nil {
+			return nil, worker.readErr
+		}
+	}
+	if err := mergeShards(workers, e.outputFormat.NewWriter(output), layout.header); err != nil {
+		return nil, fmt.Errorf(`failed to write to provided output stream %w`, err)
+	}
+	report.Duplicates = duplicates
+	report.finish(inputRows, outputRows, inputIDs, outputIDs)
+	return report, e.errorPolicy.Check(report)
+}
+
+// planShards will read every row of given shards in input order to assign the first index of each shard
+// and the reorder buffer starting at it, then plan every row kept by given filter once on the pipeline of the first shard.
+// The plan is shared with the pipeline of the other shards so each of them hold the plan of the whole input,
+// pipeline which can't share the plan is planned with every row as well.
+// Will return the indexes skipped by the filter, the ids of the input and the amount of input rows.
+func (e *EODProcessor) planShards(run *pipeline.Run, input io.ReaderAt, header []byte, mapping *columnMapping, workers []*shardWorker, filter *duplicateFilter) (map[int]bool, idTracker, int, error) {
+	planners := []pipeline.Planner{}
+	sharers := []*pipeline.Chain{}
+	source, _ := workers[0].pipeline.(*pipeline.Chain)
+	for idx, worker := range workers {
+		planner, ok := worker.pipeline.(pipeline.Planner)
+		if !ok {
+			continue
+		}
+		if chain, ok := worker.pipeline.(*pipeline.Chain); ok && idx > 0 && source != nil {
+			sharers = append(sharers, chain)
+			continue
+		}
+		planners = append(planners, planner)
+	}
+	skipped := make(map[int]bool)
+	inputIDs := e.newIDTracker()
+	idx := 0
+	for _, worker := range workers {
+		worker.shard.firstIndex = idx
+		worker.reorder = newReorderBuffer(idx)
+		reader, err := e.shardReader(input, header, worker.shard)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		for ; ; idx++ {
+			if err := run.Context.Err(); err != nil {
+				return nil, nil, 0, err
+			}
+			row, err := reader.Read()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, nil, 0, fmt.Errorf(`failed to process provided input stream %w`, err)
+			}
+			row = mapping.apply(row)
+			inputIDs.add(row[beforeEodHeaderIdxID])
+			if filter.skip(idx, row[beforeEodHeaderIdxID]) {
+				skipped[idx] = true
+				continue
+			}
+			for _, planner := range planners {
+				// Plan on a fresh data since planning may reject it.
+				data := &pipeline.EODRowData{
+					Index:    idx,
+					InputRow: row,
+					Run:      run,
+				}
+				if err := planRow(planner, data); err != nil {
+					return nil, nil, 0, err
+				}
+			}
+		}
+	}
+	for _, chain := range sharers {
+		if err := chain.SharePlan(run, source); err != nil {
+			return nil, nil, 0, fmt.Errorf("failed to plan the run, %w", err)
+		}
+	}
+	return skipped, inputIDs, idx, nil
+}
+
+// feedShard will push every row of given shard which index is not skipped into the pipeline of the shard.
+// Will stop once the context is done, and return the error of reading the shard if any.
+func (e *EODProcessor) feedShard(ctx context.Context, run *pipeline.Run, input io.ReaderAt, header []byte, mapping *columnMapping, layout *outputLayout, worker *shardWorker, skipped map[int]bool, finishChannel chan *pipeline.EODRowData) error {
+	reader, err := e.shardReader(input, header, worker.shard)
+	if err != nil {
+		return err
+	}
+	channel := worker.pipeline.Channel()
+	for idx := worker.shard.firstIndex; ; idx++ {
+		row, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf(`failed to process provided input stream %w`, err)
+		}
+		if skipped[idx] {
+			worker.reorder.skip(idx)
+			continue
+		}
+		row = mapping.apply(row)
+		select {
+		case worker.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+		data := &pipeline.EODRowData{
+			Index:         idx,
+			InputRow:      row,
+			OutputRow:     layout.newRow(row),
+			Run:           run,
+			FinishChannel: finishChannel,
+		}
+		select {
+		case channel <- data:
+		case <-ctx.Done():
+			<-worker.inFlight
+			return nil
+		}
+	}
+}
+
+// mergeShards will write given header then the output rows held by every given shard in shard order into given writer,
+// then close the writer.
+func mergeShards(workers []*shardWorker, writer format.Writer, header []string) error {
+	if err := writer.Write(header); err != nil {
+		writer.Close()
+		return err
+	}
+	for _, worker := range workers {
+		if worker.writeErr != nil {
+			writer.Close()
+			return worker.writeErr
+		}
+		if err := worker.writer.Close(); err != nil {
+			writer.Close()
+			return err
+		}
+		if _, err := worker.spill.Seek(0, io.SeekStart); err != nil {
+			writer.Close()
+			return err
+		}
+		reader := format.CSV.NewReader(worker.spill)
+		for {
+			row, err := reader.Read()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				writer.Close()
+				return err
+			}
+			if err := writer.Write(row); err != nil {
+				writer.Close()
+				return err
+			}
+		}
+	}
+	return writer.Close()
+}
@@ -0,0 +1,200 @@
+package bankeodprocessor
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/firmanmm/bank-eod-processor/format"
+	"github.com/firmanmm/bank-eod-processor/pipeline"
+)
+
+func TestSplitShards(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		count      int
+		wantHeader string
+		want       []string
+	}{
+		{"Given single shard then it must hold every row", "h\na\nb\n", 1, "h\n", []string{"a\nb\n"}},
+		{"Given rows of the same size then they must be split evenly", "h\na\nb\nc\nd\n", 2, "h\n", []string{"a\nb\n", "c\nd\n"}},
+		{"Given boundary inside a row then the row must be kept whole", "h\naaaa\nb\nc\n", 2, "h\n", []string{"aaaa\n", "b\nc\n"}},
+		{"Given more shards than rows then empty shards must be dropped", "h\na\nb\n", 8, "h\n", []string{"a\n", "b\n"}},
+		{"Given no trailing line break then the last row must be kept", "h\na\nb", 2, "h\n", []string{"a\n", "b"}},
+		{"Given header only then there must be no shard", "h\n", 4, "h\n", []string{}},
+		{"Given header without line break then there must be no shard", "h", 4, "h", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			header, shards, err := splitShards(input, input.Size(), tt.count)
+			if err != nil {
+				t.Fatalf("splitShards() error = %v, want nil", err)
+			}
+			if string(header) != tt.wantHeader {
+				t.Errorf("splitShards() header = %q, want %q", header, tt.wantHeader)
+			}
+			got := []string{}
+			for _, s := range shards {
+				got = append(got, tt.input[s.start:s.end])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitShards() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// newShardPipelineFactory will return factory of the default pipeline giving bonus to given quota of the highest balanced.
+func newShardPipelineFactory(t *testing.T, quota int) PipelineFactory {
+	config := DefaultPipelineConfig()
+	config.SetDefaultParams(pipeline.BonusDistributorStageName, []byte(fmt.Sprintf(`{"quota":%d,"amount":"10","order_by":"balanced-desc"}`, quota)))
+	return func() (pipeline.IPipeline, error) {
+		chain, err := pipeline.BuildPipeline(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return chain, nil
+	}
+}
+
+// readShardOutput will read given semicolon output and clear the worker columns since they depend on scheduling.
+func readShardOutput(t *testing.T, output []byte) [][]string {
+	reader := csv.NewReader(bytes.NewReader(output))
+	reader.Comma = ';'
+	rows, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows[1:] {
+		for _, column := range threadColumns {
+			row[column.index] = ""
+		}
+	}
+	return rows
+}
+
+func TestEODProcessor_ProcessShards(t *testing.T) {
+	rows := []string{"id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer"}
+	for id := 1; id <= 1000; id++ {
+		// Rejected rows and duplicates are spread across every shard.
+		if id%97 == 0 {
+			rows = append(rows, fmt.Sprintf("%d;Test;25;-1;100;100;2", id))
+			continue
+		}
+		if id%101 == 0 {
+			rows = append(rows, fmt.Sprintf("%d;Test;25;100;100;100;2", id-1))
+		}
+		rows = append(rows, fmt.Sprintf("%d;Test %d;25;%d;100;100;2", id, id, (id*37)%1000))
+	}
+	input := strings.Join(rows, "\n")
+	factory := newShardPipelineFactory(t, 100)
+	unshardedPipeline, _ := factory()
+	unsharded := NewEODProcessor(unshardedPipeline, WithDuplicatePolicy(DuplicatePolicyKeepFirst), WithIDTracking(true))
+	defer unsharded.Close()
+	wantOutput := &bytes.Buffer{}
+	wantReport, err := unsharded.ProcessStream(context.Background(), strings.NewReader(input), wantOutput)
+	if err != nil {
+		t.Fatalf("EODProcessor.ProcessStream() error = %v, want nil", err)
+	}
+	want := readShardOutput(t, wantOutput.Bytes())
+
+	for _, shards := range []int{1, 4, 7} {
+		t.Run(fmt.Sprintf("Given %d shards then the output must be the same as unsharded run", shards), func(t *testing.T) {
+			eodCalculator := NewEODProcessor(pipeline.NewChain(NewParser(nil)), WithShards(shards, factory), WithDuplicatePolicy(DuplicatePolicyKeepFirst), WithIDTracking(true))
+			defer eodCalculator.Close()
+			output := &bytes.Buffer{}
+			report, err := eodCalculator.ProcessShards(context.Background(), strings.NewReader(input), int64(len(input)), output)
+			if err != nil {
+				t.Fatalf("EODProcessor.ProcessShards() error = %v, want nil", err)
+			}
+			if got := readShardOutput(t, output.Bytes()); !reflect.DeepEqual(got, want) {
+				t.Errorf("EODProcessor.ProcessShards() output is not the same as unsharded run")
+			}
+			if report.ProcessedRows != wantReport.ProcessedRows {
+				t.Errorf("EODProcessor.ProcessShards() processed rows = %v, want %v", report.ProcessedRows, wantReport.ProcessedRows)
+			}
+			if !reflect.DeepEqual(report.Reconciliation, wantReport.Reconciliation) {
+				t.Errorf("EODProcessor.ProcessShards() reconciliation = %+v, want %+v", report.Reconciliation, wantReport.Reconciliation)
+			}
+			if !reflect.DeepEqual(report.Duplicates, wantReport.Duplicates) {
+				t.Errorf("EODProcessor.ProcessShards() duplicates = %v, want %v", report.Duplicates, wantReport.Duplicates)
+			}
+			if len(report.FailedRows) != len(wantReport.FailedRows) {
+				t.Errorf("EODProcessor.ProcessShards() failed rows = %v, want %v", len(report.FailedRows), len(wantReport.FailedRows))
+			}
+		})
+	}
+}
+
+func TestEODProcessor_ProcessShards_LineBreak(t *testing.T) {
+	rows := []string{"id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer"}
+	for id := 1; id <= 100; id++ {
+		rows = append(rows, fmt.Sprintf("%d;Test %d;25;100;100;100;2", id, id))
+		// Quoted line break is valid for delimited format but can't be split on line boundaries.
+		if id == 50 {
+			rows = append(rows, "1000;\"Test\n1000\";25;100;100;100;2")
+		}
+	}
+	input := strings.Join(rows, "\n")
+	lineBreakEnd := int64(strings.Index(input, "Test\n1000") + len("Test\n"))
+	factory := newShardPipelineFactory(t, 100)
+	cut := false
+	// Every boundary either cut the quoted line break in half or leave it inside a shard.
+	for shards := 1; shards <= 64; shards++ {
+		_, split, err := splitShards(strings.NewReader(input), int64(len(input)), shards)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range split {
+			cut = cut || s.end == lineBreakEnd
+		}
+		t.Run(fmt.Sprintf("Given quoted line break with %d shards then it must fail", shards), func(t *testing.T) {
+			eodCalculator := NewEODProcessor(pipeline.NewChain(NewParser(nil)), WithShards(shards, factory), WithDuplicatePolicy(DuplicatePolicyIgnore))
+			defer eodCalculator.Close()
+			_, err := eodCalculator.ProcessShards(context.Background(), strings.NewReader(input), int64(len(input)), &bytes.Buffer{})
+			if err == nil {
+				t.Fatalf("EODProcessor.ProcessShards() error = nil, want error")
+			}
+			if shards == 1 && !errors.Is(err, ErrFormatNotShardable) {
+				t.Errorf("EODProcessor.ProcessShards() error = %v, want %v", err, ErrFormatNotShardable)
+			}
+		})
+	}
+	if !cut {
+		t.Errorf("splitShards() never cut the quoted line break, want at least one shard boundary inside it")
+	}
+}
+
+func TestEODProcessor_ProcessShards_Unsupported(t *testing.T) {
+	input := "id;Nama;Age;Balanced;Previous Balanced;Average Balanced;Free Transfer\n1;Test;25;100;100;100;2\n"
+	factory := newShardPipelineFactory(t, 100)
+	tests := []struct {
+		name    string
+		options []Option
+		wantErr error
+	}{
+		{"Given no pipeline factory then it must fail", nil, ErrShardingNotConfigured},
+		{"Given not line based format then it must fail", []Option{WithShards(2, factory), WithInputFormat(format.JSONLines)}, ErrFormatNotShardable},
+		{"Given merge duplicate policy then it must fail", []Option{WithShards(2, factory), WithDuplicatePolicy(DuplicatePolicyMerge)}, ErrDuplicatePolicyNotStreamable},
+		{"Given duplicate detection without id tracking then it must fail", []Option{WithShards(2, factory)}, ErrDuplicatePolicyNotStreamable},
+		{"Given id output order then it must fail", []Option{WithShards(2, factory), WithDuplicatePolicy(DuplicatePolicyIgnore), WithOutputOrder(OutputOrderID)}, ErrOutputOrderNotStreamable},
+		{"Given columnar output then it must fail", []Option{WithShards(2, factory), WithDuplicatePolicy(DuplicatePolicyIgnore), WithOutputFormat(format.Columnar)}, ErrOutputFormatNotStreamable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eodCalculator := NewEODProcessor(pipeline.NewChain(NewParser(nil)), tt.options...)
+			defer eodCalculator.Close()
+			_, err := eodCalculator.ProcessShards(context.Background(), strings.NewReader(input), int64(len(input)), &bytes.Buffer{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("EODProcessor.ProcessShards() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -31,27 +31,54 @@ func NewWriter(waitGroup *sync.WaitGroup) *Writer {
 // In this case will format the data into CSV slice.
 func (w *Writer) Execute(workerID int, data *pipeline.EODRowData) {
 	data.FinishChannel = nil
+	formatOutputRow(data)
+	w.waitGroup.Done()
+}
+
+// formatOutputRow will format processed value of given data into its output row.
+// Will leave the output row untouched if the data failed to be processed,
+// failed data is reported on the reject file instead.
+func formatOutputRow(data *pipeline.EODRowData) {
 	if data.Error != nil {
-		errorIdx := 0
-		if data.ThreadNo1 == 0 {
-			errorIdx = int(afterEodHeaderIdxNo1Thread)
-		} else if data.ThreadNo2A == 0 {
-			errorIdx = int(afterEodHeaderIdxNo2AThread)
-		} else if data.ThreadNo2B == 0 {
-			errorIdx = int(afterEodHeaderIdxNo2BThread)
-		} else {
-			errorIdx = int(afterEodHeaderIdxNo3Thread)
+		return
+	}
+	outputRow := data.OutputRow
+	outputRow[afterEodHeaderIdxBalanced] = data.Balanced.String()
+	outputRow[afterEodHeaderIdxAverageBalanced] = data.AverageBalanced.String()
+	outputRow[afterEodHeaderIdxFreeTransfer] = strconv.Itoa(data.FreeTransfer)
+	for _, column := range threadColumns {
+		outputRow[column.index] = strconv.Itoa(column.worker(data))
+	}
+}
+
+// threadColumn represent a thread number column of the output filled with the worker of a stage.
+type threadColumn struct {
+	index CSVHeaderOutputIndex
+	stage string
+	// field restrict the column to the rows where the stage changed the field, any row if empty.
+	field string
+}
+
+var (
+	// threadColumns is the thread number columns of the output.
+	threadColumns = []threadColumn{
+		{afterEodHeaderIdxNo1Thread, pipeline.AverageCalculatorStageName, ""},
+		{afterEodHeaderIdxNo2AThread, pipeline.BenefitCalculatorStageName, pipeline.FieldFreeTransfer},
+		{afterEodHeaderIdxNo2BThread, pipeline.BenefitCalculatorStageName, pipeline.FieldBalanced},
+		{afterEodHeaderIdxNo3Thread, pipeline.BonusDistributorStageName, pipeline.FieldBalanced},
+	}
+)
+
+// worker will return the worker of the column stage which processed given data,
+// or 0 if the stage didn't process it or didn't change the column field.
+func (c threadColumn) worker(data *pipeline.EODRowData) int {
+	if len(c.field) == 0 {
+		return data.Workers[c.stage]
+	}
+	for _, adjustment := range data.Adjustments {
+		if adjustment.Stage == c.stage && adjustment.Field == c.field {
+			return data.Workers[c.stage]
 		}
-		data.OutputRow[errorIdx] = data.Error.Error()
-	} else {
-		outputRow := data.OutputRow
-		outputRow[afterEodHeaderIdxBalanced] = strconv.Itoa(data.Balanced)
-		outputRow[afterEodHeaderIdxAverageBalanced] = strconv.Itoa(data.AverageBalanced)
-		outputRow[afterEodHeaderIdxFreeTransfer] = strconv.Itoa(data.FreeTransfer)
-		outputRow[afterEodHeaderIdxNo1Thread] = strconv.Itoa(data.ThreadNo1)
-		outputRow[afterEodHeaderIdxNo2AThread] = strconv.Itoa(data.ThreadNo2A)
-		outputRow[afterEodHeaderIdxNo2BThread] = strconv.Itoa(data.ThreadNo2B)
-		outputRow[afterEodHeaderIdxNo3Thread] = strconv.Itoa(data.ThreadNo3)
 	}
-	w.waitGroup.Done()
+	return 0
 }
@@ -6,10 +6,22 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/firmanmm/bank-eod-processor/money"
 	"github.com/firmanmm/bank-eod-processor/pipeline"
 )
 
 func TestWriter_Execute(t *testing.T) {
+	workers := map[string]int{
+		ParserStageName:                     1,
+		pipeline.AverageCalculatorStageName: 11,
+		pipeline.BenefitCalculatorStageName: 21,
+		pipeline.BonusDistributorStageName:  41,
+	}
+	adjustments := []pipeline.Adjustment{
+		{Stage: pipeline.BenefitCalculatorStageName, Rule: "free-transfer", Field: pipeline.FieldFreeTransfer},
+		{Stage: pipeline.BenefitCalculatorStageName, Rule: "balanced-bonus", Field: pipeline.FieldBalanced},
+		{Stage: pipeline.BonusDistributorStageName, Rule: pipeline.BonusRuleName, Field: pipeline.FieldBalanced},
+	}
 	type args struct {
 		workerID int
 		data     *pipeline.EODRowData
@@ -32,14 +44,12 @@ func TestWriter_Execute(t *testing.T) {
 					OutputRow: []string{
 						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 					},
-					AverageBalanced:  1,
-					PreviousBalanced: 2,
-					Balanced:         3,
+					AverageBalanced:  money.FromInt(1),
+					PreviousBalanced: money.FromInt(2),
+					Balanced:         money.FromInt(3),
 					FreeTransfer:     4,
-					ThreadNo1:        11,
-					ThreadNo2A:       21,
-					ThreadNo2B:       31,
-					ThreadNo3:        41,
+					Workers:          workers,
+					Adjustments:      adjustments,
 				},
 			},
 			&pipeline.EODRowData{
@@ -48,16 +58,39 @@ func TestWriter_Execute(t *testing.T) {
 					"1", "Test 1", "24", "2", "3", "4", "5",
 				},
 				OutputRow: []string{
-					"1", "Test 1", "24", "3", "31", "41", "100", "1", "11", "4", "21",
+					"1", "Test 1", "24", "3", "21", "41", "100", "1", "11", "4", "21",
 				},
-				AverageBalanced:  1,
-				PreviousBalanced: 2,
-				Balanced:         3,
+				AverageBalanced:  money.FromInt(1),
+				PreviousBalanced: money.FromInt(2),
+				Balanced:         money.FromInt(3),
 				FreeTransfer:     4,
-				ThreadNo1:        11,
-				ThreadNo2A:       21,
-				ThreadNo2B:       31,
-				ThreadNo3:        41,
+				Workers:          workers,
+				Adjustments:      adjustments,
+			},
+			false,
+		},
+		{
+			"Given stage without change then its thread must be zero",
+			args{
+				workerID: 1,
+				data: &pipeline.EODRowData{
+					Index: 1,
+					OutputRow: []string{
+						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
+					},
+					Balanced:    money.FromInt(3),
+					Workers:     workers,
+					Adjustments: adjustments[:1],
+				},
+			},
+			&pipeline.EODRowData{
+				Index: 1,
+				OutputRow: []string{
+					"1", "Test 1", "24", "3", "0", "0", "100", "0", "11", "0", "21",
+				},
+				Balanced:    money.FromInt(3),
+				Workers:     workers,
+				Adjustments: adjustments[:1],
 			},
 			false,
 		},
@@ -73,14 +106,12 @@ func TestWriter_Execute(t *testing.T) {
 					OutputRow: []string{
 						"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 					},
-					AverageBalanced:  1,
-					PreviousBalanced: 2,
-					Balanced:         3,
+					AverageBalanced:  money.FromInt(1),
+					PreviousBalanced: money.FromInt(2),
+					Balanced:         money.FromInt(3),
 					FreeTransfer:     4,
-					ThreadNo1:        11,
-					ThreadNo2A:       21,
-					ThreadNo2B:       31,
-					ThreadNo3:        41,
+					Workers:          workers,
+					Adjustments:      adjustments,
 					Error:            errors.New("an error"),
 				},
 			},
@@ -90,16 +121,14 @@ func TestWriter_Execute(t *testing.T) {
 					"1", "Test 1", "24", "2", "3", "4", "5",
 				},
 				OutputRow: []string{
-					"1", "Test 1", "24", "176", "", "an error", "100", "125", "", "3", "",
+					"1", "Test 1", "24", "176", "", "", "100", "125", "", "3", "",
 				},
-				AverageBalanced:  1,
-				PreviousBalanced: 2,
-				Balanced:         3,
+				AverageBalanced:  money.FromInt(1),
+				PreviousBalanced: money.FromInt(2),
+				Balanced:         money.FromInt(3),
 				FreeTransfer:     4,
-				ThreadNo1:        11,
-				ThreadNo2A:       21,
-				ThreadNo2B:       31,
-				ThreadNo3:        41,
+				Workers:          workers,
+				Adjustments:      adjustments,
 				Error:            errors.New("an error"),
 			},
 			false,